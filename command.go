@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"reflect"
 	"slices"
 	"strings"
+	"time"
 )
 
 var (
@@ -15,11 +17,57 @@ var (
 	ErrCommandAlreadyHasParent = errors.New("command already has a parent")
 )
 
+type ErrUnknownCommand struct {
+	Command    string
+	Suggestion string
+}
+
+func (e *ErrUnknownCommand) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unknown command \"%s\", did you mean \"%s\"?", e.Command, e.Suggestion)
+	}
+	return fmt.Sprintf("unknown command \"%s\"", e.Command)
+}
+
 // HelpConfig is a configuration added to every executed command, for automatic help screen generation.
 type HelpConfig struct {
 	Help bool `inherited:"true" desc:"Show this help screen and exit."`
 }
 
+// VersionConfig is a configuration added to every executed command whose root command has a version (see
+// [Command.SetVersion]), for automatic version printing.
+type VersionConfig struct {
+	Version bool `inherited:"true" desc:"Show version information and exit."`
+}
+
+// ConfigFileConfig is a configuration added to every executed command whose root command has configuration files
+// enabled (see [Command.EnableConfigFile]), for loading flag values from a configuration file.
+type ConfigFileConfig struct {
+	ConfigFile string `name:"config" value-name:"FILE" inherited:"true" desc:"Configuration file to load flag values from."`
+}
+
+// TimeoutConfig is a configuration added to every executed command whose root command has action timeouts enabled (see
+// [Command.EnableTimeout]), for limiting the duration of the command's action.
+type TimeoutConfig struct {
+	Timeout time.Duration `inherited:"true" desc:"Maximum duration to run the command for (e.g. 30s or 5m); zero means no limit."`
+}
+
+// VerbosityConfig is a configuration added to every executed command whose root command has verbosity flags enabled
+// (see [Command.EnableVerbosity]), for controlling the amount of output of actions & hooks.
+type VerbosityConfig struct {
+	Verbose int  `short:"v" count:"true" inherited:"true" desc:"Increase output verbosity (may be repeated, e.g. -vv)."`
+	Quiet   bool `short:"q" inherited:"true" desc:"Suppress non-essential output."`
+}
+
+// level returns the verbosity level represented by this configuration: -1 if quiet output was requested, otherwise the
+// number of times verbose output was requested.
+func (vc *VerbosityConfig) level() int {
+	if vc.Quiet {
+		return -1
+	}
+	return vc.Verbose
+}
+
 type Action interface {
 	Run(context.Context) error
 }
@@ -48,6 +96,55 @@ func (i PreRunHookFunc) PreRun(ctx context.Context) error {
 	}
 }
 
+// PreRunHookWithInfo is an alternative to [PreRunHook], for hooks that need to know which command is being executed,
+// and which positional arguments were given to it. Hooks implementing it can be given to [New] just like [PreRunHook]
+// hooks, and are invoked in the same order.
+type PreRunHookWithInfo interface {
+	PreRun(ctx context.Context, cmd *Command, positionals []string) error
+}
+
+type PreRunHookWithInfoFunc func(ctx context.Context, cmd *Command, positionals []string) error
+
+func (i PreRunHookWithInfoFunc) PreRun(ctx context.Context, cmd *Command, positionals []string) error {
+	if i != nil {
+		return i(ctx, cmd, positionals)
+	} else {
+		return nil
+	}
+}
+
+// PersistentPreRunHook is a [PreRunHook] that is marked as persistent, by returning true from its Persistent method.
+// Before any command is executed, the persistent pre-run hooks of all commands in its chain (starting at the root) are
+// invoked first, and only then the rest of the pre-run hooks of those commands (again, starting at the root). This
+// guarantees that persistent hooks (e.g. for setting up logging) run before any other hook, regardless of the command
+// they are attached to.
+//
+// A [PreRunHookWithInfo] hook can also be marked as persistent, by implementing the Persistent method as well.
+type PersistentPreRunHook interface {
+	PreRunHook
+	Persistent() bool
+}
+
+type PersistentPreRunHookFunc func(context.Context) error
+
+func (i PersistentPreRunHookFunc) PreRun(ctx context.Context) error {
+	if i != nil {
+		return i(ctx)
+	} else {
+		return nil
+	}
+}
+
+func (i PersistentPreRunHookFunc) Persistent() bool {
+	return true
+}
+
+// isPersistentPreRunHook returns whether the given pre-run hook is marked as persistent (see [PersistentPreRunHook]).
+func isPersistentPreRunHook(h any) bool {
+	p, ok := h.(interface{ Persistent() bool })
+	return ok && p.Persistent()
+}
+
 type PostRunHook interface {
 	PostRun(context.Context, error, ExitCode) error
 }
@@ -68,13 +165,45 @@ type Command struct {
 	name             string
 	shortDescription string
 	longDescription  string
-	preRunHooks      []PreRunHook
+	preRunHooks      []any
 	postRunHooks     []PostRunHook
 	action           Action
 	flags            *flagSet
 	parent           *Command
 	subCommands      []*Command
+	version          string
+	configFile       bool
+	configDecoders   map[string]ConfigDecoder
+	timeout          bool
+	verbosity        bool
+	strict           bool
+	nonInterspersed  bool
+	envCaseFold      bool
+	promptMissing    bool
+	envPrefix        string
+	helpFlagName     string
+	helpFlagDesc     string
+	noAutoHelp       bool
+	hidden           bool
+	aliases          []string
+	defaultSubCmd    *Command
+	build            func() (*Command, error)
+	deprecated       string
+	flagSortMode     *FlagSortMode
+	hideZeroDefaults *bool
+	showFlagAliases  *bool
+	flagAliases      map[string]string
+	flagValidators   map[string]func(string) error
+	flagNormalizers  map[string]func(string) (string, error)
+	defaultValues    map[string]string
+	positionalsSpec  *positionalsSpec
+	exclusiveFlags   [][]string
+	oneRequiredFlags [][]string
 	HelpConfig       *HelpConfig
+	VersionConfig    *VersionConfig
+	ConfigFileConfig *ConfigFileConfig
+	TimeoutConfig    *TimeoutConfig
+	VerbosityConfig  *VerbosityConfig
 }
 
 // MustNew creates a new command using [New], but will panic if it returns an error.
@@ -99,12 +228,14 @@ func New(name, shortDescription, longDescription string, action Action, hooks []
 
 	// Translate the any-based hooks list into pre-run and post-run hooks
 	// Fail on any hook that doesn't implement at least one of them
-	var preRunHooks []PreRunHook
+	// Pre-run hooks are either a PreRunHook or a PreRunHookWithInfo
+	var preRunHooks []any
 	var postRunHooks []PostRunHook
 	for i, hook := range hooks {
 		var pre, post bool
-		if preRunHook, ok := hook.(PreRunHook); ok {
-			preRunHooks = append(preRunHooks, preRunHook)
+		switch hook.(type) {
+		case PreRunHookWithInfo, PreRunHook:
+			preRunHooks = append(preRunHooks, hook)
 			pre = true
 		}
 		if postRunHook, ok := hook.(PostRunHook); ok {
@@ -112,7 +243,7 @@ func New(name, shortDescription, longDescription string, action Action, hooks []
 			post = true
 		}
 		if !pre && !post {
-			return nil, fmt.Errorf("%w: hook %d (%T) is neither a PreRunHook nor a PostRunHook", ErrInvalidCommand, i, hook)
+			return nil, fmt.Errorf("%w: hook %d (%T) is neither a PreRunHook, a PreRunHookWithInfo nor a PostRunHook", ErrInvalidCommand, i, hook)
 		}
 	}
 
@@ -124,7 +255,12 @@ func New(name, shortDescription, longDescription string, action Action, hooks []
 		action:           action,
 		preRunHooks:      preRunHooks,
 		postRunHooks:     postRunHooks,
+		configDecoders:   make(map[string]ConfigDecoder),
 		HelpConfig:       &HelpConfig{},
+		VersionConfig:    &VersionConfig{},
+		ConfigFileConfig: &ConfigFileConfig{},
+		TimeoutConfig:    &TimeoutConfig{},
+		VerbosityConfig:  &VerbosityConfig{},
 	}
 
 	// Set nil parent
@@ -149,10 +285,44 @@ func (c *Command) setParent(parent *Command) error {
 	var parentFlags *flagSet
 	if parent != nil {
 		parentFlags = parent.flags
-	} else if parentFlagSet, err := newFlagSet(nil, reflect.ValueOf(c).Elem().FieldByName("HelpConfig")); err != nil {
-		return fmt.Errorf("failed creating Help flag set: %w", err)
 	} else {
-		parentFlags = parentFlagSet
+		var builtinConfigObjects []reflect.Value
+		if !c.noAutoHelp {
+			builtinConfigObjects = append(builtinConfigObjects, reflect.ValueOf(c).Elem().FieldByName("HelpConfig"))
+		}
+		if c.version != "" {
+			builtinConfigObjects = append(builtinConfigObjects, reflect.ValueOf(c).Elem().FieldByName("VersionConfig"))
+		}
+		if c.configFile {
+			builtinConfigObjects = append(builtinConfigObjects, reflect.ValueOf(c).Elem().FieldByName("ConfigFileConfig"))
+		}
+		if c.timeout {
+			builtinConfigObjects = append(builtinConfigObjects, reflect.ValueOf(c).Elem().FieldByName("TimeoutConfig"))
+		}
+		if c.verbosity {
+			builtinConfigObjects = append(builtinConfigObjects, reflect.ValueOf(c).Elem().FieldByName("VerbosityConfig"))
+		}
+		if parentFlagSet, err := newFlagSet(nil, builtinConfigObjects...); err != nil {
+			return fmt.Errorf("failed creating Help flag set: %w", err)
+		} else {
+			if c.configFile {
+				parentFlagSet.configFileFlagName = "config"
+				parentFlagSet.configDecoders = c.configDecoders
+			}
+			// The help flag is always the first flag, as it's the only field of the first built-in config struct
+			if c.noAutoHelp {
+				parentFlagSet.noHelpFlag = true
+			} else {
+				if c.helpFlagName != "" {
+					parentFlagSet.helpFlagName = c.helpFlagName
+					parentFlagSet.flags[0].Name = c.helpFlagName
+				}
+				if c.helpFlagDesc != "" {
+					parentFlagSet.flags[0].Description = &c.helpFlagDesc
+				}
+			}
+			parentFlags = parentFlagSet
+		}
 	}
 
 	// Create the flag-set
@@ -172,25 +342,683 @@ func (c *Command) setParent(parent *Command) error {
 	if fs, err := newFlagSet(parentFlags, configObjects...); err != nil {
 		return fmt.Errorf("failed creating flag-set for command '%s': %w", c.name, err)
 	} else {
+		fs.sortMode = c.flagSortMode
+		fs.hideZeroDefaults = c.hideZeroDefaults
+		fs.showFlagAliases = c.showFlagAliases
+		fs.flagAliases = c.flagAliases
+		fs.envPrefix = c.envPrefix
+		fs.validators = c.flagValidators
+		fs.normalizers = c.flagNormalizers
+		fs.defaultValues = c.defaultValues
+		fs.promptMissing = c.promptMissing
+		fs.positionalsSpec = c.positionalsSpec
+		fs.exclusiveFlagGroups = c.exclusiveFlags
+		fs.oneRequiredFlagGroups = c.oneRequiredFlags
 		c.parent = parent
 		c.flags = fs
 	}
 	return nil
 }
 
+// SetVersion sets the version of this command, which makes the "--version" flag available to it and all of its
+// sub-commands. Only the version of the root command is used, printed when "--version" is given.
+func (c *Command) SetVersion(version string) error {
+	c.version = version
+	if err := c.resetFlags(); err != nil {
+		return fmt.Errorf("failed setting version for command '%s': %w", c.name, err)
+	}
+	return nil
+}
+
+// EnableConfigFile makes the "--config=FILE" flag available to this command and all of its sub-commands. When given,
+// the file it points to is loaded before environment variables & CLI flags are applied, and its values are used as flag
+// values (overriding default values, but overridden by environment variables & CLI flags). Only takes effect when
+// invoked on the root command.
+//
+// The file is decoded by the decoder registered for its extension (see [Command.RegisterConfigDecoder]), defaulting to
+// [JSONConfigDecoder], in which case it must contain a JSON object whose keys are flag names (e.g. "my-flag"), and whose
+// values are strings, numbers, booleans, or arrays of those (for flags that accept multiple values).
+func (c *Command) EnableConfigFile() error {
+	c.configFile = true
+	if err := c.resetFlags(); err != nil {
+		return fmt.Errorf("failed enabling config file for command '%s': %w", c.name, err)
+	}
+	return nil
+}
+
+// RegisterConfigDecoder registers the given decoder for configuration files with the given extension (e.g. "yaml" or
+// ".yaml", matched case-insensitively), which allows supporting formats other than JSON (e.g. YAML or TOML) without this
+// package depending on their libraries. Registering a decoder for the empty extension replaces the default decoder used
+// for files whose extension has no registered decoder. Only takes effect when invoked on the root command.
+func (c *Command) RegisterConfigDecoder(ext string, decoder ConfigDecoder) {
+	c.configDecoders[normalizeConfigFileExt(ext)] = decoder
+}
+
+// EnableTimeout makes the "--timeout=DURATION" flag available to this command and all of its sub-commands. When given
+// (with a non-zero duration), the context given to the command's action is canceled once that duration elapses, and if
+// the action fails due to that, [ExitCodeTimeout] is returned. Only takes effect when invoked on the root command.
+func (c *Command) EnableTimeout() error {
+	c.timeout = true
+	if err := c.resetFlags(); err != nil {
+		return fmt.Errorf("failed enabling timeout for command '%s': %w", c.name, err)
+	}
+	return nil
+}
+
+// SetHelpFlag changes the name & description of the built-in help flag (see [HelpConfig]) of this command and all of its
+// sub-commands, e.g. to "--usage" or to a localized description. An empty description retains the default one. The
+// environment variable bound to the flag is derived from its new name. Only takes effect when invoked on the root
+// command.
+func (c *Command) SetHelpFlag(name, description string) error {
+	if name == "" || strings.HasPrefix(name, "-") || strings.ContainsAny(name, "= ") {
+		return fmt.Errorf("%w: invalid help flag name '%s'", ErrInvalidCommand, name)
+	}
+	c.helpFlagName, c.helpFlagDesc = name, description
+	if err := c.resetFlags(); err != nil {
+		return fmt.Errorf("failed setting help flag of command '%s': %w", c.name, err)
+	}
+	return nil
+}
+
+// DisableAutoHelp removes the built-in help flag (see [HelpConfig]) from this command and all of its sub-commands, e.g.
+// for programs providing their own "help" sub-command instead. Giving "--help" then fails like any other unknown flag.
+// Only takes effect when invoked on the root command.
+func (c *Command) DisableAutoHelp() error {
+	c.noAutoHelp = true
+	if err := c.resetFlags(); err != nil {
+		return fmt.Errorf("failed disabling help flag for command '%s': %w", c.name, err)
+	}
+	return nil
+}
+
+// EnableVerbosity makes the "-v, --verbose" (count) and "-q, --quiet" flags available to this command and all of its
+// sub-commands. The resolved verbosity level is made available to hooks & actions via the context they are given (see
+// [VerbosityFromContext]). Only takes effect when invoked on the root command.
+func (c *Command) EnableVerbosity() error {
+	c.verbosity = true
+	if err := c.resetFlags(); err != nil {
+		return fmt.Errorf("failed enabling verbosity for command '%s': %w", c.name, err)
+	}
+	return nil
+}
+
+// Validate verifies the flags of this command and all of its sub-commands (recursively), returning all problems found
+// (see [errors.Join]) - e.g. incompatible redefinitions of flags inherited from parent commands, or conflicting short
+// flag names. Such problems are otherwise only reported when the affected command is invoked, so this is useful for
+// catching misconfigurations in tests.
+func (c *Command) Validate() error {
+	var errs []error
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("command '%s': %w", c.getFullName(), err))
+	} else {
+		shortNames := make(map[string]string)
+		for _, mfd := range mergedFlagDefs {
+			if mfd.Short == nil {
+				continue
+			} else if slices.ContainsFunc(mergedFlagDefs, func(other *mergedFlagDef) bool { return other.Name == *mfd.Short }) {
+				errs = append(errs, fmt.Errorf("command '%s': short name '%s' of flag '%s' conflicts with flag '%s'", c.getFullName(), *mfd.Short, mfd.Name, *mfd.Short))
+			} else if other, ok := shortNames[*mfd.Short]; ok {
+				errs = append(errs, fmt.Errorf("command '%s': short name '%s' of flag '%s' conflicts with flag '%s'", c.getFullName(), *mfd.Short, mfd.Name, other))
+			} else {
+				shortNames[*mfd.Short] = mfd.Name
+			}
+		}
+	}
+	for _, subCmd := range c.subCommands {
+		if err := subCmd.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValueSources returns the source of each flag's value (by flag name), as applied by the last resolution or execution of
+// this command: [ValueSourceCLI], [ValueSourceEnv], [ValueSourceConfig] or [ValueSourceDefault]. This is useful for
+// debugging configuration precedence. Nil is returned if this command has not been resolved yet.
+func (c *Command) ValueSources() map[string]string {
+	return maps.Clone(c.flags.valueSources)
+}
+
+// ChangedFlags returns the names of the flags given by the user in the command line (or prompted for), sorted by name,
+// as applied by the last resolution or execution of this command. Flags applied from default values, configuration
+// files or environment variables are not included. This is useful for hooks that should only react to flags the user
+// explicitly changed (e.g. reconfiguring logging only if "--log-level" was given); [PreRunHookWithInfo] hooks are given
+// the command, and can call this method on it.
+func (c *Command) ChangedFlags() []string {
+	var names []string
+	for name, source := range c.flags.valueSources {
+		if source == ValueSourceCLI {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// SetEnvPrefix sets a prefix for the environment variable names derived from flag names (e.g. "MYAPP" would bind the
+// "--foo" flag to "MYAPP_FOO" instead of "FOO"). Environment variable names given explicitly via the "env" tag are not
+// prefixed. The prefix should be set on the root command, and applies to all of its sub-commands (if several commands
+// in the hierarchy set a prefix, the one closest to the root is used, so that inherited flags are bound consistently).
+func (c *Command) SetEnvPrefix(prefix string) {
+	if prefix = strings.ToUpper(prefix); prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	c.envPrefix = prefix
+	c.flags.envPrefix = prefix
+}
+
+// SetEnvVarsCaseInsensitive sets whether environment variable names are matched case-insensitively (e.g. "Path" would
+// match a flag bound to "PATH"). By default, names must match exactly. Only the root command's setting is used. If
+// several given environment variables only differ by case, the upper-cased one takes precedence.
+func (c *Command) SetEnvVarsCaseInsensitive(caseInsensitive bool) {
+	c.envCaseFold = caseInsensitive
+}
+
+// SetStrict sets whether this command and its sub-commands reject unknown sub-command names. By default, arguments that
+// do not match any sub-command are treated as positional arguments; in strict mode, if the invoked command has
+// sub-commands but does not accept positional arguments, an unknown sub-command error is returned instead (suggesting
+// the closest sub-command name, if one is similar enough).
+func (c *Command) SetStrict(strict bool) {
+	c.strict = strict
+}
+
+// SetInterspersedFlags sets whether flags of this command may be given after its positional arguments (the default).
+// When disabled, all arguments following the first positional argument of this command are considered positional
+// arguments as well, even if they start with "-" (e.g. "-v1.2.3" as a version string); arguments after the "--"
+// separator are still handled as usual. Unlike [Command.SetStrict], this setting is not inherited by sub-commands.
+func (c *Command) SetInterspersedFlags(enabled bool) {
+	c.nonInterspersed = !enabled
+}
+
+// SetPromptForMissingFlags sets whether this command and its sub-commands interactively prompt for the values of missing
+// required flags, instead of failing. Prompting only takes place when stdin is an interactive terminal; otherwise (e.g.
+// in CI), missing required flags still fail with [ErrRequiredFlagMissing]. The prompt shown for a flag can be set using
+// the "prompt" tag, and flags tagged with `secret:"true"` are not echoed back as they're typed.
+func (c *Command) SetPromptForMissingFlags(enabled bool) {
+	c.promptMissing = enabled
+	c.flags.promptMissing = enabled
+}
+
+// SetHidden sets whether this command is hidden from its parent's help screen (and from generated documentation &
+// shell completions). Hidden commands can still be invoked when named explicitly.
+func (c *Command) SetHidden(hidden bool) {
+	c.hidden = hidden
+}
+
+// SetFlagSortMode sets the order in which flags are listed in the help screens & usage lines of this command and its
+// sub-commands (unless they set their own sort mode). Flags are sorted alphabetically by default.
+func (c *Command) SetFlagSortMode(mode FlagSortMode) {
+	c.flagSortMode = &mode
+	c.flags.sortMode = c.flagSortMode
+}
+
+// SetHideZeroDefaults sets whether the help screens of this command and its sub-commands (unless they set their own)
+// omit default values which are the zero values of their flags' types (e.g. "default value: false" for booleans). By
+// default, all non-empty default values are shown.
+func (c *Command) SetHideZeroDefaults(hide bool) {
+	c.hideZeroDefaults = &hide
+	c.flags.hideZeroDefaults = c.hideZeroDefaults
+}
+
+// SetShowFlagAliases sets whether the help screens of this command and its sub-commands (unless they set their own) list
+// the aliases of flags (see [Command.AliasFlag]) next to their canonical flags. Aliases are not listed by default.
+func (c *Command) SetShowFlagAliases(show bool) {
+	c.showFlagAliases = &show
+	c.flags.showFlagAliases = c.showFlagAliases
+}
+
+// SetDeprecated marks this command as deprecated, with the given message (e.g. "use 'new-cmd' instead"). Deprecated
+// commands still run normally, but the message is printed when they are invoked, and shown in their parent's help
+// screen. An empty message clears the deprecation.
+func (c *Command) SetDeprecated(msg string) {
+	c.deprecated = msg
+}
+
+// SetDefaultSubCommand sets the sub-command (given by its name or one of its aliases) to invoke when this command is
+// invoked without a sub-command, yet has no action of its own. An error is returned if this command has no sub-command
+// by that name.
+func (c *Command) SetDefaultSubCommand(name string) error {
+	for _, subCmd := range c.subCommands {
+		if slices.Contains(subCmd.getNames(), name) {
+			c.defaultSubCmd = subCmd
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: unknown sub-command '%s' of command '%s'", ErrInvalidCommand, name, c.name)
+}
+
+// resolveDefaultSubCommand returns the command to invoke in lieu of this command: if this command has no action, yet has
+// a default sub-command, that sub-command is resolved (recursively); otherwise, this command is returned.
+func (c *Command) resolveDefaultSubCommand() (*Command, error) {
+	cmd := c
+	for cmd.action == nil && cmd.defaultSubCmd != nil {
+		cmd = cmd.defaultSubCmd
+		if cmd.build != nil {
+			if built, err := cmd.materialize(); err != nil {
+				return nil, err
+			} else {
+				cmd = built
+			}
+		}
+	}
+	return cmd, nil
+}
+
+// getVisibleSubCommands returns the sub-commands of this command that are not hidden.
+func (c *Command) getVisibleSubCommands() []*Command {
+	var subCommands []*Command
+	for _, subCmd := range c.subCommands {
+		if !subCmd.hidden {
+			subCommands = append(subCommands, subCmd)
+		}
+	}
+	return subCommands
+}
+
+// isStrict returns whether this command, or any of its parents, is in strict mode.
+func (c *Command) isStrict() bool {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.strict {
+			return true
+		}
+	}
+	return false
+}
+
+// verifySubCommand returns an [ErrUnknownCommand] error if this command is in strict mode, has sub-commands & accepts no
+// positional arguments (no positional arguments target nor specification), yet positional arguments were given (meaning
+// the first one is an unknown sub-command).
+func (c *Command) verifySubCommand(positionals []string) error {
+	if !c.isStrict() || len(c.subCommands) == 0 || len(positionals) == 0 || c.flags.hasPositionalsTargets() {
+		return nil
+	} else if c.positionalsSpec != nil && c.positionalsSpec.max != 0 {
+		return nil
+	}
+	var names []string
+	for _, subCmd := range c.getVisibleSubCommands() {
+		names = append(names, subCmd.name)
+	}
+	return &ErrUnknownCommand{Command: positionals[0], Suggestion: findClosest(positionals[0], names, 2)}
+}
+
+// MarkFlagsMutuallyExclusive declares that the given flags (by name, e.g. "json") must not be given together by the
+// user. Flags applied from default values or environment variables are not considered given by the user. The check also
+// applies to sub-commands that inherit all of the given flags.
+func (c *Command) MarkFlagsMutuallyExclusive(names ...string) error {
+	if err := c.verifyFlagGroup("mutually exclusive", names); err != nil {
+		return err
+	}
+	c.exclusiveFlags = append(c.exclusiveFlags, slices.Clone(names))
+	c.flags.exclusiveFlagGroups = c.exclusiveFlags
+	return nil
+}
+
+// MarkFlagsOneRequired declares that at least one of the given flags (by name, e.g. "file") must be given by the user.
+// Flags applied from default values or environment variables are not considered given by the user. The check also
+// applies to sub-commands that inherit all of the given flags.
+func (c *Command) MarkFlagsOneRequired(names ...string) error {
+	if err := c.verifyFlagGroup("one-required", names); err != nil {
+		return err
+	}
+	c.oneRequiredFlags = append(c.oneRequiredFlags, slices.Clone(names))
+	c.flags.oneRequiredFlagGroups = c.oneRequiredFlags
+	return nil
+}
+
+// SetFlagValidator registers a function that validates the values of the given flag (by name, e.g. "name"), for custom
+// validations not covered by tags. The validator is only invoked for values given by the user in the command line (with
+// "true" or "false" for boolean flags), and not for default values, configuration files or environment variables. Errors
+// returned by it are reported as [ErrInvalidValue] errors. The validator also applies to sub-commands inheriting the
+// flag. A nil validator removes the flag's validator.
+func (c *Command) SetFlagValidator(name string, fn func(string) error) error {
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		return err
+	} else if !slices.ContainsFunc(mergedFlagDefs, func(mfd *mergedFlagDef) bool { return mfd.Name == name }) {
+		return fmt.Errorf("%w: unknown flag '%s'", ErrInvalidCommand, name)
+	}
+	if fn == nil {
+		delete(c.flagValidators, name)
+		return nil
+	}
+	if c.flagValidators == nil {
+		c.flagValidators = make(map[string]func(string) error)
+	}
+	c.flagValidators[name] = fn
+	c.flags.validators = c.flagValidators
+	return nil
+}
+
+// SetFlagNormalizer registers a function that canonicalizes the values of the given flag (by name, e.g. "name") before
+// they are applied & validated, e.g. by lower-casing or trimming them, or by resolving relative paths. Unlike
+// validators, normalizers return the value to use instead of the given one. The normalizer is only invoked for values
+// given by the user (in the command line, or when prompted) and by environment variables, and not for default values or
+// configuration files. Errors returned by it are reported as [ErrInvalidValue] errors. The normalizer also applies to
+// sub-commands inheriting the flag. For boolean flags, it is given the value given in the command line ("true" if none
+// was given, e.g. "--verbose") before the value is parsed. A nil normalizer removes the flag's normalizer.
+func (c *Command) SetFlagNormalizer(name string, fn func(string) (string, error)) error {
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		return err
+	} else if !slices.ContainsFunc(mergedFlagDefs, func(mfd *mergedFlagDef) bool { return mfd.Name == name }) {
+		return fmt.Errorf("%w: unknown flag '%s'", ErrInvalidCommand, name)
+	}
+	if fn == nil {
+		delete(c.flagNormalizers, name)
+		return nil
+	}
+	if c.flagNormalizers == nil {
+		c.flagNormalizers = make(map[string]func(string) (string, error))
+	}
+	c.flagNormalizers[name] = fn
+	c.flags.normalizers = c.flagNormalizers
+	return nil
+}
+
+// AliasFlag registers an alternative long name for the given flag (by name, e.g. "new-name") of this command, which
+// behaves exactly like the flag itself - e.g. "--old-name" when renaming a flag. The alias also applies to sub-commands
+// inheriting the flag. Aliases are not shown on help screens, unless requested (see [Command.SetShowFlagAliases]). An
+// error is returned if the flag does not exist, or if the alias collides with an existing flag or alias.
+func (c *Command) AliasFlag(alias, canonical string) error {
+	if alias == "" || strings.HasPrefix(alias, "-") || strings.ContainsAny(alias, "= ") {
+		return fmt.Errorf("%w: invalid flag alias '%s'", ErrInvalidCommand, alias)
+	}
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		return err
+	} else if !slices.ContainsFunc(mergedFlagDefs, func(mfd *mergedFlagDef) bool { return mfd.Name == canonical }) {
+		return fmt.Errorf("%w: unknown flag '%s'", ErrInvalidCommand, canonical)
+	}
+	for _, mfd := range mergedFlagDefs {
+		if alias == mfd.Name || alias == defaultIfNil(mfd.Short, "") || (!mfd.HasValue && !mfd.Count && alias == mfd.getNegatedName()) {
+			return fmt.Errorf("%w: flag alias '%s' collides with flag '%s'", ErrInvalidCommand, alias, mfd.Name)
+		}
+	}
+	if name, ok := c.flags.getFlagAliases()[alias]; ok {
+		return fmt.Errorf("%w: flag alias '%s' is already an alias of flag '%s'", ErrInvalidCommand, alias, name)
+	}
+	if c.flagAliases == nil {
+		c.flagAliases = make(map[string]string)
+	}
+	c.flagAliases[alias] = canonical
+	c.flags.flagAliases = c.flagAliases
+	return nil
+}
+
+// SetDefault sets the default value of the given flag (by name, e.g. "name"), overriding the default value taken from
+// its field's initial value, which is useful when the default value depends on information only known at runtime. The
+// value is given in the same form as it would be given in the command line, and is verified to be valid for the flag.
+// The new default value also applies to sub-commands inheriting the flag, and is shown on help screens.
+func (c *Command) SetDefault(name, value string) error {
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(mergedFlagDefs, func(mfd *mergedFlagDef) bool { return mfd.Name == name })
+	if i < 0 {
+		return fmt.Errorf("%w: unknown flag '%s'", ErrInvalidCommand, name)
+	}
+
+	// Verify the value by setting it to throwaway targets of the same types as the flag's targets
+	for _, fd := range mergedFlagDefs[i].flagDefs {
+		for _, target := range fd.Targets {
+			if err := fd.setTargetValue(reflect.New(target.Type()).Elem(), value, false); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.defaultValues == nil {
+		c.defaultValues = make(map[string]string)
+	}
+	c.defaultValues[name] = value
+	c.flags.defaultValues = c.defaultValues
+	return nil
+}
+
+// SetPositionalSpec declares the names of the positional arguments this command accepts (e.g. "SRC" & "DST"), which are
+// shown in its usage line instead of the generic "[ARGS...]", and the minimum & maximum number of positional arguments
+// it accepts (a negative maximum means there is no maximum). Positional arguments beyond the minimum are shown as
+// optional, and the last name is shown as repeatable if more positional arguments than names are accepted.
+func (c *Command) SetPositionalSpec(names []string, min, max int) error {
+	if min < 0 {
+		return fmt.Errorf("%w: minimum number of positional arguments must not be negative", ErrInvalidCommand)
+	} else if max >= 0 && max < min {
+		return fmt.Errorf("%w: maximum number of positional arguments must not be lower than the minimum", ErrInvalidCommand)
+	} else if slices.Contains(names, "") {
+		return fmt.Errorf("%w: empty positional argument name", ErrInvalidCommand)
+	}
+	c.positionalsSpec = &positionalsSpec{names: slices.Clone(names), min: min, max: max}
+	c.flags.positionalsSpec = c.positionalsSpec
+	return nil
+}
+
+// verifyFlagGroup verifies that the given flag names form a valid flag group of the given kind for this command.
+func (c *Command) verifyFlagGroup(kind string, names []string) error {
+	if len(names) < 2 {
+		return fmt.Errorf("%w: at least two flags are required for a %s group", ErrInvalidCommand, kind)
+	}
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !slices.ContainsFunc(mergedFlagDefs, func(mfd *mergedFlagDef) bool { return mfd.Name == name }) {
+			return fmt.Errorf("%w: unknown flag '%s' in %s group", ErrInvalidCommand, name, kind)
+		}
+	}
+	return nil
+}
+
+// resetFlags recreates the flag-sets of this command and all of its sub-commands, recursively.
+func (c *Command) resetFlags() error {
+	if err := c.setParent(c.parent); err != nil {
+		return err
+	}
+	for _, subCmd := range c.subCommands {
+		if err := subCmd.resetFlags(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAliases sets alternative names this command can be invoked by (e.g. "rm" & "del" for a "remove" command). An
+// error is returned if any of the aliases is already used by another sub-command of this command's parent.
+func (c *Command) SetAliases(aliases ...string) error {
+	for _, alias := range aliases {
+		if alias == "" {
+			return fmt.Errorf("%w: empty alias", ErrInvalidCommand)
+		}
+	}
+	previousAliases := c.aliases
+	c.aliases = slices.Clone(aliases)
+	if c.parent != nil {
+		if err := c.parent.verifySubCommandAliases(c); err != nil {
+			c.aliases = previousAliases
+			return err
+		}
+	}
+	return nil
+}
+
+// getNames returns the name of this command, followed by its aliases.
+func (c *Command) getNames() []string {
+	return append([]string{c.name}, c.aliases...)
+}
+
+// verifySubCommandAliases verifies that the names & aliases of the given sub-command do not conflict with the names &
+// aliases of this command's other sub-commands (and vice versa).
+func (c *Command) verifySubCommandAliases(cmd *Command) error {
+	for _, sibling := range c.subCommands {
+		if sibling == cmd {
+			continue
+		}
+		if cmd.name == sibling.name {
+			return fmt.Errorf("%w: sub-command '%s' already exists", ErrInvalidCommand, cmd.name)
+		}
+		for _, name := range cmd.getNames() {
+			for _, siblingName := range sibling.getNames() {
+				if name == siblingName {
+					return fmt.Errorf("%w: name or alias '%s' of command '%s' is already used by command '%s'", ErrInvalidCommand, name, cmd.name, sibling.name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
 // AddSubCommand will add the given command as a sub-command of this command. An error is returned if the given command
-// already has another parent.
+// already has another parent, or if its name or aliases conflict with the names or aliases of other sub-commands.
 func (c *Command) AddSubCommand(cmd *Command) error {
 	if cmd.parent != nil {
 		return fmt.Errorf("%w: %s", ErrCommandAlreadyHasParent, cmd.parent.name)
+	} else if err := c.verifySubCommandAliases(cmd); err != nil {
+		return err
 	}
 	c.subCommands = append(c.subCommands, cmd)
-	if err := cmd.setParent(c); err != nil {
+	cmd.parent = c
+	if err := cmd.resetFlags(); err != nil {
+		return fmt.Errorf("failed setting parent for command '%s': %w", cmd.name, err)
+	}
+	return nil
+}
+
+// RemoveSubCommand removes the sub-command with the given name (or alias) from this command, making it a root command of
+// its own. If it was this command's default sub-command, the default is cleared. Returns false if this command has no
+// sub-command by that name. If the removed command cannot be detached, it is left in place and an error is returned.
+func (c *Command) RemoveSubCommand(name string) (bool, error) {
+	i := c.indexOfSubCommand(name)
+	if i < 0 {
+		return false, nil
+	}
+	removed := c.subCommands[i]
+	wasDefault := c.defaultSubCmd == removed
+	c.subCommands = slices.Delete(c.subCommands, i, i+1)
+	if wasDefault {
+		c.defaultSubCmd = nil
+	}
+	removed.parent = nil
+	if err := removed.resetFlags(); err != nil {
+		// Re-attach the command, restoring the flag sets of any of its sub-commands that were already detached
+		c.subCommands = slices.Insert(c.subCommands, i, removed)
+		if wasDefault {
+			c.defaultSubCmd = removed
+		}
+		removed.parent = c
+		_ = removed.resetFlags()
+		return false, fmt.Errorf("failed detaching command '%s': %w", removed.name, err)
+	}
+	return true, nil
+}
+
+// ReplaceSubCommand replaces the sub-command with the given name (or alias) with the given command, which takes its
+// place (and its role as default sub-command, if so). The replaced command becomes a root command of its own. An error
+// is returned if there's no sub-command by that name, if the given command already has another parent, or if its name
+// or aliases conflict with the names or aliases of the other sub-commands.
+func (c *Command) ReplaceSubCommand(name string, cmd *Command) error {
+	i := c.indexOfSubCommand(name)
+	if i < 0 {
+		return fmt.Errorf("%w: unknown sub-command '%s' of command '%s'", ErrInvalidCommand, name, c.name)
+	} else if cmd.parent != nil {
+		return fmt.Errorf("%w: %s", ErrCommandAlreadyHasParent, cmd.parent.name)
+	}
+
+	replaced := c.subCommands[i]
+	c.subCommands[i] = cmd
+	if err := c.verifySubCommandAliases(cmd); err != nil {
+		c.subCommands[i] = replaced
+		return err
+	}
+	cmd.parent = c
+	if err := cmd.resetFlags(); err != nil {
+		c.subCommands[i] = replaced
+		cmd.parent = nil
 		return fmt.Errorf("failed setting parent for command '%s': %w", cmd.name, err)
 	}
+	if c.defaultSubCmd == replaced {
+		c.defaultSubCmd = cmd
+	}
+	replaced.parent = nil
+	if err := replaced.resetFlags(); err != nil {
+		return fmt.Errorf("failed detaching command '%s': %w", replaced.name, err)
+	}
 	return nil
 }
 
+// Find returns the descendant command denoted by the given path of sub-command names (or aliases), starting from this
+// command; e.g. Find("remote", "add") returns the "add" sub-command of this command's "remote" sub-command. An empty
+// path returns this command. An error is returned for the first path segment that denotes no sub-command.
+func (c *Command) Find(path ...string) (*Command, error) {
+	current := c
+	for _, name := range path {
+		i := current.indexOfSubCommand(name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w: unknown sub-command '%s' of command '%s'", ErrInvalidCommand, name, current.getFullName())
+		}
+		current = current.subCommands[i]
+	}
+	return current, nil
+}
+
+// Walk traverses this command and all of its descendants (including hidden ones) depth-first, calling the given
+// function for each command (before its sub-commands) along with its depth relative to this command (which is 0). The
+// traversal stops at the first error returned by the function, which is then returned.
+func (c *Command) Walk(fn func(cmd *Command, depth int) error) error {
+	return c.walk(fn, 0)
+}
+
+func (c *Command) walk(fn func(cmd *Command, depth int) error, depth int) error {
+	if err := fn(c, depth); err != nil {
+		return err
+	}
+	for _, subCmd := range c.subCommands {
+		if err := subCmd.walk(fn, depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddSubCommandFunc adds a sub-command with the given name & short description, which is only built (using the given
+// function) when it's invoked - e.g. when it's selected by the command line, or when its help screen is requested. This
+// avoids building large command trees up front. Until it's built, the sub-command is listed in help screens (and seen
+// by generated documentation, shell completions & [Command.Walk]) by its name & short description only. Errors
+// returned by the function are returned when the sub-command is invoked.
+func (c *Command) AddSubCommandFunc(name, shortDescription string, build func() (*Command, error)) error {
+	if build == nil {
+		return fmt.Errorf("%w: nil build function for sub-command '%s'", ErrInvalidCommand, name)
+	}
+	placeholder, err := New(name, shortDescription, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	placeholder.build = build
+	return c.AddSubCommand(placeholder)
+}
+
+// materialize builds this lazily-built sub-command (see [Command.AddSubCommandFunc]), and replaces it with the built
+// command in its parent, returning the built command.
+func (c *Command) materialize() (*Command, error) {
+	built, err := c.build()
+	if err != nil {
+		return nil, fmt.Errorf("failed building sub-command '%s': %w", c.getFullName(), err)
+	} else if built == nil {
+		return nil, fmt.Errorf("%w: build function of sub-command '%s' returned nil", ErrInvalidCommand, c.getFullName())
+	} else if built.name != c.name {
+		return nil, fmt.Errorf("%w: build function of sub-command '%s' returned command '%s'", ErrInvalidCommand, c.getFullName(), built.name)
+	} else if err := c.parent.ReplaceSubCommand(c.name, built); err != nil {
+		return nil, fmt.Errorf("failed building sub-command '%s': %w", c.getFullName(), err)
+	}
+	return built, nil
+}
+
+// indexOfSubCommand returns the index of the sub-command with the given name (or alias), or -1 if there's none.
+func (c *Command) indexOfSubCommand(name string) int {
+	return slices.IndexFunc(c.subCommands, func(subCmd *Command) bool { return slices.Contains(subCmd.getNames(), name) })
+}
+
 // inferCommandAndArgs takes the given CLI arguments, and splits them into flags, positional arguments, but most
 // importantly, understands which command the user is trying to invoke. This is done by comparing given positional
 // arguments to the current command hierarchy, and removing positional arguments that denote sub-commands.
@@ -201,37 +1029,102 @@ func (c *Command) AddSubCommand(cmd *Command) error {
 //
 // And the command hierarchy is: cmd1 -> sub1 -> sub2 -> sub3
 //
+// Flags that take a value may also be given in the "--name value" form (or as the last short flag of a cluster, e.g.
+// "-vo value"), in which case the argument following them is considered as their value (and not as a positional
+// argument or a sub-command name), just like the stdlib flag set does when the flags are later applied.
+//
+// Commands whose flags may not be interspersed with positional arguments (see [Command.SetInterspersedFlags]) treat all
+// arguments after their first positional argument (up to the "--" separator) as positional arguments.
+//
 // The returned values would be:
 //   - flags: [-flag1, -flag2=1]: no "-flag3" because it's after the "--" separator
-//   - positionals: [something, sub3, a, b, c]: no "cmd1", "sub1" and "sub2" as they are commands in the hierarchy
+//   - positionals: [something]: no "cmd1", "sub1" and "sub2" as they are commands in the hierarchy
+//   - raw args: [sub3, -flag3, a, b, c]: everything after the "--" separator, as-is (nil if no separator was given)
 //   - command: sub2 (since it's the last valid command before the "--" which signals positional args only)
-func (c *Command) inferCommandAndArgs(args []string) (flags, positionals []string, current *Command) {
+//
+// Sub-commands added using [Command.AddSubCommandFunc] are built when selected, and errors in building them are
+// returned.
+func (c *Command) inferCommandAndArgs(args []string) (flags, positionals, rawArgs []string, current *Command, err error) {
 	current = c
-	onlyPositionalArgs := false
-	for _, arg := range args {
-		if onlyPositionalArgs {
-			positionals = append(positionals, arg)
-		} else if arg == "--" {
-			onlyPositionalArgs = true
+	flagsByName := current.flags.getFlagsByName()
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rawArgs = append([]string{}, args[i+1:]...)
+			break
 		} else if strings.HasPrefix(arg, "-") {
 			flags = append(flags, arg)
+			if flagArgs, needsValue := expandFlagArg(flagsByName, arg); needsValue && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			} else if i+1 < len(args) && consumesBoolValue(flagsByName, flagArgs[len(flagArgs)-1], args[i+1]) {
+				i++
+				flags = append(flags, args[i])
+			}
 		} else {
 			found := false
 			for _, subCmd := range current.subCommands {
-				if subCmd.name == arg {
+				if slices.Contains(subCmd.getNames(), arg) {
+					if subCmd.build != nil {
+						if subCmd, err = subCmd.materialize(); err != nil {
+							return nil, nil, nil, nil, err
+						}
+					}
 					current = subCmd
+					flagsByName = current.flags.getFlagsByName()
 					found = true
 					break
 				}
 			}
 			if !found {
 				positionals = append(positionals, arg)
+				if current.nonInterspersed {
+					// All arguments up to the "--" separator are positional arguments from now on
+					for i++; i < len(args) && args[i] != "--"; i++ {
+						positionals = append(positionals, args[i])
+					}
+					if i < len(args) {
+						rawArgs = append([]string{}, args[i+1:]...)
+					}
+					break
+				}
 			}
 		}
 	}
 	return
 }
 
+// invokePreRunHook invokes the given pre-run hook (either a [PreRunHookWithInfo] or a [PreRunHook]) for this command,
+// preferring the former if the hook implements both.
+func (c *Command) invokePreRunHook(ctx context.Context, h any, positionals []string) error {
+	switch hook := h.(type) {
+	case PreRunHookWithInfo:
+		return hook.PreRun(ctx, c, positionals)
+	case PreRunHook:
+		return hook.PreRun(ctx)
+	default:
+		return fmt.Errorf("%w: hook %T is not a pre-run hook", ErrInvalidCommand, h)
+	}
+}
+
+// getPreRunHooks returns the pre-run hooks of the whole chain of commands leading to this command, in invocation order:
+// first the persistent pre-run hooks (see [PersistentPreRunHook]) of all commands in the chain, and then the rest of
+// them; in both cases, starting at the root command.
+func (c *Command) getPreRunHooks() []any {
+	var hooks []any
+	chain := c.getChain()
+	for _, persistent := range []bool{true, false} {
+		for _, cmd := range chain {
+			for _, h := range cmd.preRunHooks {
+				if isPersistentPreRunHook(h) == persistent {
+					hooks = append(hooks, h)
+				}
+			}
+		}
+	}
+	return hooks
+}
+
 // getFullName returns the names of all commands in this command's hierarchy, starting from the root, all the way to
 // this command.
 //
@@ -261,6 +1154,12 @@ func (c *Command) getChain() []*Command {
 }
 
 func (c *Command) PrintHelp(w io.Writer, width int) error {
+	return c.printHelp(w, width, false)
+}
+
+// printHelp prints the help screen of this command, optionally colorizing section headers & flag names with ANSI escape
+// sequences.
+func (c *Command) printHelp(w io.Writer, width int, color bool) error {
 	ww, err := NewWrappingWriter(width)
 	if err != nil {
 		return err
@@ -284,7 +1183,7 @@ func (c *Command) PrintHelp(w io.Writer, width int) error {
 
 	// Long description if we have one
 	if c.longDescription != "" {
-		_, _ = fmt.Fprint(ww, "Description: ")
+		_, _ = fmt.Fprint(ww, colorize("Description:", ansiBold, color)+" ")
 		_ = ww.SetLinePrefix(prefix4)
 		_, _ = fmt.Fprintln(ww, c.longDescription)
 		_ = ww.SetLinePrefix("")
@@ -292,7 +1191,7 @@ func (c *Command) PrintHelp(w io.Writer, width int) error {
 	}
 
 	// Usage line
-	_, _ = fmt.Fprintln(ww, "Usage:")
+	_, _ = fmt.Fprintln(ww, colorize("Usage:", ansiBold, color))
 	_ = ww.SetLinePrefix(prefix4)
 	_, _ = fmt.Fprint(ww, fullName+" ")
 	_ = ww.SetLinePrefix(prefix8)
@@ -304,35 +1203,42 @@ func (c *Command) PrintHelp(w io.Writer, width int) error {
 	_, _ = fmt.Fprintln(ww)
 
 	// Flags
+	// Flags without a group are listed under "Flags:", and grouped flags are listed under their group's name
 	if c.flags.hasFlags() {
-		_, _ = fmt.Fprintln(ww, "Flags:")
-		_ = ww.SetLinePrefix(prefix4)
-		if err := c.flags.printFlagsMultiLine(ww, prefix4); err != nil {
+		groups, err := c.flags.getFlagGroups()
+		if err != nil {
 			return err
 		}
-		_ = ww.SetLinePrefix("")
-		_, _ = fmt.Fprintln(ww)
+		for _, group := range groups {
+			header := "Flags:"
+			if group != "" {
+				header = group + ":"
+			}
+			_, _ = fmt.Fprintln(ww, colorize(header, ansiBold, color))
+			_ = ww.SetLinePrefix(prefix4)
+			if err := c.flags.printFlagsMultiLine(ww, prefix4, group, color); err != nil {
+				return err
+			}
+			_ = ww.SetLinePrefix("")
+			_, _ = fmt.Fprintln(ww)
+		}
 	}
 
-	// Sub-commands
-	if len(c.subCommands) > 0 {
-		_, _ = fmt.Fprintln(ww, "Available sub-commands:")
+	// Sub-commands (excluding hidden ones)
+	if subCommands := c.getVisibleSubCommands(); len(subCommands) > 0 {
+		_, _ = fmt.Fprintln(ww, colorize("Available sub-commands:", ansiBold, color))
 
-		lenOfLongestSubCommand := 0
-		for _, subCmd := range c.subCommands {
-			if len(subCmd.name) > lenOfLongestSubCommand {
-				lenOfLongestSubCommand = len(subCmd.name)
+		subCommandLabels, subCommandDescriptionCol := getSubCommandLabels(subCommands)
+		for _, subCmd := range subCommands {
+			label := subCommandLabels[subCmd]
+			_ = ww.SetLinePrefixes(prefix4, strings.Repeat(" ", len(prefix4)+subCommandDescriptionCol))
+			_, _ = fmt.Fprint(ww, label)
+			_, _ = fmt.Fprint(ww, strings.Repeat(" ", subCommandDescriptionCol-len(label)))
+			_, _ = fmt.Fprint(ww, subCmd.shortDescription)
+			if subCmd.deprecated != "" {
+				_, _ = fmt.Fprintf(ww, " (DEPRECATED: %s)", subCmd.deprecated)
 			}
-		}
-		subCommandNameDescSpacing := 10 - lenOfLongestSubCommand%10
-		subCommandDescriptionCol := lenOfLongestSubCommand + subCommandNameDescSpacing
-
-		for _, subCmd := range c.subCommands {
-			_ = ww.SetLinePrefix(prefix4)
-			_, _ = fmt.Fprint(ww, subCmd.name)
-			_, _ = fmt.Fprint(ww, strings.Repeat(" ", subCommandDescriptionCol-len(subCmd.name)))
-			_ = ww.SetLinePrefix(strings.Repeat(" ", len(prefix4)+subCommandDescriptionCol))
-			_, _ = fmt.Fprintln(ww, subCmd.shortDescription)
+			_, _ = fmt.Fprintln(ww)
 		}
 		_, _ = fmt.Fprintln(ww)
 
@@ -344,6 +1250,78 @@ func (c *Command) PrintHelp(w io.Writer, width int) error {
 	return nil
 }
 
+// PrintHelpCompact prints a compact help screen of this command, fitting commands with many sub-commands: flags are
+// listed in a single (wrapped) usage line, and each sub-command is listed in a single line, with its short description
+// truncated to fit the given width.
+func (c *Command) PrintHelpCompact(w io.Writer, width int) error {
+	ww, err := NewWrappingWriter(width)
+	if err != nil {
+		return err
+	}
+
+	prefix4 := strings.Repeat(" ", 4)
+	prefix8 := strings.Repeat(" ", 8)
+	fullName := c.getFullName()
+
+	// Command name & short description
+	if c.shortDescription != "" {
+		_, _ = fmt.Fprint(ww, fullName+": ")
+		_ = ww.SetLinePrefix(prefix4)
+		_, _ = fmt.Fprintln(ww, c.shortDescription)
+		_ = ww.SetLinePrefix("")
+	} else {
+		_, _ = fmt.Fprintln(ww, fullName)
+	}
+	_, _ = fmt.Fprintln(ww)
+
+	// Usage line, including all flags
+	_, _ = fmt.Fprintln(ww, "Usage:")
+	_ = ww.SetLinePrefixes(prefix4, prefix8)
+	_, _ = fmt.Fprint(ww, fullName+" ")
+	if err := c.flags.printFlagsSingleLine(ww); err != nil {
+		return err
+	}
+	_ = ww.SetLinePrefix("")
+	_, _ = fmt.Fprintln(ww)
+
+	// Sub-commands (excluding hidden ones), one per line
+	if subCommands := c.getVisibleSubCommands(); len(subCommands) > 0 {
+		_, _ = fmt.Fprintln(ww)
+		_, _ = fmt.Fprintln(ww, "Available sub-commands:")
+		subCommandLabels, subCommandDescriptionCol := getSubCommandLabels(subCommands)
+		for _, subCmd := range subCommands {
+			label := subCommandLabels[subCmd]
+			desc := subCmd.shortDescription
+			if subCmd.deprecated != "" {
+				desc += fmt.Sprintf(" (DEPRECATED: %s)", subCmd.deprecated)
+			}
+			_, _ = fmt.Fprint(ww, prefix4+label+strings.Repeat(" ", subCommandDescriptionCol-len(label)))
+			_, _ = fmt.Fprintln(ww, Truncate(desc, width-len(prefix4)-subCommandDescriptionCol))
+		}
+	}
+
+	if _, err = w.Write([]byte(ww.String())); err != nil {
+		return err
+	}
+	return nil
+}
+
+// getSubCommandLabels returns the labels of the given sub-commands (their names along with their aliases, e.g.
+// "remove, rm, del"), and the column at which their descriptions should start, so they are aligned.
+func getSubCommandLabels(subCommands []*Command) (map[*Command]string, int) {
+	lenOfLongestSubCommand := 0
+	subCommandLabels := make(map[*Command]string)
+	for _, subCmd := range subCommands {
+		label := strings.Join(subCmd.getNames(), ", ")
+		subCommandLabels[subCmd] = label
+		if len(label) > lenOfLongestSubCommand {
+			lenOfLongestSubCommand = len(label)
+		}
+	}
+	subCommandNameDescSpacing := 10 - lenOfLongestSubCommand%10
+	return subCommandLabels, lenOfLongestSubCommand + subCommandNameDescSpacing
+}
+
 func (c *Command) PrintUsageLine(w io.Writer, width int) error {
 	ww, err := NewWrappingWriter(width)
 	if err != nil {
@@ -2,9 +2,14 @@ package command
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	. "github.com/arikkfir/justest"
 	"github.com/go-loremipsum/loremipsum"
@@ -107,6 +112,7 @@ func TestNew(t *testing.T) {
 						Will(EqualTo(
 							tc.expectedFlagSet.flags,
 							cmpopts.IgnoreFields(flagDef{}, "Targets"),
+							cmpopts.IgnoreFields(flagDef{}, "index"),
 							cmp.AllowUnexported(flagDef{})),
 						).
 						OrFail()
@@ -135,6 +141,396 @@ func TestAddSubCommand(t *testing.T) {
 	With(t).Verify(sub2.parent).Will(EqualTo(root, cmpopts.EquateComparable(&Command{}))).OrFail()
 }
 
+func TestSetFlagSortMode(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		rootMode      *FlagSortMode
+		subMode       *FlagSortMode
+		expectedUsage string
+	}
+	testCases := map[string]testCase{
+		"alphabetical by default": {
+			expectedUsage: "Usage: root sub [--alpha=VALUE] [-h, --help] [--mike=VALUE] [--zulu=VALUE]\n",
+		},
+		"declaration order inherited from root": {
+			rootMode:      ptrOf(SortDeclaration),
+			expectedUsage: "Usage: root sub [--zulu=VALUE] [--mike=VALUE] [--alpha=VALUE] [-h, --help]\n",
+		},
+		"sub-command overrides root": {
+			rootMode:      ptrOf(SortDeclaration),
+			subMode:       ptrOf(SortAlphabetical),
+			expectedUsage: "Usage: root sub [--alpha=VALUE] [-h, --help] [--mike=VALUE] [--zulu=VALUE]\n",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			sub := MustNew("sub", "desc", "", &struct {
+				Action
+				Zulu string `flag:"true"`
+				Mike string `flag:"true"`
+			}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+			root := MustNew("root", "desc", "", &struct {
+				Action
+				Alpha string `inherited:"true"`
+			}{Action: ActionFunc(func(context.Context) error { return nil })}, nil, sub)
+			if tc.rootMode != nil {
+				root.SetFlagSortMode(*tc.rootMode)
+			}
+			if tc.subMode != nil {
+				sub.SetFlagSortMode(*tc.subMode)
+			}
+
+			b := &bytes.Buffer{}
+			With(t).Verify(sub.PrintUsageLine(b, 200)).Will(Succeed()).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedUsage)).OrFail()
+		})
+	}
+}
+
+func TestSetAliases(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		siblings      []string
+		aliases       []string
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"no conflicts": {
+			siblings: []string{"add"},
+			aliases:  []string{"rm", "del"},
+		},
+		"empty alias": {
+			aliases:       []string{"rm", ""},
+			expectedError: `^invalid command: empty alias$`,
+		},
+		"alias conflicts with sibling name": {
+			siblings:      []string{"add"},
+			aliases:       []string{"rm", "add"},
+			expectedError: `^invalid command: name or alias 'add' of command 'remove' is already used by command 'add'$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			root := MustNew("root", "desc", "", nil, nil)
+			for _, sibling := range tc.siblings {
+				With(t).Verify(root.AddSubCommand(MustNew(sibling, "desc", "", nil, nil))).Will(Succeed()).OrFail()
+			}
+			remove := MustNew("remove", "desc", "", nil, nil)
+			With(t).Verify(root.AddSubCommand(remove)).Will(Succeed()).OrFail()
+			if tc.expectedError != "" {
+				With(t).Verify(remove.SetAliases(tc.aliases...)).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(remove.SetAliases(tc.aliases...)).Will(Succeed()).OrFail()
+				With(t).Verify(remove.getNames()).Will(EqualTo(append([]string{"remove"}, tc.aliases...))).OrFail()
+			}
+		})
+	}
+}
+
+func TestAddSubCommandWithConflictingAlias(t *testing.T) {
+	t.Parallel()
+	root := MustNew("root", "desc", "", nil, nil, MustNew("rm", "desc", "", nil, nil))
+	remove := MustNew("remove", "desc", "", nil, nil)
+	With(t).Verify(remove.SetAliases("rm")).Will(Succeed()).OrFail()
+	With(t).Verify(root.AddSubCommand(remove)).Will(Fail(`^invalid command: name or alias 'rm' of command 'remove' is already used by command 'rm'$`)).OrFail()
+}
+
+func TestAddSubCommandWithDuplicateName(t *testing.T) {
+	t.Parallel()
+	existing := MustNew("sub", "desc", "", nil, nil)
+	root := MustNew("root", "desc", "", nil, nil, existing)
+	duplicate := MustNew("sub", "other desc", "", nil, nil)
+	err := root.AddSubCommand(duplicate)
+	With(t).Verify(err).Will(Fail(`^invalid command: sub-command 'sub' already exists$`)).OrFail()
+	With(t).Verify(errors.Is(err, ErrInvalidCommand)).Will(EqualTo(true)).OrFail()
+	With(t).Verify(len(root.subCommands)).Will(EqualTo(1)).OrFail()
+	With(t).Verify(root.subCommands[0] == existing).Will(EqualTo(true)).OrFail()
+	With(t).Verify(duplicate.parent).Will(BeNil()).OrFail()
+}
+
+func TestNewWithDuplicateSubCommands(t *testing.T) {
+	t.Parallel()
+	_, err := New("root", "desc", "", nil, nil, MustNew("sub", "desc", "", nil, nil), MustNew("sub", "desc", "", nil, nil))
+	With(t).Verify(err).Will(Fail(`^invalid command: failed adding sub-command 'sub' to 'root': invalid command: sub-command 'sub' already exists$`)).OrFail()
+}
+
+func TestRemoveSubCommand(t *testing.T) {
+	t.Parallel()
+	sub1 := MustNew("sub1", "desc", "", nil, nil)
+	sub2 := MustNew("sub2", "desc", "", nil, nil)
+	With(t).Verify(sub2.SetAliases("s2")).Will(Succeed()).OrFail()
+	root := MustNew("root", "desc", "", nil, nil, sub1, sub2)
+	With(t).Verify(root.SetDefaultSubCommand("sub2")).Will(Succeed()).OrFail()
+
+	removed, err := root.RemoveSubCommand("unknown")
+	With(t).Verify(err).Will(Succeed()).OrFail()
+	With(t).Verify(removed).Will(EqualTo(false)).OrFail()
+	removed, err = root.RemoveSubCommand("s2")
+	With(t).Verify(err).Will(Succeed()).OrFail()
+	With(t).Verify(removed).Will(EqualTo(true)).OrFail()
+	With(t).Verify(len(root.subCommands)).Will(EqualTo(1)).OrFail()
+	With(t).Verify(root.subCommands[0] == sub1).Will(EqualTo(true)).OrFail()
+	With(t).Verify(root.defaultSubCmd).Will(BeNil()).OrFail()
+	With(t).Verify(sub2.parent).Will(BeNil()).OrFail()
+	With(t).Verify(sub2.flags.parent.parent).Will(BeNil()).OrFail()
+
+	// Removed command can be added elsewhere
+	With(t).Verify(MustNew("other", "desc", "", nil, nil).AddSubCommand(sub2)).Will(Succeed()).OrFail()
+}
+
+func TestRemoveSubCommandDetachFailure(t *testing.T) {
+	t.Parallel()
+	leaf := MustNew("leaf", "desc", "", nil, nil)
+	sub := MustNew("sub", "desc", "", nil, nil, leaf)
+	root := MustNew("root", "desc", "", nil, nil, MustNew("other", "desc", "", nil, nil), sub)
+	With(t).Verify(root.SetDefaultSubCommand("sub")).Will(Succeed()).OrFail()
+	leafFlags := leaf.flags
+
+	// Make the flag-set of the nested command fail to be recreated once its ancestor is detached
+	leaf.action = &struct {
+		Action
+		Name string `flag:"true" short:"ab"`
+	}{Action: ActionFunc(func(context.Context) error { return nil })}
+
+	removed, err := root.RemoveSubCommand("sub")
+	With(t).Verify(err).Will(Fail(`^failed detaching command 'sub': .*must be a single character`)).OrFail()
+	With(t).Verify(removed).Will(EqualTo(false)).OrFail()
+	With(t).Verify(len(root.subCommands)).Will(EqualTo(2)).OrFail()
+	With(t).Verify(root.subCommands[1] == sub).Will(EqualTo(true)).OrFail()
+	With(t).Verify(root.defaultSubCmd == sub).Will(EqualTo(true)).OrFail()
+	With(t).Verify(sub.parent == root).Will(EqualTo(true)).OrFail()
+	With(t).Verify(sub.flags.parent == root.flags).Will(EqualTo(true)).OrFail()
+	With(t).Verify(leaf.flags == leafFlags).Will(EqualTo(true)).OrFail()
+}
+
+func TestReplaceSubCommand(t *testing.T) {
+	t.Parallel()
+	type rootConfig struct {
+		Action
+		Name string `name:"name" inherited:"true"`
+	}
+	var names []string
+	newLeaf := func(name string) *Command {
+		return MustNew(name, "desc", "", &struct{ Action }{Action: ActionFunc(func(context.Context) error {
+			names = append(names, name)
+			return nil
+		})}, nil)
+	}
+
+	old := MustNew("old", "desc", "", nil, nil, newLeaf("leaf"))
+	root := MustNew("root", "desc", "", &rootConfig{Action: ActionFunc(func(context.Context) error { return nil })}, nil, old, MustNew("other", "desc", "", nil, nil))
+	With(t).Verify(root.SetDefaultSubCommand("old")).Will(Succeed()).OrFail()
+
+	With(t).Verify(root.ReplaceSubCommand("unknown", MustNew("new", "desc", "", nil, nil))).Will(Fail(`^invalid command: unknown sub-command 'unknown' of command 'root'$`)).OrFail()
+	With(t).Verify(root.ReplaceSubCommand("old", old.subCommands[0])).Will(Fail(`^command already has a parent: old$`)).OrFail()
+	With(t).Verify(root.ReplaceSubCommand("old", MustNew("other", "desc", "", nil, nil))).Will(Fail(`^invalid command: sub-command 'other' already exists$`)).OrFail()
+	With(t).Verify(root.subCommands[0] == old).Will(EqualTo(true)).OrFail()
+	With(t).Verify(old.parent == root).Will(EqualTo(true)).OrFail()
+
+	replacement := MustNew("new", "desc", "", nil, nil, newLeaf("new-leaf"))
+	With(t).Verify(root.ReplaceSubCommand("old", replacement)).Will(Succeed()).OrFail()
+	With(t).Verify(root.subCommands[0] == replacement).Will(EqualTo(true)).OrFail()
+	With(t).Verify(root.defaultSubCmd == replacement).Will(EqualTo(true)).OrFail()
+	With(t).Verify(replacement.parent == root).Will(EqualTo(true)).OrFail()
+	With(t).Verify(old.parent).Will(BeNil()).OrFail()
+
+	// Inherited flags of the root are available to the replacement's sub-commands
+	b := &bytes.Buffer{}
+	With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"new", "new-leaf", "--name=x"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+	With(t).Verify(names).Will(EqualTo([]string{"new-leaf"})).OrFail()
+}
+
+func TestAddSubCommandFunc(t *testing.T) {
+	t.Parallel()
+	type lazyConfig struct {
+		Action
+		Name string `desc:"Name to use."`
+	}
+	newRoot := func(t T, builds *int, buildErr error) (*Command, *lazyConfig) {
+		cfg := &lazyConfig{Action: ActionFunc(func(context.Context) error { return nil })}
+		root := MustNew("root", "desc", "", nil, nil, MustNew("eager", "Eager command", "", nil, nil))
+		With(t).Verify(root.AddSubCommandFunc("lazy", "Lazy command", func() (*Command, error) {
+			*builds++
+			if buildErr != nil {
+				return nil, buildErr
+			}
+			return New("lazy", "Lazy command", "Lazy command description.", cfg, nil)
+		})).Will(Succeed()).OrFail()
+		return root, cfg
+	}
+
+	t.Run("not built when listed in help", func(t *testing.T) {
+		t.Parallel()
+		builds := 0
+		root, _ := newRoot(t, &builds, nil)
+		b := &bytes.Buffer{}
+		With(t).Verify(root.PrintHelp(b, 80)).Will(Succeed()).OrFail()
+		With(t).Verify(b).Will(Say(`(?m)^    lazy      Lazy command$`)).OrFail()
+		With(t).Verify(builds).Will(EqualTo(0)).OrFail()
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"eager"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(builds).Will(EqualTo(0)).OrFail()
+	})
+
+	t.Run("built once when selected", func(t *testing.T) {
+		t.Parallel()
+		builds := 0
+		root, cfg := newRoot(t, &builds, nil)
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"lazy", "--name=abc"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(cfg.Name).Will(EqualTo("abc")).OrFail()
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"lazy"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(builds).Will(EqualTo(1)).OrFail()
+		lazy, err := root.Find("lazy")
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(lazy.action == Action(cfg)).Will(EqualTo(true)).OrFail()
+	})
+
+	t.Run("built when its help is requested", func(t *testing.T) {
+		t.Parallel()
+		builds := 0
+		root, _ := newRoot(t, &builds, nil)
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"lazy", "--help"}, nil, WithWidth(80))).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b).Will(Say(`(?s)^root lazy: Lazy command\n\nDescription: Lazy command description\..*--name=VALUE`)).OrFail()
+		With(t).Verify(builds).Will(EqualTo(1)).OrFail()
+	})
+
+	t.Run("built when it's the default sub-command", func(t *testing.T) {
+		t.Parallel()
+		builds := 0
+		root, cfg := newRoot(t, &builds, nil)
+		With(t).Verify(root.SetDefaultSubCommand("lazy")).Will(Succeed()).OrFail()
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"--name=abc"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(cfg.Name).Will(EqualTo("abc")).OrFail()
+		With(t).Verify(builds).Will(EqualTo(1)).OrFail()
+	})
+
+	t.Run("build errors are returned when selected", func(t *testing.T) {
+		t.Parallel()
+		builds := 0
+		root, _ := newRoot(t, &builds, errors.New("boom"))
+		b := &bytes.Buffer{}
+		exitCode, err := ExecuteE(context.Background(), b, root, []string{"lazy"}, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeError)).OrFail()
+		With(t).Verify(err).Will(Fail(`^failed building sub-command 'root lazy': boom$`)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("failed building sub-command 'root lazy': boom\n")).OrFail()
+	})
+
+	t.Run("built command must have the registered name", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("root", "desc", "", nil, nil)
+		With(t).Verify(root.AddSubCommandFunc("lazy", "Lazy command", func() (*Command, error) {
+			return New("other", "Other command", "", nil, nil)
+		})).Will(Succeed()).OrFail()
+		_, err := root.Resolve([]string{"lazy"}, nil)
+		With(t).Verify(err).Will(Fail(`^invalid command: build function of sub-command 'root lazy' returned command 'other'$`)).OrFail()
+	})
+
+	t.Run("name must not conflict with other sub-commands", func(t *testing.T) {
+		t.Parallel()
+		builds := 0
+		root, _ := newRoot(t, &builds, nil)
+		With(t).Verify(root.AddSubCommandFunc("eager", "Eager command", func() (*Command, error) { return nil, nil })).Will(Fail(`^invalid command: sub-command 'eager' already exists$`)).OrFail()
+		With(t).Verify(root.AddSubCommandFunc("other", "Other command", nil)).Will(Fail(`^invalid command: nil build function for sub-command 'other'$`)).OrFail()
+	})
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+	add := MustNew("add", "desc", "", nil, nil)
+	remote := MustNew("remote", "desc", "", nil, nil, add)
+	With(t).Verify(add.SetAliases("a")).Will(Succeed()).OrFail()
+	root := MustNew("root", "desc", "", nil, nil, remote)
+
+	type testCase struct {
+		path          []string
+		expected      *Command
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"empty path":         {path: nil, expected: root},
+		"direct sub-command": {path: []string{"remote"}, expected: remote},
+		"nested sub-command": {path: []string{"remote", "add"}, expected: add},
+		"alias":              {path: []string{"remote", "a"}, expected: add},
+		"missing first":      {path: []string{"unknown", "add"}, expectedError: `^invalid command: unknown sub-command 'unknown' of command 'root'$`},
+		"missing nested":     {path: []string{"remote", "rm"}, expectedError: `^invalid command: unknown sub-command 'rm' of command 'root remote'$`},
+		"path beyond leaf":   {path: []string{"remote", "add", "x"}, expectedError: `^invalid command: unknown sub-command 'x' of command 'root remote add'$`},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cmd, err := root.Find(tc.path...)
+			if tc.expectedError != "" {
+				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+				With(t).Verify(cmd).Will(BeNil()).OrFail()
+			} else {
+				With(t).Verify(err).Will(BeNil()).OrFail()
+				With(t).Verify(cmd == tc.expected).Will(EqualTo(true)).OrFail()
+			}
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+	hidden := MustNew("hidden", "desc", "", nil, nil)
+	hidden.SetHidden(true)
+	root := MustNew("root", "desc", "", nil, nil,
+		MustNew("remote", "desc", "", nil, nil,
+			MustNew("add", "desc", "", nil, nil),
+			MustNew("remove", "desc", "", nil, nil),
+		),
+		hidden,
+		MustNew("status", "desc", "", nil, nil),
+	)
+
+	t.Run("visits all commands depth-first", func(t *testing.T) {
+		t.Parallel()
+		var visited []string
+		With(t).Verify(root.Walk(func(cmd *Command, depth int) error {
+			visited = append(visited, fmt.Sprintf("%d:%s", depth, cmd.getFullName()))
+			return nil
+		})).Will(Succeed()).OrFail()
+		With(t).Verify(visited).Will(EqualTo([]string{
+			"0:root",
+			"1:root remote",
+			"2:root remote add",
+			"2:root remote remove",
+			"1:root hidden",
+			"1:root status",
+		})).OrFail()
+	})
+
+	t.Run("depth is relative to receiver", func(t *testing.T) {
+		t.Parallel()
+		remote, err := root.Find("remote")
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		var visited []string
+		With(t).Verify(remote.Walk(func(cmd *Command, depth int) error {
+			visited = append(visited, fmt.Sprintf("%d:%s", depth, cmd.name))
+			return nil
+		})).Will(Succeed()).OrFail()
+		With(t).Verify(visited).Will(EqualTo([]string{"0:remote", "1:add", "1:remove"})).OrFail()
+	})
+
+	t.Run("stops at first error", func(t *testing.T) {
+		t.Parallel()
+		var visited []string
+		With(t).Verify(root.Walk(func(cmd *Command, depth int) error {
+			visited = append(visited, cmd.name)
+			if cmd.name == "add" {
+				return errors.New("stop")
+			}
+			return nil
+		})).Will(Fail(`^stop$`)).OrFail()
+		With(t).Verify(visited).Will(EqualTo([]string{"root", "remote", "add"})).OrFail()
+	})
+}
+
 func Test_inferCommandAndArgs(t *testing.T) {
 	type testCase struct {
 		root                *Command
@@ -142,6 +538,7 @@ func Test_inferCommandAndArgs(t *testing.T) {
 		expectedCommand     string
 		expectedFlags       []string
 		expectedPositionals []string
+		expectedRawArgs     []string
 	}
 	testCases := map[string]testCase{
 		"No arguments": {
@@ -158,6 +555,32 @@ func Test_inferCommandAndArgs(t *testing.T) {
 			expectedFlags:       nil,
 			expectedPositionals: nil,
 		},
+		"Flags not interspersed with positionals": {
+			root: func() *Command {
+				sub1 := MustNew("sub1", "sub1 desc", "sub1 description", nil, nil,
+					MustNew("sub2", "sub2 desc", "sub2 description", nil, nil),
+				)
+				sub1.SetInterspersedFlags(false)
+				return MustNew("root", "desc", "description", nil, nil, sub1)
+			}(),
+			args:                strings.Split("-f1 sub1 -f2 a -f3 sub2 -- b", " "),
+			expectedCommand:     "sub1",
+			expectedFlags:       []string{"-f1", "-f2"},
+			expectedPositionals: []string{"a", "-f3", "sub2"},
+			expectedRawArgs:     []string{"b"},
+		},
+		"Explicit boolean flag values": {
+			root: MustNew("root", "desc", "description", &struct {
+				Action
+				Verbose bool   `short:"v"`
+				Count   int    `count:"true" short:"c"`
+				Name    string `short:"n"`
+			}{}, nil),
+			args:                strings.Split("--verbose false -v true -c true --name true --verbose=true false -vc false --no-verbose false --verbose yes", " "),
+			expectedCommand:     "root",
+			expectedFlags:       []string{"--verbose", "false", "-v", "true", "-c", "--name", "true", "--verbose=true", "-vc", "--no-verbose", "false", "--verbose"},
+			expectedPositionals: []string{"true", "false", "false", "yes"},
+		},
 		"Flags for root command": {
 			root: MustNew(
 				"root", "desc", "description", nil, nil,
@@ -206,13 +629,79 @@ func Test_inferCommandAndArgs(t *testing.T) {
 			expectedFlags:       []string{"-f1", "-f2"},
 			expectedPositionals: []string{"a", "b", "c"},
 		},
+		"Flags and positionals for sub1 command invoked by alias": {
+			root: func() *Command {
+				sub1 := MustNew("sub1", "sub1 desc", "sub1 description", nil, nil)
+				if err := sub1.SetAliases("s1", "first"); err != nil {
+					panic(err)
+				}
+				return MustNew("root", "desc", "description", nil, nil, sub1)
+			}(),
+			args:                strings.Split("-f1 first -f2 a", " "),
+			expectedCommand:     "sub1",
+			expectedFlags:       []string{"-f1", "-f2"},
+			expectedPositionals: []string{"a"},
+		},
+		"Values of flags given as separate arguments": {
+			root: MustNew(
+				"root", "desc", "description", &struct {
+					Action
+					Output string `flag:"true" short:"o" inherited:"true"`
+					Quiet  bool   `flag:"true"`
+				}{Action: ActionFunc(func(context.Context) error { return nil })}, nil,
+				MustNew("sub1", "sub1 desc", "sub1 description", nil, nil,
+					MustNew("sub2", "sub2 desc", "sub2 description", nil, nil),
+				),
+			),
+			args:                strings.Split("--output sub1 --quiet sub1 -o sub2 --output=a b", " "),
+			expectedCommand:     "sub1",
+			expectedFlags:       []string{"--output", "sub1", "--quiet", "-o", "sub2", "--output=a"},
+			expectedPositionals: []string{"b"},
+		},
+		"Values of short flag clusters given as separate arguments": {
+			root: MustNew(
+				"root", "desc", "description", &struct {
+					Action
+					Output string `flag:"true" short:"o"`
+					Quiet  bool   `flag:"true" short:"q"`
+				}{Action: ActionFunc(func(context.Context) error { return nil })}, nil,
+				MustNew("sub1", "sub1 desc", "sub1 description", nil, nil),
+			),
+			args:                strings.Split("-qo sub1 -oq sub1", " "),
+			expectedCommand:     "sub1",
+			expectedFlags:       []string{"-qo", "sub1", "-oq"},
+			expectedPositionals: nil,
+		},
+		"Raw arguments after separator": {
+			root: MustNew(
+				"root", "desc", "description", nil, nil,
+				MustNew("sub1", "sub1 desc", "sub1 description", nil, nil,
+					MustNew("sub2", "sub2 desc", "sub2 description", nil, nil),
+				),
+			),
+			args:                strings.Split("-f1 sub1 a -- sub2 -f2 b", " "),
+			expectedCommand:     "sub1",
+			expectedFlags:       []string{"-f1"},
+			expectedPositionals: []string{"a"},
+			expectedRawArgs:     []string{"sub2", "-f2", "b"},
+		},
+		"Empty raw arguments after separator": {
+			root:                MustNew("root", "desc", "description", nil, nil),
+			args:                strings.Split("-f1 a --", " "),
+			expectedCommand:     "root",
+			expectedFlags:       []string{"-f1"},
+			expectedPositionals: []string{"a"},
+			expectedRawArgs:     []string{},
+		},
 	}
 	for name, tc := range testCases {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
-			flags, positionals, cmd := tc.root.inferCommandAndArgs(tc.args)
+			flags, positionals, rawArgs, cmd, err := tc.root.inferCommandAndArgs(tc.args)
+			With(t).Verify(err).Will(BeNil()).OrFail()
 			With(t).Verify(flags).Will(EqualTo(tc.expectedFlags)).OrFail()
 			With(t).Verify(positionals).Will(EqualTo(tc.expectedPositionals)).OrFail()
+			With(t).Verify(rawArgs).Will(EqualTo(tc.expectedRawArgs)).OrFail()
 			With(t).Verify(cmd.name).Will(EqualTo(tc.expectedCommand)).OrFail()
 		})
 	}
@@ -307,7 +796,7 @@ func TestPrintHelp(t *testing.T) {
 				return MustNew("cmd", ligen.Sentence(), ligen.Sentences(2), nil, nil)
 			},
 			expectedHelpUsageOutput: `
-Usage: cmd [--help]
+Usage: cmd [-h, --help]
 `,
 			expectedHelpOutput: `
 cmd: Lorem ipsum dolor sit amet consectetur 
@@ -326,12 +815,12 @@ Description: Lorem ipsum dolor sit amet
     volutpat curae quis lectus.
 
 Usage:
-    cmd [--help]
+    cmd [-h, --help]
 
 Flags:
-    [--help]  Show this help screen and exit. 
-              (default value: false, environment 
-              variable: HELP)
+    [-h, --help]  Show this help screen and exit. 
+                  (default value: false, 
+                  environment variable: HELP)
 
 `,
 		},
@@ -351,7 +840,7 @@ Flags:
 				)
 			},
 			expectedHelpUsageOutput: `
-Usage: cmd [--help] 
+Usage: cmd [-h, --help] 
     [--my-flag=VALUE] 
     [ARGS...]
 `,
@@ -372,16 +861,16 @@ Description: Lorem ipsum dolor sit amet
     volutpat curae quis lectus.
 
 Usage:
-    cmd [--help] [--my-flag=VALUE] [ARGS...]
+    cmd [-h, --help] [--my-flag=VALUE] [ARGS...]
 
 Flags:
-    [--help]            Show this help screen and 
-                        exit. (default value: 
-                        false, environment 
-                        variable: HELP)
-    [--my-flag=VALUE]   flag description 
-                        (environment variable: 
-                        MY_FLAG)
+    [-h, --help]       Show this help screen and 
+                       exit. (default value: 
+                       false, environment 
+                       variable: HELP)
+    [--my-flag=VALUE]  flag description 
+                       (environment variable: 
+                       MY_FLAG)
 
 `,
 		},
@@ -412,7 +901,7 @@ Flags:
 				)
 			},
 			expectedHelpUsageOutput: `
-Usage: cmd [--help] 
+Usage: cmd [-h, --help] 
     [--my-flag=VALUE] 
     [ARGS...]
 `,
@@ -433,16 +922,16 @@ Description: Lorem ipsum dolor sit amet
     volutpat curae quis lectus.
 
 Usage:
-    cmd [--help] [--my-flag=VALUE] [ARGS...]
+    cmd [-h, --help] [--my-flag=VALUE] [ARGS...]
 
 Flags:
-    [--help]            Show this help screen and 
-                        exit. (default value: 
-                        false, environment 
-                        variable: HELP)
-    [--my-flag=VALUE]   flag description 
-                        (environment variable: 
-                        MY_FLAG)
+    [-h, --help]       Show this help screen and 
+                       exit. (default value: 
+                       false, environment 
+                       variable: HELP)
+    [--my-flag=VALUE]  flag description 
+                       (environment variable: 
+                       MY_FLAG)
 
 Available sub-commands:
     child1    Et dolor viverra nulla ipsum 
@@ -452,6 +941,125 @@ Available sub-commands:
               consequat pharetra convallis 
               bibendum rhoncus etiam.
 
+`,
+		},
+		"with hidden sub-commands": {
+			commandFactory: func(*testCase) *Command {
+				hidden := MustNew("very-long-hidden-name", "Hidden command.", "", nil, nil)
+				hidden.SetHidden(true)
+				return MustNew("cmd", "Command.", "", nil, nil, MustNew("visible", "Visible command.", "", nil, nil), hidden)
+			},
+			expectedHelpUsageOutput: `
+Usage: cmd [-h, --help]
+`,
+			expectedHelpOutput: `
+cmd: Command.
+
+Usage:
+    cmd [-h, --help]
+
+Flags:
+    [-h, --help]  Show this help screen and exit. 
+                  (default value: false, 
+                  environment variable: HELP)
+
+Available sub-commands:
+    visible   Visible command.
+
+`,
+		},
+		"with deprecated sub-commands": {
+			commandFactory: func(*testCase) *Command {
+				old := MustNew("old", "Old command.", "", nil, nil)
+				old.SetDeprecated("use 'new' instead")
+				return MustNew("cmd", "Command.", "", nil, nil, MustNew("new", "New command.", "", nil, nil), old)
+			},
+			expectedHelpUsageOutput: `
+Usage: cmd [-h, --help]
+`,
+			expectedHelpOutput: `
+cmd: Command.
+
+Usage:
+    cmd [-h, --help]
+
+Flags:
+    [-h, --help]  Show this help screen and exit. 
+                  (default value: false, 
+                  environment variable: HELP)
+
+Available sub-commands:
+    new       New command.
+    old       Old command. (DEPRECATED: use 'new' 
+              instead)
+
+`,
+		},
+		"with aliased sub-commands": {
+			commandFactory: func(*testCase) *Command {
+				remove := MustNew("remove", "Remove things.", "", nil, nil)
+				if err := remove.SetAliases("rm", "del"); err != nil {
+					panic(err)
+				}
+				return MustNew("cmd", "Command.", "", nil, nil, MustNew("add", "Add things.", "", nil, nil), remove)
+			},
+			expectedHelpUsageOutput: `
+Usage: cmd [-h, --help]
+`,
+			expectedHelpOutput: `
+cmd: Command.
+
+Usage:
+    cmd [-h, --help]
+
+Flags:
+    [-h, --help]  Show this help screen and exit. 
+                  (default value: false, 
+                  environment variable: HELP)
+
+Available sub-commands:
+    add                 Add things.
+    remove, rm, del     Remove things.
+
+`,
+		},
+		"with grouped flags": {
+			commandFactory: func(*testCase) *Command {
+				return MustNew("cmd", "Command.", "", &struct {
+					Action
+					Host    string `desc:"Host." group:"Networking"`
+					Port    int    `desc:"Port." group:"Networking"`
+					Verbose bool   `desc:"Verbose." group:"Logging"`
+				}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+			},
+			expectedHelpUsageOutput: `
+Usage: cmd [-h, --help] 
+    [--host=VALUE] 
+    [--port=VALUE] [--verbose]
+`,
+			expectedHelpOutput: `
+cmd: Command.
+
+Usage:
+    cmd [-h, --help] [--host=VALUE] 
+        [--port=VALUE] [--verbose]
+
+Flags:
+    [-h, --help]    Show this help screen and 
+                    exit. (default value: false, 
+                    environment variable: HELP)
+
+Networking:
+    [--host=VALUE]  Host. (environment variable: 
+                    HOST)
+    [--port=VALUE]  Port. (default value: 0, 
+                    environment variable: PORT)
+
+Logging:
+    [--verbose]     Verbose. (default value: 
+                    false, environment variable: 
+                    VERBOSE)
+
 `,
 		},
 	}
@@ -472,3 +1080,890 @@ Available sub-commands:
 		})
 	}
 }
+
+func TestPrintHelpCompact(t *testing.T) {
+	t.Parallel()
+	remove := MustNew("remove", "Remove an item from the list, permanently deleting it and everything it refers to", "", nil, nil)
+	With(t).Verify(remove.SetAliases("rm")).Will(Succeed()).OrFail()
+	old := MustNew("old", "Old command", "", nil, nil)
+	old.SetDeprecated("use 'new' instead")
+	hidden := MustNew("hidden", "Hidden command", "", nil, nil)
+	hidden.SetHidden(true)
+	root := MustNew("root", "Manage items", "Long description that is not shown.", &struct {
+		Action
+		Output string `desc:"Output format." inherited:"true"`
+		Limit  int    `desc:"Maximum number of items."`
+	}{}, nil, MustNew("add", "Add an item", "", nil, nil), remove, old, hidden)
+
+	b := &bytes.Buffer{}
+	With(t).Verify(root.PrintHelpCompact(b, 50)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`root: Manage items
+
+Usage:
+    root [-h, --help] [--limit=VALUE] 
+        [--output=VALUE]
+
+Available sub-commands:
+    add                 Add an item
+    remove, rm          Remove an item from the…
+    old                 Old command (DEPRECATED:…
+`)).OrFail()
+}
+
+func TestSetHelpFlag(t *testing.T) {
+	t.Parallel()
+
+	t.Run("renames help flag for all commands", func(t *testing.T) {
+		t.Parallel()
+		sub := MustNew("sub", "desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("root", "desc", "", nil, nil, sub)
+		With(t).Verify(root.SetHelpFlag("usage", "Afficher l'aide.")).Will(Succeed()).OrFail()
+		With(t).Verify(root.Validate()).Will(Succeed()).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub", "--usage"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`(?m)^    \[-h, --usage\]\s+Afficher l'aide\. \(default value: false, environment\s+variable: USAGE\)$`)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+		root.HelpConfig.Help = false
+
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub", "-h"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`(?m)^Usage:$`)).OrFail()
+		root.HelpConfig.Help = false
+
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub"}, map[string]string{"USAGE": "true"})).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`(?m)^Usage:$`)).OrFail()
+		root.HelpConfig.Help = false
+
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub", "--help"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`^unknown flag: --help\n`)).OrFail()
+	})
+
+	t.Run("empty description retains default", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("root", "desc", "", nil, nil)
+		With(t).Verify(root.SetHelpFlag("usage", "")).Will(Succeed()).OrFail()
+		b := &bytes.Buffer{}
+		With(t).Verify(root.PrintUsageLine(b, 80)).Will(Succeed()).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("Usage: root [-h, --usage]\n")).OrFail()
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, nil, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`\[-h, --usage\]\s+Show this help screen and exit\.`)).OrFail()
+	})
+
+	t.Run("invalid names are rejected", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("root", "desc", "", nil, nil)
+		for _, name := range []string{"", "--usage", "us age", "usage=x"} {
+			With(t).Verify(root.SetHelpFlag(name, "")).Will(Fail(`^invalid command: invalid help flag name '.*'$`)).OrFail()
+		}
+	})
+}
+
+func TestDisableAutoHelp(t *testing.T) {
+	t.Parallel()
+	var helpInvoked bool
+	helpCmd := MustNew("help", "Show help", "", ActionFunc(func(context.Context) error {
+		helpInvoked = true
+		return nil
+	}), nil)
+	root := MustNew("root", "desc", "", &struct {
+		Action
+		Force bool `short:"f"`
+	}{Action: ActionFunc(func(context.Context) error { return nil })}, nil, helpCmd)
+	With(t).Verify(root.DisableAutoHelp()).Will(Succeed()).OrFail()
+	With(t).Verify(root.Validate()).Will(Succeed()).OrFail()
+
+	b := &bytes.Buffer{}
+	With(t).Verify(root.PrintUsageLine(b, 80)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo("Usage: root [-f, --force]\n")).OrFail()
+
+	for _, arg := range []string{"--help", "-h"} {
+		b.Reset()
+		exitCode, err := ExecuteE(context.Background(), b, root, []string{arg}, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		var unknownFlagErr *ErrUnknownFlag
+		With(t).Verify(errors.As(err, &unknownFlagErr)).Will(EqualTo(true)).OrFail()
+		With(t).Verify(unknownFlagErr.Flag).Will(EqualTo(strings.TrimLeft(arg, "-"))).OrFail()
+		With(t).Verify(err).Will(Fail(`^unknown flag: ` + arg + `$`)).OrFail()
+	}
+
+	b.Reset()
+	With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"help"}, map[string]string{"HELP": "true"})).Will(EqualTo(ExitCodeSuccess)).OrFail()
+	With(t).Verify(helpInvoked).Will(EqualTo(true)).OrFail()
+	With(t).Verify(b.String()).Will(BeEmpty()).OrFail()
+}
+
+func TestSetHideZeroDefaults(t *testing.T) {
+	t.Parallel()
+	zero := 0
+	sub := MustNew("sub", "desc", "", &struct {
+		Action
+		Debug   bool          `desc:"Debug."`
+		Force   bool          `desc:"Force."`
+		Level   int           `desc:"Level."`
+		Name    string        `desc:"Name." env:"-"`
+		Port    *int          `desc:"Port." env:"-"`
+		Retries int           `desc:"Retries." env:"-"`
+		Timeout time.Duration `desc:"Timeout." env:"-"`
+	}{Action: ActionFunc(func(context.Context) error { return nil }), Force: true, Name: "abc", Port: &zero, Retries: 3}, nil)
+	root := MustNew("root", "desc", "", nil, nil, sub)
+	root.SetHideZeroDefaults(true)
+
+	b := &bytes.Buffer{}
+	With(t).Verify(sub.PrintHelp(b, 120)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`root sub: desc
+
+Usage:
+    root sub [--debug] [--force] [-h, --help] [--level=VALUE] [--name=VALUE] [--port=VALUE] [--retries=VALUE] 
+        [--timeout=VALUE]
+
+Flags:
+    [--debug]          Debug. (environment variable: DEBUG)
+    [--force]          Force. (default value: true, environment variable: FORCE) (negate with --no-force)
+    [-h, --help]       Show this help screen and exit. (environment variable: HELP)
+    [--level=VALUE]    Level. (environment variable: LEVEL)
+    [--name=VALUE]     Name. (default value: abc)
+    [--port=VALUE]     Port. (default value: 0)
+    [--retries=VALUE]  Retries. (default value: 3)
+    [--timeout=VALUE]  Timeout.
+
+`)).OrFail()
+
+	sub.SetHideZeroDefaults(false)
+	b.Reset()
+	With(t).Verify(sub.PrintHelp(b, 120)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(Say(`\[--debug\]\s+Debug\. \(default value: false, environment variable: DEBUG\)`)).OrFail()
+	With(t).Verify(b.String()).Will(Say(`\[--timeout=VALUE\]\s+Timeout\. \(default value: 0s\)`)).OrFail()
+}
+
+func TestMarkFlagsMutuallyExclusive(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		names         []string
+		args          []string
+		envVars       map[string]string
+		expectedError string
+		expectedOut   string
+		expectedCode  ExitCode
+	}
+	testCases := map[string]testCase{
+		"too few flags": {
+			names:         []string{"json"},
+			expectedError: `^invalid command: at least two flags are required for a mutually exclusive group$`,
+		},
+		"unknown flag": {
+			names:         []string{"json", "xml"},
+			expectedError: `^invalid command: unknown flag 'xml' in mutually exclusive group$`,
+		},
+		"single flag given": {
+			names:        []string{"json", "yaml"},
+			args:         []string{"--json"},
+			expectedCode: ExitCodeSuccess,
+		},
+		"both flags given": {
+			names:        []string{"json", "yaml"},
+			args:         []string{"--json", "--yaml"},
+			expectedOut:  "mutually exclusive flags given together: --json, --yaml\nUsage: cmd [-h, --help] [--json] [--yaml]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"environment variables are not considered given": {
+			names:        []string{"json", "yaml"},
+			args:         []string{"--json"},
+			envVars:      map[string]string{"YAML": "true"},
+			expectedCode: ExitCodeSuccess,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cmd := MustNew("cmd", "desc", "", &struct {
+				Action
+				JSON bool `name:"json"`
+				YAML bool `name:"yaml"`
+			}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.MarkFlagsMutuallyExclusive(tc.names...)).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(cmd.MarkFlagsMutuallyExclusive(tc.names...)).Will(Succeed()).OrFail()
+
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, cmd, tc.args, tc.envVars)).Will(EqualTo(tc.expectedCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+		})
+	}
+}
+
+func TestSetFlagValidator(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		name          string
+		args          []string
+		envVars       map[string]string
+		expectedError string
+		expectedOut   string
+		expectedCode  ExitCode
+	}
+	testCases := map[string]testCase{
+		"unknown flag": {
+			name:          "unknown",
+			expectedError: `^invalid command: unknown flag 'unknown'$`,
+		},
+		"valid value": {
+			name:         "name",
+			args:         []string{"--name=abc"},
+			expectedCode: ExitCodeSuccess,
+		},
+		"invalid value": {
+			name:         "name",
+			args:         []string{"--name=ABC"},
+			expectedOut:  "invalid value \"ABC\" for flag -name: invalid value 'ABC' for flag 'name': must be lower-case\nUsage: cmd [-h, --help] [--name=VALUE] [--verbose]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"invalid boolean value": {
+			name:         "verbose",
+			args:         []string{"--verbose"},
+			expectedOut:  "invalid boolean flag verbose: invalid value 'true' for flag 'verbose': must be lower-case\nUsage: cmd [-h, --help] [--name=VALUE] [--verbose]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"environment variables are not validated": {
+			name:         "name",
+			envVars:      map[string]string{"NAME": "ABC"},
+			expectedCode: ExitCodeSuccess,
+		},
+		"default values are not validated": {
+			name:         "name",
+			expectedCode: ExitCodeSuccess,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cmd := MustNew("cmd", "desc", "", &struct {
+				Action
+				Name    string `name:"name"`
+				Verbose bool   `name:"verbose"`
+			}{Action: ActionFunc(func(context.Context) error { return nil }), Name: "DEFAULT"}, nil)
+			validator := func(v string) error {
+				if v != strings.ToLower(v) || v == "true" {
+					return errors.New("must be lower-case")
+				}
+				return nil
+			}
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.SetFlagValidator(tc.name, validator)).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(cmd.SetFlagValidator(tc.name, validator)).Will(Succeed()).OrFail()
+
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, cmd, tc.args, tc.envVars)).Will(EqualTo(tc.expectedCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+		})
+	}
+}
+
+func TestSetFlagValidatorInherited(t *testing.T) {
+	t.Parallel()
+	sub := MustNew("sub", "desc", "", &struct {
+		Action
+	}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+	root := MustNew("root", "desc", "", &struct {
+		Action
+		Name string `name:"name" inherited:"true"`
+	}{Action: ActionFunc(func(context.Context) error { return nil })}, nil, sub)
+	With(t).Verify(root.SetFlagValidator("name", func(string) error { return errors.New("bad") })).Will(Succeed()).OrFail()
+
+	b := &bytes.Buffer{}
+	With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub", "--name=x"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo("invalid value \"x\" for flag -name: invalid value 'x' for flag 'name': bad\nUsage: root sub [-h, --help] [--name=VALUE]\n")).OrFail()
+}
+
+func TestSetFlagNormalizer(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Action
+		Name string `name:"name"`
+	}
+	type testCase struct {
+		name          string
+		args          []string
+		envVars       map[string]string
+		withValidator bool
+		expectedError string
+		expectedOut   string
+		expectedCode  ExitCode
+		expectedName  string
+	}
+	testCases := map[string]testCase{
+		"unknown flag": {
+			name:          "unknown",
+			expectedError: `^invalid command: unknown flag 'unknown'$`,
+		},
+		"CLI value is normalized": {
+			name:         "name",
+			args:         []string{"--name=  ABC "},
+			expectedCode: ExitCodeSuccess,
+			expectedName: "abc",
+		},
+		"environment variable is normalized": {
+			name:         "name",
+			envVars:      map[string]string{"NAME": "XYZ"},
+			expectedCode: ExitCodeSuccess,
+			expectedName: "xyz",
+		},
+		"default value is not normalized": {
+			name:         "name",
+			expectedCode: ExitCodeSuccess,
+			expectedName: "DEFAULT",
+		},
+		"validator is given normalized value": {
+			name:          "name",
+			args:          []string{"--name=ABC"},
+			withValidator: true,
+			expectedCode:  ExitCodeSuccess,
+			expectedName:  "abc",
+		},
+		"normalizer error": {
+			name:         "name",
+			args:         []string{"--name=   "},
+			expectedOut:  "invalid value \"   \" for flag -name: invalid value '   ' for flag 'name': must not be blank\nUsage: cmd [-h, --help] [--name=VALUE]\n",
+			expectedCode: ExitCodeMisconfiguration,
+			expectedName: "DEFAULT",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config{Action: ActionFunc(func(context.Context) error { return nil }), Name: "DEFAULT"}
+			cmd := MustNew("cmd", "desc", "", cfg, nil)
+			normalizer := func(v string) (string, error) {
+				if v = strings.TrimSpace(v); v == "" {
+					return "", errors.New("must not be blank")
+				}
+				return strings.ToLower(v), nil
+			}
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.SetFlagNormalizer(tc.name, normalizer)).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(cmd.SetFlagNormalizer(tc.name, normalizer)).Will(Succeed()).OrFail()
+			if tc.withValidator {
+				With(t).Verify(cmd.SetFlagValidator(tc.name, func(v string) error {
+					if v != strings.ToLower(v) {
+						return errors.New("must be lower-case")
+					}
+					return nil
+				})).Will(Succeed()).OrFail()
+			}
+
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, cmd, tc.args, tc.envVars)).Will(EqualTo(tc.expectedCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+			With(t).Verify(cfg.Name).Will(EqualTo(tc.expectedName)).OrFail()
+		})
+	}
+}
+
+func TestAliasFlag(t *testing.T) {
+	t.Parallel()
+	type rootConfig struct {
+		Action
+		Output  string `name:"output" inherited:"true" desc:"Output file."`
+		Verbose bool   `name:"verbose" short:"v"`
+	}
+	newCommands := func() (*Command, *Command, *rootConfig) {
+		rootCfg := &rootConfig{Action: ActionFunc(func(context.Context) error { return nil })}
+		sub := MustNew("sub", "desc", "", &struct{ Action }{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+		root := MustNew("root", "desc", "", rootCfg, nil, sub)
+		return root, sub, rootCfg
+	}
+
+	t.Run("alias behaves like the canonical flag", func(t *testing.T) {
+		t.Parallel()
+		root, _, rootCfg := newCommands()
+		With(t).Verify(root.AliasFlag("out", "output")).Will(Succeed()).OrFail()
+		With(t).Verify(root.AliasFlag("loud", "verbose")).Will(Succeed()).OrFail()
+
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"--out=a.txt", "--loud"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(rootCfg.Output).Will(EqualTo("a.txt")).OrFail()
+		With(t).Verify(rootCfg.Verbose).Will(EqualTo(true)).OrFail()
+		With(t).Verify(root.ChangedFlags()).Will(EqualTo([]string{"output", "verbose"})).OrFail()
+	})
+
+	t.Run("alias applies to sub-commands inheriting the flag", func(t *testing.T) {
+		t.Parallel()
+		root, _, rootCfg := newCommands()
+		With(t).Verify(root.AliasFlag("out", "output")).Will(Succeed()).OrFail()
+		With(t).Verify(root.AliasFlag("loud", "verbose")).Will(Succeed()).OrFail()
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"sub", "--out", "b.txt"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(rootCfg.Output).Will(EqualTo("b.txt")).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub", "--loud"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`^unknown flag: --loud\n`)).OrFail()
+	})
+
+	t.Run("aliases are hidden from help unless requested", func(t *testing.T) {
+		t.Parallel()
+		root, sub, _ := newCommands()
+		With(t).Verify(root.AliasFlag("out", "output")).Will(Succeed()).OrFail()
+		With(t).Verify(root.AliasFlag("output-file", "output")).Will(Succeed()).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(sub.PrintHelp(b, 120)).Will(Succeed()).OrFail()
+		With(t).Verify(b.String()).Will(Say(`(?m)^    \[--output=VALUE\]  Output file\. \(environment variable: OUTPUT\)$`)).OrFail()
+
+		root.SetShowFlagAliases(true)
+		b.Reset()
+		With(t).Verify(sub.PrintHelp(b, 120)).Will(Succeed()).OrFail()
+		With(t).Verify(b.String()).Will(Say(`(?m)^    \[--output=VALUE\]  Output file\. \(environment variable: OUTPUT\) \(aliases: --out, --output-file\)$`)).OrFail()
+	})
+
+	t.Run("invalid aliases are rejected", func(t *testing.T) {
+		t.Parallel()
+		root, _, _ := newCommands()
+		With(t).Verify(root.AliasFlag("out", "output")).Will(Succeed()).OrFail()
+		With(t).Verify(root.AliasFlag("", "output")).Will(Fail(`^invalid command: invalid flag alias ''$`)).OrFail()
+		With(t).Verify(root.AliasFlag("--o", "output")).Will(Fail(`^invalid command: invalid flag alias '--o'$`)).OrFail()
+		With(t).Verify(root.AliasFlag("o", "unknown")).Will(Fail(`^invalid command: unknown flag 'unknown'$`)).OrFail()
+		With(t).Verify(root.AliasFlag("verbose", "output")).Will(Fail(`^invalid command: flag alias 'verbose' collides with flag 'verbose'$`)).OrFail()
+		With(t).Verify(root.AliasFlag("v", "output")).Will(Fail(`^invalid command: flag alias 'v' collides with flag 'verbose'$`)).OrFail()
+		With(t).Verify(root.AliasFlag("no-verbose", "output")).Will(Fail(`^invalid command: flag alias 'no-verbose' collides with flag 'verbose'$`)).OrFail()
+		With(t).Verify(root.AliasFlag("out", "verbose")).Will(Fail(`^invalid command: flag alias 'out' is already an alias of flag 'output'$`)).OrFail()
+	})
+
+	t.Run("alias conflicting with a sub-command flag fails", func(t *testing.T) {
+		t.Parallel()
+		sub := MustNew("sub", "desc", "", &struct {
+			Action
+			Out string `name:"out"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+		root := MustNew("root", "desc", "", &rootConfig{Action: ActionFunc(func(context.Context) error { return nil })}, nil, sub)
+		With(t).Verify(root.AliasFlag("out", "output")).Will(Succeed()).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"sub"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(b.String()).Will(Say(`^alias 'out' of flag 'output' conflicts with another flag\n`)).OrFail()
+	})
+}
+
+func TestSetFlagNormalizerBoolean(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Action
+		Verbose bool `name:"verbose"`
+	}
+	type testCase struct {
+		args            []string
+		envVars         map[string]string
+		expectedOut     string
+		expectedCode    ExitCode
+		expectedVerbose bool
+	}
+	testCases := map[string]testCase{
+		"flag without value":           {args: []string{"--verbose"}, expectedCode: ExitCodeSuccess, expectedVerbose: true},
+		"explicit value is normalized": {args: []string{"--verbose=Sure"}, expectedCode: ExitCodeSuccess, expectedVerbose: true},
+		"negated flag value is normalized": {
+			args:            []string{"--verbose", "--no-verbose=SURE"},
+			expectedCode:    ExitCodeSuccess,
+			expectedVerbose: false,
+		},
+		"environment variable is normalized": {envVars: map[string]string{"VERBOSE": "sure"}, expectedCode: ExitCodeSuccess, expectedVerbose: true},
+		"normalizer error": {
+			args:         []string{"--verbose=never"},
+			expectedOut:  "invalid boolean value \"never\" for -verbose: invalid value 'never' for flag 'verbose': unsupported value\nUsage: cmd [-h, --help] [--verbose]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config{Action: ActionFunc(func(context.Context) error { return nil })}
+			cmd := MustNew("cmd", "desc", "", cfg, nil)
+			With(t).Verify(cmd.SetFlagNormalizer("verbose", func(v string) (string, error) {
+				switch v = strings.ToLower(v); v {
+				case "sure":
+					return "true", nil
+				case "never":
+					return "", errors.New("unsupported value")
+				default:
+					return v, nil
+				}
+			})).Will(Succeed()).OrFail()
+
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, cmd, tc.args, tc.envVars)).Will(EqualTo(tc.expectedCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+			With(t).Verify(cfg.Verbose).Will(EqualTo(tc.expectedVerbose)).OrFail()
+		})
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Action
+		Name  string        `name:"name"`
+		Port  int           `name:"port"`
+		Wait  time.Duration `name:"wait"`
+		Level string        `name:"level" choices:"info,debug"`
+	}
+	type testCase struct {
+		name           string
+		value          string
+		args           []string
+		envVars        map[string]string
+		expectedError  string
+		expectedConfig config
+	}
+	testCases := map[string]testCase{
+		"unknown flag": {
+			name:          "unknown",
+			value:         "v",
+			expectedError: `^invalid command: unknown flag 'unknown'$`,
+		},
+		"invalid value": {
+			name:          "port",
+			value:         "abc",
+			expectedError: `^invalid value 'abc' for flag 'port': invalid syntax$`,
+		},
+		"value not in choices": {
+			name:          "level",
+			value:         "trace",
+			expectedError: `^invalid value 'trace' for flag 'level': must be one of: info, debug$`,
+		},
+		"string default": {
+			name:           "name",
+			value:          "n1",
+			expectedConfig: config{Name: "n1", Port: 80, Level: "info"},
+		},
+		"int default": {
+			name:           "port",
+			value:          "8080",
+			expectedConfig: config{Name: "default", Port: 8080, Level: "info"},
+		},
+		"duration default": {
+			name:           "wait",
+			value:          "5s",
+			expectedConfig: config{Name: "default", Port: 80, Wait: 5 * time.Second, Level: "info"},
+		},
+		"environment variable overrides default": {
+			name:           "port",
+			value:          "8080",
+			envVars:        map[string]string{"PORT": "9090"},
+			expectedConfig: config{Name: "default", Port: 9090, Level: "info"},
+		},
+		"CLI flag overrides default": {
+			name:           "port",
+			value:          "8080",
+			args:           []string{"--port=9090"},
+			expectedConfig: config{Name: "default", Port: 9090, Level: "info"},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config{Action: ActionFunc(func(context.Context) error { return nil }), Name: "default", Port: 80, Level: "info"}
+			cmd := MustNew("cmd", "desc", "", cfg, nil)
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.SetDefault(tc.name, tc.value)).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(cmd.SetDefault(tc.name, tc.value)).Will(Succeed()).OrFail()
+			_, err := cmd.Resolve(tc.args, tc.envVars)
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			cfg.Action = nil
+			With(t).Verify(*cfg).Will(EqualTo(tc.expectedConfig)).OrFail()
+		})
+	}
+}
+
+func TestSetDefaultInherited(t *testing.T) {
+	t.Parallel()
+	subConfig := &struct {
+		Action
+	}{Action: ActionFunc(func(context.Context) error { return nil })}
+	sub := MustNew("sub", "desc", "", subConfig, nil)
+	rootConfig := &struct {
+		Action
+		Name string `name:"name" inherited:"true" desc:"Name."`
+	}{Action: ActionFunc(func(context.Context) error { return nil }), Name: "default"}
+	root := MustNew("root", "desc", "", rootConfig, nil, sub)
+	With(t).Verify(root.SetDefault("name", "runtime")).Will(Succeed()).OrFail()
+
+	b := &bytes.Buffer{}
+	With(t).Verify(sub.PrintHelp(b, 80)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(Say(`--name=VALUE\]\s+Name\. \(default value: runtime,`)).OrFail()
+
+	_, err := root.Resolve([]string{"sub"}, nil)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	With(t).Verify(rootConfig.Name).Will(EqualTo("runtime")).OrFail()
+}
+
+func TestInheritedFlagDefaultOverride(t *testing.T) {
+	t.Parallel()
+	type rootConfig struct {
+		Action
+		Level string `name:"level" inherited:"true"`
+	}
+	type subConfig struct {
+		Action
+		Level string `name:"level" inherited:"true"`
+	}
+	rootCfg := &rootConfig{Action: ActionFunc(func(context.Context) error { return nil }), Level: "info"}
+	subCfg := &subConfig{Action: ActionFunc(func(context.Context) error { return nil }), Level: "debug"}
+	leaf := MustNew("leaf", "desc", "", &struct{ Action }{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+	other := MustNew("other", "desc", "", &struct{ Action }{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+	root := MustNew("root", "desc", "", rootCfg, nil, MustNew("sub", "desc", "", subCfg, nil, leaf), other)
+	With(t).Verify(root.Validate()).Will(Succeed()).OrFail()
+
+	type testCase struct {
+		args          []string
+		expectedLevel string
+	}
+	testCases := map[string]testCase{
+		"root uses its own default":                  {args: nil, expectedLevel: "info"},
+		"sibling uses root default":                  {args: []string{"other"}, expectedLevel: "info"},
+		"re-declaring sub-command uses its default":  {args: []string{"sub"}, expectedLevel: "debug"},
+		"descendant of re-declaring sub-command too": {args: []string{"sub", "leaf"}, expectedLevel: "debug"},
+		"explicit value still wins":                  {args: []string{"sub", "--level=warn"}, expectedLevel: "warn"},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			rootCfg.Level, subCfg.Level = "", ""
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, root, tc.args, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+			With(t).Verify(rootCfg.Level).Will(EqualTo(tc.expectedLevel)).OrFail()
+		})
+	}
+}
+
+func TestSetPositionalSpec(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		names         []string
+		min, max      int
+		args          []string
+		expectedError string
+		expectedOut   string
+		expectedCode  ExitCode
+	}
+	testCases := map[string]testCase{
+		"negative minimum": {
+			min:           -1,
+			expectedError: `^invalid command: minimum number of positional arguments must not be negative$`,
+		},
+		"maximum lower than minimum": {
+			min:           2,
+			max:           1,
+			expectedError: `^invalid command: maximum number of positional arguments must not be lower than the minimum$`,
+		},
+		"empty name": {
+			names:         []string{"SRC", ""},
+			expectedError: `^invalid command: empty positional argument name$`,
+		},
+		"exact count": {
+			names:        []string{"SRC", "DST"},
+			min:          2,
+			max:          2,
+			args:         []string{"a", "b"},
+			expectedOut:  "[a b]\n",
+			expectedCode: ExitCodeSuccess,
+		},
+		"too few": {
+			names:        []string{"SRC", "DST"},
+			min:          2,
+			max:          2,
+			args:         []string{"a"},
+			expectedOut:  "expected exactly 2 positional arguments (SRC DST), got 1\nUsage: cmd [-h, --help] SRC DST\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"too many": {
+			names:        []string{"SRC", "DST"},
+			min:          1,
+			max:          2,
+			args:         []string{"a", "b", "c"},
+			expectedOut:  "expected between 1 and 2 positional arguments (SRC [DST]), got 3\nUsage: cmd [-h, --help] SRC [DST]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"unlimited": {
+			names:        []string{"FILE"},
+			min:          1,
+			max:          -1,
+			args:         []string{"a", "b", "c"},
+			expectedOut:  "[a b c]\n",
+			expectedCode: ExitCodeSuccess,
+		},
+		"unlimited with too few": {
+			names:        []string{"FILE"},
+			min:          1,
+			max:          -1,
+			expectedOut:  "expected at least 1 positional arguments (FILE...), got 0\nUsage: cmd [-h, --help] FILE...\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"optional with too many": {
+			names:        []string{"NAME"},
+			max:          1,
+			args:         []string{"a", "b"},
+			expectedOut:  "expected at most 1 positional arguments ([NAME]), got 2\nUsage: cmd [-h, --help] [NAME]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			b := &bytes.Buffer{}
+			action := &struct {
+				Action
+				Args []string `args:"true"`
+			}{}
+			action.Action = ActionFunc(func(context.Context) error {
+				_, _ = fmt.Fprintln(b, action.Args)
+				return nil
+			})
+			cmd := MustNew("cmd", "desc", "", action, nil)
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.SetPositionalSpec(tc.names, tc.min, tc.max)).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(cmd.SetPositionalSpec(tc.names, tc.min, tc.max)).Will(Succeed()).OrFail()
+			With(t).Verify(ExecuteWithContext(context.Background(), b, cmd, tc.args, nil)).Will(EqualTo(tc.expectedCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		commandFactory func() *Command
+		expectedError  string
+	}
+	testCases := map[string]testCase{
+		"valid tree": {
+			commandFactory: func() *Command {
+				return MustNew("root", "desc", "", &struct {
+					Action
+					Verbose bool `short:"v" inherited:"true"`
+				}{}, nil, MustNew("sub", "desc", "", &struct {
+					Action
+					Verbose bool `short:"v"`
+				}{}, nil))
+			},
+		},
+		"incompatible inherited flags": {
+			commandFactory: func() *Command {
+				return MustNew("root", "desc", "", &struct {
+					Action
+					Level string `inherited:"true"`
+				}{Level: "info"}, nil,
+					MustNew("sub1", "desc", "", &struct {
+						Action
+						Level bool `flag:"true"`
+					}{}, nil),
+					MustNew("sub2", "desc", "", nil, nil, MustNew("sub3", "desc", "", &struct {
+						Action
+						Level string `short:"l"`
+						Limit string `short:"l"`
+					}{Level: "info"}, nil)),
+				)
+			},
+			expectedError: `^command 'root sub1': given flag 'level' must not have a value, but it does\n` +
+				`command 'root sub2 sub3': short name 'l' of flag 'limit' conflicts with flag 'level'$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cmd := tc.commandFactory()
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.Validate()).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(cmd.Validate()).Will(Succeed()).OrFail()
+			}
+		})
+	}
+}
+
+func TestMarkFlagsOneRequired(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		names         []string
+		args          []string
+		envVars       map[string]string
+		expectedError string
+		expectedOut   string
+		expectedCode  ExitCode
+	}
+	testCases := map[string]testCase{
+		"too few flags": {
+			names:         []string{"file"},
+			expectedError: `^invalid command: at least two flags are required for a one-required group$`,
+		},
+		"unknown flag": {
+			names:         []string{"file", "url"},
+			expectedError: `^invalid command: unknown flag 'url' in one-required group$`,
+		},
+		"one flag given": {
+			names:        []string{"file", "stdin"},
+			args:         []string{"--name=n", "--stdin"},
+			expectedCode: ExitCodeSuccess,
+		},
+		"all flags given": {
+			names:        []string{"file", "stdin"},
+			args:         []string{"--name=n", "--stdin", "--file=f"},
+			expectedCode: ExitCodeSuccess,
+		},
+		"no flags given": {
+			names:        []string{"file", "stdin"},
+			args:         []string{"--name=n"},
+			expectedOut:  "at least one of the flags is required: --file, --stdin\nUsage: cmd [--file=VALUE] [-h, --help] --name=VALUE [--stdin]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"environment variables are not considered given": {
+			names:        []string{"file", "stdin"},
+			envVars:      map[string]string{"FILE": "f"},
+			args:         []string{"--name=n"},
+			expectedOut:  "at least one of the flags is required: --file, --stdin\nUsage: cmd [--file=VALUE] [-h, --help] --name=VALUE [--stdin]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+		"required flags are reported first": {
+			names:        []string{"file", "stdin"},
+			expectedOut:  "required flag is missing: --name\nUsage: cmd [--file=VALUE] [-h, --help] --name=VALUE [--stdin]\n",
+			expectedCode: ExitCodeMisconfiguration,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cmd := MustNew("cmd", "desc", "", &struct {
+				Action
+				File  string `flag:"true"`
+				Stdin bool   `flag:"true"`
+				Name  string `flag:"true" required:"true"`
+			}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+			if tc.expectedError != "" {
+				With(t).Verify(cmd.MarkFlagsOneRequired(tc.names...)).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(cmd.MarkFlagsOneRequired(tc.names...)).Will(Succeed()).OrFail()
+
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, cmd, tc.args, tc.envVars)).Will(EqualTo(tc.expectedCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+		})
+	}
+}
@@ -0,0 +1,361 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var nonIdentifierCharsRE = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// GenBashCompletion writes a bash completion script for this command's hierarchy to the given writer. The script
+// completes sub-command names at each level of the hierarchy, and flag names (when the current word starts with "-").
+func (c *Command) GenBashCompletion(w io.Writer) error {
+	root := c.getChain()[0]
+	funcName := "_" + nonIdentifierCharsRE.ReplaceAllString(root.name, "_") + "_completions"
+
+	b := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(b, "# bash completion for %s\n", root.name)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintf(b, "%s() {\n", funcName)
+	_, _ = fmt.Fprintln(b, `    local cur="${COMP_WORDS[COMP_CWORD]}"`)
+	_, _ = fmt.Fprintf(b, "    local cmd=%s\n", bashQuote(root.name))
+	_, _ = fmt.Fprintln(b, `    local i`)
+	_, _ = fmt.Fprintln(b, `    for ((i = 1; i < COMP_CWORD; i++)); do`)
+	_, _ = fmt.Fprintln(b, `        case "${cmd}:${COMP_WORDS[i]}" in`)
+	if err := root.walkBashCompletionTransitions(b); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b, `        esac`)
+	_, _ = fmt.Fprintln(b, `    done`)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, `    local commands="" flags=""`)
+	_, _ = fmt.Fprintln(b, `    case "${cmd}" in`)
+	if err := root.walkBashCompletionWords(b); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b, `    esac`)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, `    if [[ "${cur}" == -* ]]; then`)
+	_, _ = fmt.Fprintln(b, `        COMPREPLY=($(compgen -W "${flags}" -- "${cur}"))`)
+	_, _ = fmt.Fprintln(b, `    else`)
+	_, _ = fmt.Fprintln(b, `        COMPREPLY=($(compgen -W "${commands}" -- "${cur}"))`)
+	_, _ = fmt.Fprintln(b, `    fi`)
+	_, _ = fmt.Fprintln(b, `}`)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintf(b, "complete -F %s %s\n", funcName, root.name)
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// walkBashCompletionTransitions writes the bash "case" branches that transition from this command to each of its
+// sub-commands, recursively.
+func (c *Command) walkBashCompletionTransitions(b *bytes.Buffer) error {
+	fullName := c.getFullName()
+	for _, subCmd := range c.subCommands {
+		var patterns []string
+		for _, name := range subCmd.getNames() {
+			patterns = append(patterns, bashQuote(fullName+":"+name))
+		}
+		_, _ = fmt.Fprintf(b, "            %s) cmd=%s ;;\n", strings.Join(patterns, "|"), bashQuote(subCmd.getFullName()))
+		if err := subCmd.walkBashCompletionTransitions(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkBashCompletionWords writes the bash "case" branches that list the sub-commands & flags of this command and all
+// of its sub-commands, recursively.
+func (c *Command) walkBashCompletionWords(b *bytes.Buffer) error {
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	var commands, flags []string
+	for _, subCmd := range c.getVisibleSubCommands() {
+		commands = append(commands, subCmd.name)
+	}
+	for _, mfd := range mergedFlagDefs {
+		flags = append(flags, "--"+mfd.Name)
+		if mfd.Short != nil {
+			flags = append(flags, "-"+*mfd.Short)
+		}
+	}
+
+	_, _ = fmt.Fprintf(b, "        %s)\n", bashQuote(c.getFullName()))
+	_, _ = fmt.Fprintf(b, "            commands=%s\n", bashQuote(strings.Join(commands, " ")))
+	_, _ = fmt.Fprintf(b, "            flags=%s\n", bashQuote(strings.Join(flags, " ")))
+	_, _ = fmt.Fprintln(b, "            ;;")
+
+	for _, subCmd := range c.subCommands {
+		if err := subCmd.walkBashCompletionWords(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// bashQuote quotes the given string as a single-quoted bash word.
+func bashQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// GenZshCompletion writes a zsh completion script for this command's hierarchy to the given writer. The script
+// completes sub-command names at each level of the hierarchy (described by their short descriptions), and flag names
+// (described by their "desc" tags) when the current word starts with "-".
+func (c *Command) GenZshCompletion(w io.Writer) error {
+	root := c.getChain()[0]
+	funcName := "_" + nonIdentifierCharsRE.ReplaceAllString(root.name, "_")
+
+	b := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(b, "#compdef %s\n", root.name)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintf(b, "%s() {\n", funcName)
+	_, _ = fmt.Fprintf(b, "    local cmd=%s\n", zshQuote(root.name))
+	_, _ = fmt.Fprintln(b, `    local i`)
+	_, _ = fmt.Fprintln(b, `    for ((i = 2; i < CURRENT; i++)); do`)
+	_, _ = fmt.Fprintln(b, `        case "${cmd}:${words[i]}" in`)
+	if err := root.walkZshCompletionTransitions(b); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b, `        esac`)
+	_, _ = fmt.Fprintln(b, `    done`)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, `    local -a commands flags`)
+	_, _ = fmt.Fprintln(b, `    case "${cmd}" in`)
+	if err := root.walkZshCompletionWords(b); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b, `    esac`)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, `    if [[ "${words[CURRENT]}" == -* ]]; then`)
+	_, _ = fmt.Fprintln(b, `        _describe -t flags 'flag' flags`)
+	_, _ = fmt.Fprintln(b, `    else`)
+	_, _ = fmt.Fprintln(b, `        _describe -t commands 'command' commands`)
+	_, _ = fmt.Fprintln(b, `    fi`)
+	_, _ = fmt.Fprintln(b, `}`)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, `# Support both autoloading (via "fpath") and sourcing this script directly`)
+	_, _ = fmt.Fprintf(b, "if [[ \"${funcstack[1]}\" == %s ]]; then\n", funcName)
+	_, _ = fmt.Fprintf(b, "    %s \"$@\"\n", funcName)
+	_, _ = fmt.Fprintln(b, `else`)
+	_, _ = fmt.Fprintf(b, "    compdef %s %s\n", funcName, root.name)
+	_, _ = fmt.Fprintln(b, `fi`)
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// walkZshCompletionTransitions writes the zsh "case" branches that transition from this command to each of its
+// sub-commands, recursively.
+func (c *Command) walkZshCompletionTransitions(b *bytes.Buffer) error {
+	fullName := c.getFullName()
+	for _, subCmd := range c.subCommands {
+		var patterns []string
+		for _, name := range subCmd.getNames() {
+			patterns = append(patterns, zshQuote(fullName+":"+name))
+		}
+		_, _ = fmt.Fprintf(b, "            %s) cmd=%s ;;\n", strings.Join(patterns, "|"), zshQuote(subCmd.getFullName()))
+		if err := subCmd.walkZshCompletionTransitions(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkZshCompletionWords writes the zsh "case" branches that list the described sub-commands & flags of this command
+// and all of its sub-commands, recursively.
+func (c *Command) walkZshCompletionWords(b *bytes.Buffer) error {
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	_, _ = fmt.Fprintf(b, "        %s)\n", zshQuote(c.getFullName()))
+	_, _ = fmt.Fprintln(b, "            commands=(")
+	for _, subCmd := range c.getVisibleSubCommands() {
+		_, _ = fmt.Fprintf(b, "                %s\n", zshQuote(zshDescribeItem(subCmd.name, subCmd.shortDescription)))
+	}
+	_, _ = fmt.Fprintln(b, "            )")
+	_, _ = fmt.Fprintln(b, "            flags=(")
+	for _, mfd := range mergedFlagDefs {
+		desc := defaultIfNil(mfd.Description, "")
+		_, _ = fmt.Fprintf(b, "                %s\n", zshQuote(zshDescribeItem("--"+mfd.Name, desc)))
+		if mfd.Short != nil {
+			_, _ = fmt.Fprintf(b, "                %s\n", zshQuote(zshDescribeItem("-"+*mfd.Short, desc)))
+		}
+	}
+	_, _ = fmt.Fprintln(b, "            )")
+	_, _ = fmt.Fprintln(b, "            ;;")
+
+	for _, subCmd := range c.subCommands {
+		if err := subCmd.walkZshCompletionWords(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zshQuote quotes the given string as a single-quoted zsh word.
+func zshQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// zshDescribeItem formats the given name & description as an item for the zsh "_describe" function.
+func zshDescribeItem(name, description string) string {
+	name = strings.ReplaceAll(name, ":", `\:`)
+	if description == "" {
+		return name
+	}
+	return name + ":" + strings.ReplaceAll(description, "\n", " ")
+}
+
+// GenFishCompletion writes a fish completion script for this command's hierarchy to the given writer. The script
+// completes sub-command names at each level of the hierarchy, and flag names (long & short), described by their short
+// descriptions and "desc" tags respectively.
+func (c *Command) GenFishCompletion(w io.Writer) error {
+	root := c.getChain()[0]
+	funcName := "__" + nonIdentifierCharsRE.ReplaceAllString(root.name, "_") + "_using_command"
+
+	b := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(b, "# fish completion for %s\n", root.name)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintf(b, "function %s\n", funcName)
+	_, _ = fmt.Fprintf(b, "    set -l cmd %s\n", fishQuote(root.name))
+	_, _ = fmt.Fprintln(b, `    for word in (commandline -opc)[2..-1]`)
+	_, _ = fmt.Fprintln(b, `        switch "$cmd:$word"`)
+	if err := root.walkFishCompletionTransitions(b); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b, `        end`)
+	_, _ = fmt.Fprintln(b, `    end`)
+	_, _ = fmt.Fprintln(b, `    test "$cmd" = "$argv[1]"`)
+	_, _ = fmt.Fprintln(b, `end`)
+	_, _ = fmt.Fprintln(b)
+	if err := root.walkFishCompletionWords(b, root.name, funcName); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// walkFishCompletionTransitions writes the fish "switch" cases that transition from this command to each of its
+// sub-commands, recursively.
+func (c *Command) walkFishCompletionTransitions(b *bytes.Buffer) error {
+	fullName := c.getFullName()
+	for _, subCmd := range c.subCommands {
+		var patterns []string
+		for _, name := range subCmd.getNames() {
+			patterns = append(patterns, fishQuote(fullName+":"+name))
+		}
+		_, _ = fmt.Fprintf(b, "            case %s\n", strings.Join(patterns, " "))
+		_, _ = fmt.Fprintf(b, "                set cmd %s\n", fishQuote(subCmd.getFullName()))
+		if err := subCmd.walkFishCompletionTransitions(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkFishCompletionWords writes the fish "complete" lines for the sub-commands & flags of this command and all of its
+// sub-commands, recursively.
+func (c *Command) walkFishCompletionWords(b *bytes.Buffer, rootName, funcName string) error {
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	complete := "complete -c " + fishQuote(rootName)
+	condition := fishQuote(funcName + " " + fishQuote(c.getFullName()))
+	for _, subCmd := range c.getVisibleSubCommands() {
+		line := fmt.Sprintf("%s -f -n %s -a %s", complete, condition, fishQuote(subCmd.name))
+		if subCmd.shortDescription != "" {
+			line += " -d " + fishQuote(subCmd.shortDescription)
+		}
+		_, _ = fmt.Fprintln(b, line)
+	}
+	for _, mfd := range mergedFlagDefs {
+		line := fmt.Sprintf("%s -n %s -l %s", complete, condition, fishQuote(mfd.Name))
+		if mfd.Short != nil {
+			line += " -s " + fishQuote(*mfd.Short)
+		}
+		if len(mfd.Choices) > 0 {
+			line += " -x -a " + fishQuote(strings.Join(mfd.Choices, " "))
+		} else if mfd.HasValue {
+			line += " -r"
+		}
+		if mfd.Description != nil && *mfd.Description != "" {
+			line += " -d " + fishQuote(strings.ReplaceAll(*mfd.Description, "\n", " "))
+		}
+		_, _ = fmt.Fprintln(b, line)
+	}
+
+	for _, subCmd := range c.subCommands {
+		if err := subCmd.walkFishCompletionWords(b, rootName, funcName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fishQuote quotes the given string as a single-quoted fish word.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
+
+// completionAction prints a completion script for the root of the command hierarchy it's part of.
+type completionAction struct {
+	cmd       *Command
+	w         io.Writer
+	generator func(*Command, io.Writer) error
+}
+
+func (a *completionAction) Run(_ context.Context) error {
+	return a.generator(a.cmd.getChain()[0], a.w)
+}
+
+// NewCompletionCommand creates a "completion" command, with a sub-command per supported shell (e.g. "completion bash")
+// that prints the completion script of the command hierarchy it is added to into the given writer. Add it as a
+// sub-command of your root command to make shell completions available to your users. The command is hidden from help
+// screens & generated documentation, but can still be invoked.
+func NewCompletionCommand(w io.Writer) (*Command, error) {
+	shells := []struct {
+		name      string
+		generator func(*Command, io.Writer) error
+	}{
+		{name: "bash", generator: (*Command).GenBashCompletion},
+		{name: "zsh", generator: (*Command).GenZshCompletion},
+		{name: "fish", generator: (*Command).GenFishCompletion},
+	}
+
+	var shellCommands []*Command
+	for _, shell := range shells {
+		action := &completionAction{w: w, generator: shell.generator}
+		cmd, err := New(shell.name, fmt.Sprintf("Generate the %s completion script.", shell.name), "", action, nil)
+		if err != nil {
+			return nil, err
+		}
+		action.cmd = cmd
+		shellCommands = append(shellCommands, cmd)
+	}
+
+	cmd, err := New("completion", "Generate shell completion scripts.", "", nil, nil, shellCommands...)
+	if err != nil {
+		return nil, err
+	}
+	cmd.SetHidden(true)
+	return cmd, nil
+}
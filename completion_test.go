@@ -0,0 +1,316 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	. "github.com/arikkfir/justest"
+)
+
+func newCompletionTestCommand(t T, w *bytes.Buffer) *Command {
+	completionCmd, err := NewCompletionCommand(w)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	return MustNew(
+		"my-cmd", "desc", "",
+		&struct {
+			Action
+			Verbose bool `short:"v" desc:"Verbose output."`
+		}{},
+		nil,
+		MustNew(
+			"sub1", "sub1 desc", "", nil, nil,
+			MustNew(
+				"sub2", "sub2 desc", "",
+				&struct {
+					Action
+					Force bool `desc:"Force it."`
+				}{},
+				nil,
+			),
+		),
+		completionCmd,
+	)
+}
+
+func TestGenBashCompletion(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	root := newCompletionTestCommand(t, b)
+	With(t).Verify(root.GenBashCompletion(b)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`
+# bash completion for my-cmd
+
+_my_cmd_completions() {
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local cmd='my-cmd'
+    local i
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        case "${cmd}:${COMP_WORDS[i]}" in
+            'my-cmd:sub1') cmd='my-cmd sub1' ;;
+            'my-cmd sub1:sub2') cmd='my-cmd sub1 sub2' ;;
+            'my-cmd:completion') cmd='my-cmd completion' ;;
+            'my-cmd completion:bash') cmd='my-cmd completion bash' ;;
+            'my-cmd completion:zsh') cmd='my-cmd completion zsh' ;;
+            'my-cmd completion:fish') cmd='my-cmd completion fish' ;;
+        esac
+    done
+
+    local commands="" flags=""
+    case "${cmd}" in
+        'my-cmd')
+            commands='sub1'
+            flags='--help -h --verbose -v'
+            ;;
+        'my-cmd sub1')
+            commands='sub2'
+            flags='--help -h'
+            ;;
+        'my-cmd sub1 sub2')
+            commands=''
+            flags='--force --help -h'
+            ;;
+        'my-cmd completion')
+            commands='bash zsh fish'
+            flags='--help -h'
+            ;;
+        'my-cmd completion bash')
+            commands=''
+            flags='--help -h'
+            ;;
+        'my-cmd completion zsh')
+            commands=''
+            flags='--help -h'
+            ;;
+        'my-cmd completion fish')
+            commands=''
+            flags='--help -h'
+            ;;
+    esac
+
+    if [[ "${cur}" == -* ]]; then
+        COMPREPLY=($(compgen -W "${flags}" -- "${cur}"))
+    else
+        COMPREPLY=($(compgen -W "${commands}" -- "${cur}"))
+    fi
+}
+
+complete -F _my_cmd_completions my-cmd
+`[1:])).OrFail()
+}
+
+func TestGenZshCompletion(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	root := newCompletionTestCommand(t, b)
+	With(t).Verify(root.GenZshCompletion(b)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`
+#compdef my-cmd
+
+_my_cmd() {
+    local cmd='my-cmd'
+    local i
+    for ((i = 2; i < CURRENT; i++)); do
+        case "${cmd}:${words[i]}" in
+            'my-cmd:sub1') cmd='my-cmd sub1' ;;
+            'my-cmd sub1:sub2') cmd='my-cmd sub1 sub2' ;;
+            'my-cmd:completion') cmd='my-cmd completion' ;;
+            'my-cmd completion:bash') cmd='my-cmd completion bash' ;;
+            'my-cmd completion:zsh') cmd='my-cmd completion zsh' ;;
+            'my-cmd completion:fish') cmd='my-cmd completion fish' ;;
+        esac
+    done
+
+    local -a commands flags
+    case "${cmd}" in
+        'my-cmd')
+            commands=(
+                'sub1:sub1 desc'
+            )
+            flags=(
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+                '--verbose:Verbose output.'
+                '-v:Verbose output.'
+            )
+            ;;
+        'my-cmd sub1')
+            commands=(
+                'sub2:sub2 desc'
+            )
+            flags=(
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+            )
+            ;;
+        'my-cmd sub1 sub2')
+            commands=(
+            )
+            flags=(
+                '--force:Force it.'
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+            )
+            ;;
+        'my-cmd completion')
+            commands=(
+                'bash:Generate the bash completion script.'
+                'zsh:Generate the zsh completion script.'
+                'fish:Generate the fish completion script.'
+            )
+            flags=(
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+            )
+            ;;
+        'my-cmd completion bash')
+            commands=(
+            )
+            flags=(
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+            )
+            ;;
+        'my-cmd completion zsh')
+            commands=(
+            )
+            flags=(
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+            )
+            ;;
+        'my-cmd completion fish')
+            commands=(
+            )
+            flags=(
+                '--help:Show this help screen and exit.'
+                '-h:Show this help screen and exit.'
+            )
+            ;;
+    esac
+
+    if [[ "${words[CURRENT]}" == -* ]]; then
+        _describe -t flags 'flag' flags
+    else
+        _describe -t commands 'command' commands
+    fi
+}
+
+# Support both autoloading (via "fpath") and sourcing this script directly
+if [[ "${funcstack[1]}" == _my_cmd ]]; then
+    _my_cmd "$@"
+else
+    compdef _my_cmd my-cmd
+fi
+`[1:])).OrFail()
+}
+
+func TestGenFishCompletion(t *testing.T) {
+	t.Parallel()
+
+	b := &bytes.Buffer{}
+	root := newCompletionTestCommand(t, b)
+	With(t).Verify(root.GenFishCompletion(b)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`
+# fish completion for my-cmd
+
+function __my_cmd_using_command
+    set -l cmd 'my-cmd'
+    for word in (commandline -opc)[2..-1]
+        switch "$cmd:$word"
+            case 'my-cmd:sub1'
+                set cmd 'my-cmd sub1'
+            case 'my-cmd sub1:sub2'
+                set cmd 'my-cmd sub1 sub2'
+            case 'my-cmd:completion'
+                set cmd 'my-cmd completion'
+            case 'my-cmd completion:bash'
+                set cmd 'my-cmd completion bash'
+            case 'my-cmd completion:zsh'
+                set cmd 'my-cmd completion zsh'
+            case 'my-cmd completion:fish'
+                set cmd 'my-cmd completion fish'
+        end
+    end
+    test "$cmd" = "$argv[1]"
+end
+
+complete -c 'my-cmd' -f -n '__my_cmd_using_command \'my-cmd\'' -a 'sub1' -d 'sub1 desc'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd\'' -l 'verbose' -s 'v' -d 'Verbose output.'
+complete -c 'my-cmd' -f -n '__my_cmd_using_command \'my-cmd sub1\'' -a 'sub2' -d 'sub2 desc'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd sub1\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd sub1 sub2\'' -l 'force' -d 'Force it.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd sub1 sub2\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+complete -c 'my-cmd' -f -n '__my_cmd_using_command \'my-cmd completion\'' -a 'bash' -d 'Generate the bash completion script.'
+complete -c 'my-cmd' -f -n '__my_cmd_using_command \'my-cmd completion\'' -a 'zsh' -d 'Generate the zsh completion script.'
+complete -c 'my-cmd' -f -n '__my_cmd_using_command \'my-cmd completion\'' -a 'fish' -d 'Generate the fish completion script.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd completion\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd completion bash\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd completion zsh\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+complete -c 'my-cmd' -n '__my_cmd_using_command \'my-cmd completion fish\'' -l 'help' -s 'h' -d 'Show this help screen and exit.'
+`[1:])).OrFail()
+}
+
+func TestBashQuote(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		value    string
+		expected string
+	}
+	testCases := map[string]testCase{
+		"plain":         {value: "my-cmd sub", expected: `'my-cmd sub'`},
+		"empty":         {value: "", expected: `''`},
+		"single quotes": {value: "it's", expected: `'it'\''s'`},
+		"expansions":    {value: `$HOME "x" $(id) \n`, expected: `'$HOME "x" $(id) \n'`},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			With(t).Verify(bashQuote(tc.value)).Will(EqualTo(tc.expected)).OrFail()
+		})
+	}
+}
+
+func TestCompletionCommandIsHidden(t *testing.T) {
+	t.Parallel()
+	completionCmd, err := NewCompletionCommand(&bytes.Buffer{})
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	With(t).Verify(completionCmd.hidden).Will(EqualTo(true)).OrFail()
+
+	b := &bytes.Buffer{}
+	root := MustNew("my-cmd", "desc", "", nil, nil, completionCmd)
+	With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"--help"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+	With(t).Verify(b.String()).Will(Say(`(?m)^Usage:`)).OrFail()
+	With(t).Verify(b.String()).Will(Not(Say(`completion`))).OrFail()
+}
+
+func TestCompletionCommand(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		generator func(*Command, io.Writer) error
+	}
+	testCases := map[string]testCase{
+		"bash": {generator: (*Command).GenBashCompletion},
+		"zsh":  {generator: (*Command).GenZshCompletion},
+		"fish": {generator: (*Command).GenFishCompletion},
+	}
+	for shell, tc := range testCases {
+		shell, tc := shell, tc
+		t.Run(shell, func(t *testing.T) {
+			t.Parallel()
+
+			b := &bytes.Buffer{}
+			root := newCompletionTestCommand(t, b)
+			expected := &bytes.Buffer{}
+			With(t).Verify(tc.generator(root, expected)).Will(Succeed()).OrFail()
+
+			With(t).Verify(ExecuteWithContext(context.Background(), b, root, []string{"completion", shell}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(expected.String())).OrFail()
+		})
+	}
+}
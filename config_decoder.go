@@ -0,0 +1,72 @@
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ConfigDecoder decodes configuration files (see [Command.EnableConfigFile]) into flag values, mapped by flag names
+// (e.g. "my-flag"). Flags that accept multiple values (e.g. slices) can be given more than one value.
+type ConfigDecoder interface {
+	Decode(r io.Reader) (map[string][]string, error)
+}
+
+type ConfigDecoderFunc func(r io.Reader) (map[string][]string, error)
+
+func (i ConfigDecoderFunc) Decode(r io.Reader) (map[string][]string, error) {
+	if i != nil {
+		return i(r)
+	} else {
+		return nil, nil
+	}
+}
+
+// JSONConfigDecoder is the default configuration file decoder, used for files whose extension has no registered
+// decoder (see [Command.RegisterConfigDecoder]). It decodes a JSON object whose keys are flag names, and whose values
+// are strings, numbers, booleans, or arrays of those (for flags that accept multiple values).
+var JSONConfigDecoder ConfigDecoder = ConfigDecoderFunc(decodeJSONConfig)
+
+func decodeJSONConfig(r io.Reader) (map[string][]string, error) {
+	var rawValues map[string]any
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&rawValues); err != nil {
+		return nil, err
+	}
+
+	// Translate the decoded values into flag values
+	values := make(map[string][]string, len(rawValues))
+	for name, rawValue := range rawValues {
+		var items []any
+		if arr, ok := rawValue.([]any); ok {
+			items = arr
+		} else {
+			items = []any{rawValue}
+		}
+		for _, item := range items {
+			switch v := item.(type) {
+			case string:
+				values[name] = append(values[name], v)
+			case json.Number:
+				values[name] = append(values[name], v.String())
+			case bool:
+				values[name] = append(values[name], strconv.FormatBool(v))
+			default:
+				return nil, fmt.Errorf("unsupported value for flag '%s': %v", name, item)
+			}
+		}
+	}
+	return values, nil
+}
+
+// normalizeConfigFileExt normalizes the given file extension into a lower-case extension with a leading dot (e.g.
+// "YAML" becomes ".yaml"); empty extensions are left as-is.
+func normalizeConfigFileExt(ext string) string {
+	if ext == "" {
+		return ""
+	}
+	return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+}
@@ -5,6 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type ExitCode int
@@ -13,93 +18,433 @@ const (
 	ExitCodeSuccess          ExitCode = 0
 	ExitCodeError            ExitCode = 1
 	ExitCodeMisconfiguration ExitCode = 2
+	ExitCodeTimeout          ExitCode = 124
 )
 
+// exitCodeNames maps the well-known exit codes to their names.
+var exitCodeNames = map[ExitCode]string{
+	ExitCodeSuccess:          "success",
+	ExitCodeError:            "error",
+	ExitCodeMisconfiguration: "misconfiguration",
+	ExitCodeTimeout:          "timeout",
+}
+
+// String returns the name of the exit code (e.g. "success" or "misconfiguration"), or "code(N)" for exit codes that
+// have no name.
+func (e ExitCode) String() string {
+	if name, ok := exitCodeNames[e]; ok {
+		return name
+	}
+	return fmt.Sprintf("code(%d)", int(e))
+}
+
+// ParseExitCode parses the given exit code, which is either the name of a well-known exit code (e.g. "success"), a
+// "code(N)" string (as returned by [ExitCode.String]), or a plain number.
+func ParseExitCode(s string) (ExitCode, error) {
+	for code, name := range exitCodeNames {
+		if s == name {
+			return code, nil
+		}
+	}
+	number := s
+	if v, found := strings.CutPrefix(s, "code("); found {
+		if v, found = strings.CutSuffix(v, ")"); found {
+			number = v
+		}
+	}
+	if n, err := strconv.Atoi(number); err != nil {
+		return 0, fmt.Errorf("invalid exit code '%s'", s)
+	} else {
+		return ExitCode(n), nil
+	}
+}
+
+// Phase is the phase of command execution in which an error occurred (see [ErrorHandler]).
+type Phase int
+
+const (
+	ParsePhase   Phase = iota // Resolving the command & applying CLI args & environment variables to it
+	PreRunPhase               // Invoking the pre-run hooks
+	ActionPhase               // Running the command's action (or printing the help screen instead)
+	PostRunPhase              // Invoking the post-run hooks
+)
+
+// String returns the name of the phase (e.g. "pre-run").
+func (p Phase) String() string {
+	switch p {
+	case ParsePhase:
+		return "parse"
+	case PreRunPhase:
+		return "pre-run"
+	case ActionPhase:
+		return "action"
+	case PostRunPhase:
+		return "post-run"
+	default:
+		return fmt.Sprintf("phase(%d)", int(p))
+	}
+}
+
+// ErrorHandler handles errors that occur during command execution, in place of the default behavior of printing them
+// (along with the usage line, for parse errors), and returns the exit code to use for them. See [WithErrorHandler].
+type ErrorHandler func(err error, phase Phase) ExitCode
+
+// ExecuteOption customizes the execution of commands by [Execute], [ExecuteWithContext] & [ExecuteE].
+type ExecuteOption func(*executeOptions)
+
+type executeOptions struct {
+	stdout       io.Writer
+	stderr       io.Writer
+	width        int
+	color        *bool
+	errorHandler ErrorHandler
+	dryRun       bool
+	logger       *slog.Logger
+}
+
+// newExecuteOptions creates the execution options from the given options, defaulting to writing all output to the given
+// writer, to the terminal's width, and to colorizing help screens only if they're written to a terminal.
+func newExecuteOptions(w io.Writer, opts ...ExecuteOption) *executeOptions {
+	options := &executeOptions{stdout: w, stderr: w}
+	for _, opt := range opts {
+		opt(options)
+	}
+	if options.width <= 0 {
+		options.width = getTerminalWidth()
+	}
+	if options.color == nil {
+		options.color = ptrOf(shouldColorize(options.stdout))
+	}
+	if options.logger == nil {
+		options.logger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	}
+	return options
+}
+
+// handleError handles the given error of the given phase using the error handler, if one was given, returning the exit
+// code it decides on. Otherwise, the error is printed to the error writer, and the given default exit code is returned.
+func (o *executeOptions) handleError(err error, phase Phase, defaultExitCode ExitCode) ExitCode {
+	if o.errorHandler != nil {
+		return o.errorHandler(err, phase)
+	}
+	_, _ = fmt.Fprintln(o.stderr, err)
+	return defaultExitCode
+}
+
+// WithStdout sets the writer that help screens & version information are written to, instead of the writer given to
+// the execution function.
+func WithStdout(w io.Writer) ExecuteOption {
+	return func(o *executeOptions) { o.stdout = w }
+}
+
+// WithStderr sets the writer that errors, usage lines & warnings are written to, instead of the writer given to the
+// execution function.
+func WithStderr(w io.Writer) ExecuteOption {
+	return func(o *executeOptions) { o.stderr = w }
+}
+
+// WithWidth sets the width that help screens & usage lines are wrapped at, instead of the terminal's width.
+func WithWidth(width int) ExecuteOption {
+	return func(o *executeOptions) { o.width = width }
+}
+
+// WithColor sets whether help screens are colorized, instead of auto-detecting it (help screens are colorized by default
+// only if written to a terminal, and the "NO_COLOR" environment variable is not set).
+func WithColor(enabled bool) ExecuteOption {
+	return func(o *executeOptions) { o.color = &enabled }
+}
+
+// WithErrorHandler sets a handler that renders errors (e.g. as JSON, or to a logger) and decides on their exit codes,
+// instead of printing them to the error writer. Errors of post-run hooks are handled separately from the error of the
+// phase that preceded them, and the exit code returned for them replaces the previous exit code.
+func WithErrorHandler(handler ErrorHandler) ExecuteOption {
+	return func(o *executeOptions) { o.errorHandler = handler }
+}
+
+// WithDryRun sets whether to only validate the command line & environment variables, without running anything. In dry
+// run mode, the command is resolved & its flags & positional arguments are applied & validated as usual, but instead of
+// invoking its hooks & action, a summary of the resolved command, its flags & its positional arguments is written to
+// the output writer (see [WithStdout]). This is useful for validating configuration, e.g. in CI pipelines.
+func WithDryRun(enabled bool) ExecuteOption {
+	return func(o *executeOptions) { o.dryRun = enabled }
+}
+
+// WithLogger sets a logger that the execution lifecycle is logged to at debug level: the resolved command, and the start
+// & finish (with duration & error, if any) of each pre-run hook, the action & each post-run hook. This is useful for
+// observing the order in which hooks run. Nothing is logged by default.
+func WithLogger(logger *slog.Logger) ExecuteOption {
+	return func(o *executeOptions) { o.logger = logger }
+}
+
+// Resolve infers the command to invoke in this command hierarchy (which must start at this command) from the given CLI
+// args, and applies the given CLI args & environment variables to its configuration structs. The resolved command is
+// returned, but none of its hooks or action are invoked; this is useful for testing & embedding.
+//
+// Note that if "--help" or "--version" are given, they are applied to the root command's [HelpConfig] &
+// [VersionConfig] respectively, and it is up to the caller to act on them.
+func (c *Command) Resolve(args []string, envVars map[string]string) (*Command, error) {
+	_, cmd, _, err := c.resolve(io.Discard, args, envVars)
+	return cmd, err
+}
+
+// resolve infers the command requested by the given CLI args & the command that should actually be invoked for it
+// (which differ when the requested command falls through to its default sub-command), and applies the given CLI args &
+// environment variables to the latter's configuration structs. Warnings (e.g. about deprecated flags) are written to
+// the given writer. The positional arguments given to the invoked command are returned as well.
+//
+// The invoked command is returned even when an error is returned (unless this command is not the root command), so
+// that callers can print its usage line.
+func (c *Command) resolve(w io.Writer, args []string, envVars map[string]string) (requested, cmd *Command, positionals []string, err error) {
+
+	// We insist on getting the root command - so that we can infer correctly which command the user wanted to invoke
+	if c.parent != nil {
+		return nil, nil, nil, fmt.Errorf("%w: command must be the root command", errors.ErrUnsupported)
+	}
+
+	// Extract the command, CLI flags, positional arguments & the command hierarchy
+	flags, positionals, rawArgs, requested, err := c.inferCommandAndArgs(args)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// If the requested command has no action, fall through to its default sub-command (if any)
+	if cmd, err = requested.resolveDefaultSubCommand(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Arguments given after the "--" separator are either captured verbatim by "rawargs" fields (if the command has any),
+	// or treated as positional arguments
+	captureRawArgs := cmd.flags.hasRawArgsTargets()
+	if !captureRawArgs {
+		positionals = append(positionals, rawArgs...)
+	}
+
+	// In strict mode, fail on unknown sub-commands rather than treating them as positional arguments
+	if err := cmd.verifySubCommand(positionals); err != nil {
+		return requested, cmd, positionals, err
+	}
+
+	// Normalize environment variable names if they should be matched case-insensitively (declared names are upper-case)
+	if c.envCaseFold {
+		envVars = upperCaseKeys(envVars)
+	}
+
+	// Apply the CLI args & environment variables to the configuration structs
+	// Note that the "--help" & "--version" flags are bound to the root's configuration structs, and inherited by all
+	// sub-commands
+	// Positional arguments are separated from the flags, so that ones starting with "-" are not parsed as flags (unless a
+	// flag is missing its value, in which case positional arguments must not be mistaken for it)
+	applyArgs := flags
+	if len(positionals) > 0 && !cmd.flags.isMissingFlagValue(flags) {
+		applyArgs = append(append(slices.Clone(flags), "--"), positionals...)
+	}
+	if err := cmd.flags.apply(w, envVars, applyArgs); err != nil {
+		return requested, cmd, positionals, err
+	} else if captureRawArgs {
+		cmd.flags.setRawArgs(rawArgs)
+	}
+	return requested, cmd, positionals, nil
+}
+
+// printDryRunSummary prints the summary of what would be run for this command (see [WithDryRun]): its full name, the
+// values of its visible flags (along with the source of each value, and masking values of secret flags), and the given
+// positional arguments.
+func (c *Command) printDryRunSummary(w io.Writer, positionals []string) error {
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	if c.action != nil {
+		_, _ = fmt.Fprintf(w, "command: %s\n", c.getFullName())
+	} else {
+		_, _ = fmt.Fprintf(w, "command: %s (no action, would print help)\n", c.getFullName())
+	}
+	_, _ = fmt.Fprintln(w, "flags:")
+	for _, mfd := range mergedFlagDefs {
+		value := formatTargetValue(mfd.flagDefs[0].Targets[0])
+		if mfd.Secret && value != "" {
+			value = "***"
+		}
+		_, _ = fmt.Fprintf(w, "  --%s=%s (%s)\n", mfd.Name, value, c.flags.valueSources[mfd.Name])
+	}
+	_, _ = fmt.Fprintf(w, "positionals: %s\n", strings.Join(positionals, " "))
+	return nil
+}
+
+// ExitCoder is an interface that errors returned from actions & pre-run hooks can implement to control the exit code
+// returned for them, instead of the default [ExitCodeError]. Wrapped errors are also considered (see [errors.As]).
+type ExitCoder interface {
+	ExitCode() ExitCode
+}
+
+// exitCodeForError returns the exit code for the given action or pre-run hook error, which is taken from the error if
+// it implements [ExitCoder], and defaults to [ExitCodeError] otherwise.
+func exitCodeForError(err error) ExitCode {
+	var exitCoder ExitCoder
+	if errors.As(err, &exitCoder) {
+		return exitCoder.ExitCode()
+	}
+	return ExitCodeError
+}
+
+// verbosityContextKey is the key of the verbosity level in contexts given to hooks & actions.
+type verbosityContextKey struct{}
+
+// VerbosityFromContext returns the verbosity level stored in the given context, which is given to hooks & actions by
+// [ExecuteWithContext]: -1 if "--quiet" was given, otherwise the number of times "-v" (or "--verbose") was given. Zero
+// is returned if verbosity flags were not enabled (see [Command.EnableVerbosity]) or if the context has no level.
+func VerbosityFromContext(ctx context.Context) int {
+	if v, ok := ctx.Value(verbosityContextKey{}).(int); ok {
+		return v
+	}
+	return 0
+}
+
 // ExecuteWithContext the correct command in the given command hierarchy (starting at "root"), configured from the given
 // CLI args and environment variables. The command will be executed with the given context after all pre-RunFunc hooks
 // have been successfully executed in the command hierarchy.
-func ExecuteWithContext(ctx context.Context, w io.Writer, root *Command, args []string, envVars map[string]string) (exitCode ExitCode) {
+func ExecuteWithContext(ctx context.Context, w io.Writer, root *Command, args []string, envVars map[string]string, opts ...ExecuteOption) ExitCode {
+	exitCode, _ := ExecuteE(ctx, w, root, args, envVars, opts...)
+	return exitCode
+}
+
+// ExecuteE is similar to [ExecuteWithContext], except that in addition to the exit code, it also returns the error that
+// caused it (if any) - e.g. a parse error, a hook error or an action error - so it can be inspected programmatically.
+// Errors are still printed as well. Errors of post-run hooks are joined with the error they were
+// given (see [errors.Join]).
+func ExecuteE(ctx context.Context, w io.Writer, root *Command, args []string, envVars map[string]string, opts ...ExecuteOption) (exitCode ExitCode, err error) {
 	exitCode = ExitCodeSuccess
+	options := newExecuteOptions(w, opts...)
+	stdout, stderr, logger := options.stdout, options.stderr, options.logger
 
-	// We insist on getting the root command - so that we can infer correctly which command the user wanted to invoke
-	if root.parent != nil {
-		_, _ = fmt.Fprintf(w, "%s: command must be the root command", errors.ErrUnsupported)
+	// Resolve the command & apply CLI flags, positional arguments & environment variables to it
+	requested, cmd, positionals, err := root.resolve(stderr, args, envVars)
+	if err != nil && options.errorHandler != nil {
+		exitCode = options.errorHandler(err, ParsePhase)
+		return
+	} else if cmd == nil && root.parent != nil {
+		// Not given the root command; no command could be resolved at all
+		_, _ = fmt.Fprint(stderr, err)
 		exitCode = ExitCodeError
 		return
-	}
-
-	// Extract the command, CLI flags, positional arguments & the command hierarchy
-	flags, positionals, cmd := root.inferCommandAndArgs(args)
-
-	// Create flagSet & apply it to the configuration structs
-	// If "--help" is given, print help and exit
-	if err := cmd.flags.apply(envVars, append(flags, positionals...)); err != nil {
-		_, _ = fmt.Fprintln(w, err)
-		if err := cmd.PrintUsageLine(w, getTerminalWidth()); err != nil {
-			_, _ = fmt.Fprintf(w, "%s\n", err)
+	} else if cmd == nil {
+		// A lazily built sub-command failed to build
+		_, _ = fmt.Fprintln(stderr, err)
+		exitCode = ExitCodeError
+		return
+	} else if err != nil {
+		_, _ = fmt.Fprintln(stderr, err)
+		if usageErr := cmd.PrintUsageLine(stderr, options.width); usageErr != nil {
+			_, _ = fmt.Fprintf(stderr, "%s\n", usageErr)
+			err = errors.Join(err, usageErr)
 			exitCode = ExitCodeError
 			return
 		} else {
 			exitCode = ExitCodeMisconfiguration
 			return
 		}
-	} else if cmd.HelpConfig.Help {
-		if err := cmd.PrintHelp(w, getTerminalWidth()); err != nil {
-			_, _ = fmt.Fprintf(w, "%s\n", err)
-			exitCode = ExitCodeMisconfiguration
+	}
+	logger.DebugContext(ctx, "Resolved command", "command", cmd.getFullName(), "positionals", positionals)
+
+	// If "--help" or "--version" is given, print help or version and exit
+	if !root.noAutoHelp && root.HelpConfig.Help {
+		if err = requested.printHelp(stdout, options.width, *options.color); err != nil {
+			exitCode = options.handleError(err, ActionPhase, ExitCodeMisconfiguration)
 			return
 		} else {
 			exitCode = ExitCodeSuccess
 			return
 		}
+	} else if root.VersionConfig.Version {
+		_, _ = fmt.Fprintf(stdout, "%s %s\n", root.name, root.version)
+		exitCode = ExitCodeSuccess
+		return
+	} else if options.dryRun {
+		if err = cmd.printDryRunSummary(stdout, positionals); err != nil {
+			exitCode = options.handleError(err, ActionPhase, ExitCodeError)
+		}
+		return
 	}
 
 	// Results
 	var actionError error
 
+	// Make the verbosity level available to hooks & the action, if enabled
+	postHooksCtx := context.Background()
+	if root.verbosity {
+		ctx = context.WithValue(ctx, verbosityContextKey{}, root.VerbosityConfig.level())
+		postHooksCtx = context.WithValue(postHooksCtx, verbosityContextKey{}, root.VerbosityConfig.level())
+	}
+
 	// Ensure we invoke post-run hooks before we return
 	chain := cmd.getChain()
 	defer func() {
-		postHooksCtx := context.Background()
+		err = actionError
 		for i := len(chain) - 1; i >= 0; i-- {
 			c := chain[i]
 			for j := len(c.postRunHooks) - 1; j >= 0; j-- {
 				h := c.postRunHooks[j]
-				if err := h.PostRun(postHooksCtx, actionError, exitCode); err != nil {
-					_, _ = fmt.Fprintln(w, err)
-					exitCode = ExitCodeError
+				logger.DebugContext(postHooksCtx, "Running post-run hook", "command", c.getFullName(), "hook", fmt.Sprintf("%T", h))
+				start := time.Now()
+				hookErr := h.PostRun(postHooksCtx, actionError, exitCode)
+				logger.DebugContext(postHooksCtx, "Post-run hook finished", "command", c.getFullName(), "hook", fmt.Sprintf("%T", h), "duration", time.Since(start), "error", hookErr)
+				if hookErr != nil {
+					err = errors.Join(err, hookErr)
+					exitCode = options.handleError(hookErr, PostRunPhase, ExitCodeError)
 				}
 			}
 		}
 	}()
 
-	// Invoke all "PreRun" hooks on the whole chain of commands (starting at the root)
-	for i := 0; i < len(chain); i++ {
-		c := chain[i]
-		for j := 0; j < len(c.preRunHooks); j++ {
-			h := c.preRunHooks[j]
-			if err := h.PreRun(ctx); err != nil {
-				_, _ = fmt.Fprintln(w, err)
-				actionError = err
-				exitCode = ExitCodeError
-				return
-			}
+	// Warn about deprecated commands in the chain (they are still executed normally)
+	for _, c := range chain {
+		if c.deprecated != "" {
+			_, _ = fmt.Fprintf(stderr, "command \"%s\" is deprecated: %s\n", c.getFullName(), c.deprecated)
+		}
+	}
+
+	// Invoke all "PreRun" hooks on the whole chain of commands (persistent ones first, starting at the root)
+	for _, h := range cmd.getPreRunHooks() {
+		logger.DebugContext(ctx, "Running pre-run hook", "command", cmd.getFullName(), "hook", fmt.Sprintf("%T", h))
+		start := time.Now()
+		preRunErr := cmd.invokePreRunHook(ctx, h, positionals)
+		logger.DebugContext(ctx, "Pre-run hook finished", "command", cmd.getFullName(), "hook", fmt.Sprintf("%T", h), "duration", time.Since(start), "error", preRunErr)
+		if preRunErr != nil {
+			actionError = preRunErr
+			exitCode = options.handleError(preRunErr, PreRunPhase, exitCodeForError(preRunErr))
+			return
 		}
 	}
 
 	// Run the command or print help screen if it's not a command
 	if cmd.action != nil {
-		if err := cmd.action.Run(ctx); err != nil {
-			_, _ = fmt.Fprintln(w, err)
-			actionError = err
-			exitCode = ExitCodeError
+
+		// Limit the action's duration if a timeout was given
+		actionCtx := ctx
+		if root.timeout && root.TimeoutConfig.Timeout > 0 {
+			var cancel context.CancelFunc
+			actionCtx, cancel = context.WithTimeout(ctx, root.TimeoutConfig.Timeout)
+			defer cancel()
+		}
+
+		logger.DebugContext(actionCtx, "Running action", "command", cmd.getFullName(), "action", fmt.Sprintf("%T", cmd.action))
+		start := time.Now()
+		runErr := cmd.action.Run(actionCtx)
+		logger.DebugContext(actionCtx, "Action finished", "command", cmd.getFullName(), "action", fmt.Sprintf("%T", cmd.action), "duration", time.Since(start), "error", runErr)
+		if runErr != nil {
+			actionError = runErr
+			if errors.Is(actionCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				exitCode = options.handleError(runErr, ActionPhase, ExitCodeTimeout)
+			} else {
+				exitCode = options.handleError(runErr, ActionPhase, exitCodeForError(runErr))
+			}
 		}
 	} else {
 		// Command is not a runner - print help
-		if err := cmd.PrintHelp(w, getTerminalWidth()); err != nil {
-			_, _ = fmt.Fprintf(w, "%s\n", err)
-			actionError = err
-			exitCode = ExitCodeError
+		if helpErr := cmd.printHelp(stdout, options.width, *options.color); helpErr != nil {
+			actionError = helpErr
+			exitCode = options.handleError(helpErr, ActionPhase, ExitCodeError)
 		}
 	}
 	return
@@ -110,10 +455,10 @@ func ExecuteWithContext(ctx context.Context, w io.Writer, root *Command, args []
 // for termination is received, after all pre-RunFunc hooks have been successfully executed in the command hierarchy.
 //
 //goland:noinspection GoUnusedExportedFunction
-func Execute(w io.Writer, root *Command, args []string, envVars map[string]string) ExitCode {
+func Execute(w io.Writer, root *Command, args []string, envVars map[string]string, opts ...ExecuteOption) ExitCode {
 	// Prepare a context that gets canceled if OS termination signals are sent
 	ctx, cancel := context.WithCancel(SetupSignalHandler())
 	defer cancel()
 
-	return ExecuteWithContext(ctx, w, root, args, envVars)
+	return ExecuteWithContext(ctx, w, root, args, envVars, opts...)
 }
@@ -3,12 +3,18 @@ package command
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	. "github.com/arikkfir/justest"
+	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 )
 
@@ -93,7 +99,7 @@ func TestExecute(t *testing.T) {
 		b := &bytes.Buffer{}
 		With(t).Verify(ExecuteWithContext(ctx, b, cmd, []string{"--bad-flag=V1"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
 		With(t).Verify(cmd.action.(*ActionWithConfig).MyFlag).Will(BeEmpty()).OrFail()
-		With(t).Verify(b.String()).Will(EqualTo("unknown flag: --bad-flag\nUsage: cmd [--help] [--my-flag=VALUE]\n")).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("unknown flag: --bad-flag\nUsage: cmd [-h, --help] [--my-flag=VALUE]\n")).OrFail()
 	})
 
 	t.Run("prints help on --help flag", func(t *testing.T) {
@@ -107,16 +113,312 @@ cmd: desc
 Description: long desc
 
 Usage:
-    cmd [--help] [--my-flag=VALUE]
+    cmd [-h, --help] [--my-flag=VALUE]
 
 Flags:
-    [--help]            Show this help screen and exit. (default value: false, 
-                        environment variable: HELP)
-    [--my-flag=VALUE]   environment variable: MY_FLAG
+    [-h, --help]       Show this help screen and exit. (default value: false, 
+                       environment variable: HELP)
+    [--my-flag=VALUE]  environment variable: MY_FLAG
 
 `[1:])).OrFail()
 	})
 
+	t.Run("prints help on --help flag of sub-command", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "--help"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+		With(t).Verify(b).Will(Say(`^cmd sub: sub desc\n`)).OrFail()
+	})
+
+	t.Run("prints help on -h flag of sub-command", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "-h"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+		With(t).Verify(b).Will(Say(`^cmd sub: sub desc\n`)).OrFail()
+	})
+
+	t.Run("prints help on HELP environment variable", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub"}, map[string]string{"HELP": "true"})).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+		With(t).Verify(b).Will(Say(`^cmd sub: sub desc\n`)).OrFail()
+	})
+
+	t.Run("-h is left to flags that use it as their short name", func(t *testing.T) {
+		ctx := context.Background()
+		action := &struct {
+			ActionWithConfig
+			Host string `short:"h"`
+		}{}
+		cmd := MustNew("cmd", "desc", "long desc", action, nil)
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, cmd, []string{"-h", "example.com"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(action.Host).Will(EqualTo("example.com")).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("")).OrFail()
+	})
+
+	t.Run("prints version on --version flag", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		With(t).Verify(root.SetVersion("1.2.3")).Will(Succeed()).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"--version"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("cmd 1.2.3\n")).OrFail()
+
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "--version"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("cmd 1.2.3\n")).OrFail()
+
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "--help"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b).Will(Say(`\[--version\]\s+Show version information and exit.`)).OrFail()
+	})
+
+	t.Run("no --version flag without a version", func(t *testing.T) {
+		ctx := context.Background()
+		cmd := MustNew("cmd", "desc", "long desc", &ActionWithConfig{}, nil)
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, cmd, []string{"--version"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("unknown flag: --version\nUsage: cmd [-h, --help] [--my-flag=VALUE]\n")).OrFail()
+	})
+
+	t.Run("loads flag values from config file", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		With(t).Verify(root.EnableConfigFile()).Will(Succeed()).OrFail()
+
+		file := filepath.Join(t.TempDir(), "config.json")
+		With(t).Verify(os.WriteFile(file, []byte(`{"my-flag":"V1"}`), 0600)).Will(Succeed()).OrFail()
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"sub", "--config=" + file}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+	})
+
+	t.Run("loads flag values from config file with registered decoder", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		root.RegisterConfigDecoder("txt", ConfigDecoderFunc(func(r io.Reader) (map[string][]string, error) {
+			data, err := io.ReadAll(r)
+			return map[string][]string{"my-flag": {strings.TrimSpace(string(data))}}, err
+		}))
+		With(t).Verify(root.EnableConfigFile()).Will(Succeed()).OrFail()
+
+		file := filepath.Join(t.TempDir(), "config.txt")
+		With(t).Verify(os.WriteFile(file, []byte("V1\n"), 0600)).Will(Succeed()).OrFail()
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"sub", "--config=" + file}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+	})
+
+	t.Run("action timeout", func(t *testing.T) {
+		ctx := context.Background()
+		waitForCtx := ActionFunc(func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		postRunHook := &TrackingPostRunHook{}
+		sub := MustNew("sub", "desc", "", waitForCtx, []any{postRunHook})
+		root := MustNew("cmd", "desc", "", nil, nil, sub)
+		With(t).Verify(root.EnableTimeout()).Will(Succeed()).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "--timeout=50ms"}, nil)).Will(EqualTo(ExitCodeTimeout)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("context deadline exceeded\n")).OrFail()
+		With(t).Verify(postRunHook.callTime).Will(Not(BeNil())).OrFail()
+		With(t).Verify(postRunHook.providedExitCode).Will(EqualTo(ExitCodeTimeout)).OrFail()
+		With(t).Verify(postRunHook.providedCtx.Err()).Will(BeNil()).OrFail()
+	})
+
+	t.Run("no action timeout by default", func(t *testing.T) {
+		ctx := context.Background()
+		var deadlineSet bool
+		root := MustNew("cmd", "desc", "", ActionFunc(func(ctx context.Context) error {
+			_, deadlineSet = ctx.Deadline()
+			return nil
+		}), nil)
+		With(t).Verify(root.EnableTimeout()).Will(Succeed()).OrFail()
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, nil, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(deadlineSet).Will(EqualTo(false)).OrFail()
+	})
+
+	t.Run("verbosity level given to hooks & action", func(t *testing.T) {
+		type testCase struct {
+			args              []string
+			expectedVerbosity int
+		}
+		testCases := map[string]testCase{
+			"default":            {args: []string{"sub"}, expectedVerbosity: 0},
+			"verbose":            {args: []string{"sub", "-v"}, expectedVerbosity: 1},
+			"very verbose":       {args: []string{"-vv", "sub", "--verbose"}, expectedVerbosity: 3},
+			"quiet":              {args: []string{"sub", "--quiet"}, expectedVerbosity: -1},
+			"quiet wins":         {args: []string{"sub", "-q", "-v"}, expectedVerbosity: -1},
+			"verbose before sub": {args: []string{"--verbose", "sub"}, expectedVerbosity: 1},
+		}
+		for name, tc := range testCases {
+			tc := tc
+			t.Run(name, func(t *testing.T) {
+				var hookVerbosity, actionVerbosity, postHookVerbosity int
+				preRunHook := PreRunHookFunc(func(ctx context.Context) error {
+					hookVerbosity = VerbosityFromContext(ctx)
+					return nil
+				})
+				postRunHook := PostRunHookFunc(func(ctx context.Context, _ error, _ ExitCode) error {
+					postHookVerbosity = VerbosityFromContext(ctx)
+					return nil
+				})
+				sub := MustNew("sub", "desc", "", ActionFunc(func(ctx context.Context) error {
+					actionVerbosity = VerbosityFromContext(ctx)
+					return nil
+				}), []any{preRunHook, postRunHook})
+				root := MustNew("cmd", "desc", "", nil, nil, sub)
+				With(t).Verify(root.EnableVerbosity()).Will(Succeed()).OrFail()
+				With(t).Verify(ExecuteWithContext(context.Background(), os.Stderr, root, tc.args, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+				With(t).Verify(hookVerbosity).Will(EqualTo(tc.expectedVerbosity)).OrFail()
+				With(t).Verify(actionVerbosity).Will(EqualTo(tc.expectedVerbosity)).OrFail()
+				With(t).Verify(postHookVerbosity).Will(EqualTo(tc.expectedVerbosity)).OrFail()
+			})
+		}
+	})
+
+	t.Run("no verbosity flags by default", func(t *testing.T) {
+		var verbosity int
+		root := MustNew("cmd", "desc", "", ActionFunc(func(ctx context.Context) error {
+			verbosity = VerbosityFromContext(ctx)
+			return nil
+		}), nil)
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"-v"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(VerbosityFromContext(context.Background())).Will(EqualTo(0)).OrFail()
+		With(t).Verify(verbosity).Will(EqualTo(0)).OrFail()
+	})
+
+	t.Run("unknown sub-command treated as positional by default", func(t *testing.T) {
+		ctx := context.Background()
+		root := MustNew("cmd", "desc", "long desc", &ActionWithConfig{}, nil, MustNew("status", "desc", "", nil, nil))
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"stauts"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(root.action.(*ActionWithConfig).callTime).Will(Not(BeNil())).OrFail()
+	})
+
+	t.Run("unknown sub-command fails in strict mode", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "desc", "", nil, nil, MustNew("status", "desc", "", nil, nil))
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		root.SetStrict(true)
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "stauts"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("unknown command \"stauts\", did you mean \"status\"?\nUsage: cmd sub [-h, --help]\n")).OrFail()
+
+		b.Reset()
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"sub", "xyz"}, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("unknown command \"xyz\"\nUsage: cmd sub [-h, --help]\n")).OrFail()
+	})
+
+	t.Run("positionals allowed in strict mode when command accepts them", func(t *testing.T) {
+		ctx := context.Background()
+		action := &struct {
+			TrackingAction
+			Args []string `args:"true"`
+		}{}
+		root := MustNew("cmd", "desc", "long desc", action, nil, MustNew("status", "desc", "", nil, nil))
+		root.SetStrict(true)
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"stauts"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"stauts"})).OrFail()
+	})
+
+	t.Run("hidden sub-commands can be invoked", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("sub", "sub desc", "", &ActionWithConfig{}, nil)
+		sub.SetHidden(true)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"sub", "--my-flag=V1"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+	})
+
+	t.Run("default sub-command invoked when no sub-command given", func(t *testing.T) {
+		ctx := context.Background()
+		list := MustNew("list", "list desc", "", &ActionWithConfig{}, nil)
+		other := MustNew("other", "other desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "long desc", nil, nil, list, other)
+		With(t).Verify(root.SetDefaultSubCommand("unknown")).Will(Fail(`^invalid command: unknown sub-command 'unknown' of command 'cmd'$`)).OrFail()
+		With(t).Verify(root.SetDefaultSubCommand("list")).Will(Succeed()).OrFail()
+
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"--my-flag=V1"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(list.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+		With(t).Verify(other.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"other"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(other.action.(*ActionWithConfig).callTime).Will(Not(BeNil())).OrFail()
+	})
+
+	t.Run("help printed for command with default sub-command", func(t *testing.T) {
+		ctx := context.Background()
+		list := MustNew("list", "list desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "", nil, nil, list)
+		With(t).Verify(root.SetDefaultSubCommand("list")).Will(Succeed()).OrFail()
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"--help"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(strings.HasPrefix(b.String(), "cmd: desc\n")).Will(EqualTo(true)).OrFail()
+		With(t).Verify(list.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+	})
+
+	t.Run("deprecated sub-commands are invoked with a notice", func(t *testing.T) {
+		ctx := context.Background()
+		sub := MustNew("old", "old desc", "", &ActionWithConfig{}, nil)
+		sub.SetDeprecated("use 'new' instead")
+		root := MustNew("cmd", "desc", "long desc", nil, nil, sub)
+
+		b := &bytes.Buffer{}
+		With(t).Verify(ExecuteWithContext(ctx, b, root, []string{"old", "--my-flag=V1"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("command \"cmd old\" is deprecated: use 'new' instead\n")).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+	})
+
+	t.Run("persistent preRun hooks called before other preRun hooks", func(t *testing.T) {
+		ctx := context.Background()
+		var calls []string
+		hook := func(name string) PreRunHookFunc {
+			return func(context.Context) error { calls = append(calls, name); return nil }
+		}
+		persistentHook := func(name string) PersistentPreRunHookFunc {
+			return func(context.Context) error { calls = append(calls, name); return nil }
+		}
+		sub := MustNew("sub", "desc", "", &ActionWithConfig{}, []any{hook("sub"), persistentHook("sub-persistent")})
+		root := MustNew("cmd", "desc", "", nil, []any{hook("root"), persistentHook("root-persistent")}, sub)
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"sub"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(calls).Will(EqualTo([]string{"root-persistent", "sub-persistent", "root", "sub"})).OrFail()
+	})
+
+	t.Run("preRun hooks with info get command and positionals", func(t *testing.T) {
+		ctx := context.Background()
+		var hookCmd *Command
+		var hookPositionals []string
+		hook := PreRunHookWithInfoFunc(func(_ context.Context, cmd *Command, positionals []string) error {
+			hookCmd, hookPositionals = cmd, positionals
+			return nil
+		})
+		sub := MustNew("sub", "desc", "", &struct {
+			TrackingAction
+			Args []string `args:"true"`
+		}{}, nil)
+		root := MustNew("cmd", "desc", "", nil, []any{hook}, sub)
+		With(t).Verify(ExecuteWithContext(ctx, os.Stderr, root, []string{"sub", "a", "b"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(hookCmd).Will(EqualTo(sub, cmpopts.EquateComparable(&Command{}))).OrFail()
+		With(t).Verify(hookPositionals).Will(EqualTo([]string{"a", "b"})).OrFail()
+	})
+
 	t.Run("preRun called for command chain", func(t *testing.T) {
 		ctx := context.Background()
 		sub2 := MustNew("sub2", "desc", "long desc", &ActionWithConfig{}, []any{&PreRunHookWithConfig{}})
@@ -237,7 +539,7 @@ Flags:
 		b := &bytes.Buffer{}
 		With(t).Verify(ExecuteWithContext(ctx, b, root, nil, nil)).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
 		With(t).Verify(action.TrackingAction.callTime).Will(BeNil()).OrFail()
-		With(t).Verify(b.String()).Will(EqualTo("required flag is missing: --my-flag\nUsage: cmd [--help] --my-flag=VALUE\n")).OrFail()
+		With(t).Verify(b.String()).Will(EqualTo("required flag is missing: --my-flag\nUsage: cmd [-h, --help] --my-flag=VALUE\n")).OrFail()
 	})
 
 	t.Run("required flags with default value do not fail execution", func(t *testing.T) {
@@ -277,3 +579,636 @@ Flags:
 		}
 	})
 }
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	t.Run("command must be root", func(t *testing.T) {
+		t.Parallel()
+		sub := MustNew("sub", "desc", "", &ActionWithConfig{}, nil)
+		_ = MustNew("cmd", "desc", "", nil, nil, sub)
+		_, err := sub.Resolve(nil, nil)
+		With(t).Verify(err).Will(Fail(`^unsupported operation: command must be the root command$`)).OrFail()
+	})
+
+	t.Run("resolves command without invoking it", func(t *testing.T) {
+		t.Parallel()
+		preRunHook := &PreRunHookWithConfig{}
+		sub := MustNew("sub", "desc", "", &ActionWithConfig{}, []any{preRunHook})
+		root := MustNew("cmd", "desc", "", nil, nil, sub)
+		cmd, err := root.Resolve([]string{"sub", "--my-flag=V1"}, nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd).Will(EqualTo(sub, cmpopts.EquateComparable(&Command{}))).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+		With(t).Verify(sub.action.(*ActionWithConfig).callTime).Will(BeNil()).OrFail()
+		With(t).Verify(preRunHook.MyFlag).Will(EqualTo("V1")).OrFail()
+		With(t).Verify(preRunHook.callTime).Will(BeNil()).OrFail()
+	})
+
+	t.Run("applies environment variables", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+		cmd, err := root.Resolve(nil, map[string]string{"MY_FLAG": "V1"})
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+	})
+
+	t.Run("matches environment variables exactly by default", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+		cmd, err := root.Resolve(nil, map[string]string{"my_flag": "V1"})
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd.action.(*ActionWithConfig).MyFlag).Will(EqualTo("")).OrFail()
+	})
+
+	t.Run("matches environment variables case-insensitively", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+		root.SetEnvVarsCaseInsensitive(true)
+		cmd, err := root.Resolve(nil, map[string]string{"My_Flag": "V1"})
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd.action.(*ActionWithConfig).MyFlag).Will(EqualTo("V1")).OrFail()
+	})
+
+	t.Run("applies environment variable prefix", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Derived  string `flag:"true"`
+			Explicit string `env:"EXPLICIT"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		sub := MustNew("sub", "desc", "", action, nil)
+		root := MustNew("cmd", "desc", "", nil, nil, sub)
+		root.SetEnvPrefix("myapp")
+		envVars := map[string]string{"DERIVED": "V1", "MYAPP_DERIVED": "V2", "EXPLICIT": "V3", "MYAPP_EXPLICIT": "V4"}
+		_, err := root.Resolve([]string{"sub"}, envVars)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(action.Derived).Will(EqualTo("V2")).OrFail()
+		With(t).Verify(action.Explicit).Will(EqualTo("V3")).OrFail()
+	})
+
+	t.Run("reports value sources", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &struct {
+			Action
+			FromDefault string `flag:"true"`
+			FromEnv     string `flag:"true"`
+			FromCLI     string `flag:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+		With(t).Verify(root.ValueSources()).Will(BeNil()).OrFail()
+		cmd, err := root.Resolve([]string{"--from-cli=V1"}, map[string]string{"FROM_ENV": "V2", "FROM_CLI": "V3"})
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd.ValueSources()).Will(EqualTo(map[string]string{
+			"from-default": ValueSourceDefault,
+			"from-env":     ValueSourceEnv,
+			"from-cli":     ValueSourceCLI,
+			"help":         ValueSourceDefault,
+		})).OrFail()
+	})
+
+	t.Run("applies flag values given as separate arguments", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Output string   `flag:"true" inherited:"true"`
+			Args   []string `args:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		sub := MustNew("sub", "desc", "", &ActionWithConfig{}, nil)
+		root := MustNew("cmd", "desc", "", action, nil, sub)
+		cmd, err := root.Resolve(strings.Split("--output sub a", " "), nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd).Will(EqualTo(root, cmpopts.EquateComparable(&Command{}))).OrFail()
+		With(t).Verify(action.Output).Will(EqualTo("sub")).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"a"})).OrFail()
+	})
+
+	t.Run("captures raw arguments after separator", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Verbose bool     `flag:"true"`
+			Args    []string `args:"true"`
+			Raw     []string `rawargs:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		sub := MustNew("run", "desc", "", action, nil)
+		root := MustNew("cmd", "desc", "", nil, nil, sub)
+		_, err := root.Resolve(strings.Split("run --verbose a -- cmd --flag -- b", " "), nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(action.Verbose).Will(EqualTo(true)).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"a"})).OrFail()
+		With(t).Verify(action.Raw).Will(EqualTo([]string{"cmd", "--flag", "--", "b"})).OrFail()
+	})
+
+	t.Run("treats arguments after separator as positionals without raw arguments fields", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Args []string `args:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		root := MustNew("cmd", "desc", "", action, nil)
+		_, err := root.Resolve(strings.Split("a -- b c", " "), nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"a", "b", "c"})).OrFail()
+	})
+
+	t.Run("dash-prefixed arguments after separator are positionals", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Verbose bool     `flag:"true"`
+			Args    []string `args:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		root := MustNew("cmd", "desc", "", action, nil)
+		_, err := root.Resolve(strings.Split("--verbose -- -v1.2.3 --verbose", " "), nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(action.Verbose).Will(EqualTo(true)).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"-v1.2.3", "--verbose"})).OrFail()
+	})
+
+	t.Run("flags after positionals are parsed by default", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Verbose bool     `flag:"true"`
+			Args    []string `args:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		root := MustNew("cmd", "desc", "", action, nil)
+		_, err := root.Resolve(strings.Split("a --verbose b", " "), nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(action.Verbose).Will(EqualTo(true)).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"a", "b"})).OrFail()
+	})
+
+	t.Run("dash-prefixed arguments after positionals are positionals when flags are not interspersed", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Verbose bool     `flag:"true"`
+			Args    []string `args:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		sub := MustNew("release", "desc", "", action, nil)
+		sub.SetInterspersedFlags(false)
+		root := MustNew("cmd", "desc", "", nil, nil, sub)
+		_, err := root.Resolve(strings.Split("release --verbose v1 -v1.2.3 --verbose release -- -x", " "), nil)
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(action.Verbose).Will(EqualTo(true)).OrFail()
+		With(t).Verify(action.Args).Will(EqualTo([]string{"v1", "-v1.2.3", "--verbose", "release", "-x"})).OrFail()
+	})
+
+	t.Run("flag missing its value is not given positionals", func(t *testing.T) {
+		t.Parallel()
+		action := &struct {
+			Action
+			Name string   `flag:"true"`
+			Args []string `args:"true"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}
+		root := MustNew("cmd", "desc", "", action, nil)
+		_, err := root.Resolve(strings.Split("a --name", " "), nil)
+		With(t).Verify(err).Will(Fail(`^flag needs an argument: -name$`)).OrFail()
+	})
+
+	t.Run("reports changed flags", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &struct {
+			Action
+			FromDefault string `flag:"true"`
+			FromEnv     string `flag:"true"`
+			FromCLI     string `flag:"true"`
+			Verbose     bool   `flag:"true" short:"v"`
+		}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+		With(t).Verify(root.ChangedFlags()).Will(BeNil()).OrFail()
+		cmd, err := root.Resolve([]string{"--from-cli=V1", "-v"}, map[string]string{"FROM_ENV": "V2"})
+		With(t).Verify(err).Will(BeNil()).OrFail()
+		With(t).Verify(cmd.ChangedFlags()).Will(EqualTo([]string{"from-cli", "verbose"})).OrFail()
+	})
+
+	t.Run("exposes changed flags to pre-run hooks", func(t *testing.T) {
+		t.Parallel()
+		var changedFlags []string
+		hook := PreRunHookWithInfoFunc(func(_ context.Context, cmd *Command, _ []string) error {
+			changedFlags = cmd.ChangedFlags()
+			return nil
+		})
+		sub := MustNew("sub", "desc", "", &ActionWithConfig{}, []any{hook})
+		root := MustNew("cmd", "desc", "", nil, nil, sub)
+		With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, []string{"sub", "--my-flag=V1"}, nil)).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(changedFlags).Will(EqualTo([]string{"my-flag"})).OrFail()
+	})
+
+	t.Run("returns parse errors along with the command", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+		cmd, err := root.Resolve([]string{"--bad-flag"}, nil)
+		With(t).Verify(err).Will(Fail(`^unknown flag: --bad-flag$`)).OrFail()
+		With(t).Verify(cmd).Will(EqualTo(root, cmpopts.EquateComparable(&Command{}))).OrFail()
+	})
+}
+
+type exitCodeError struct {
+	error
+	exitCode ExitCode
+}
+
+func (e *exitCodeError) ExitCode() ExitCode {
+	return e.exitCode
+}
+
+func TestExitCodeString(t *testing.T) {
+	t.Parallel()
+	testCases := map[ExitCode]string{
+		ExitCodeSuccess:          "success",
+		ExitCodeError:            "error",
+		ExitCodeMisconfiguration: "misconfiguration",
+		ExitCodeTimeout:          "timeout",
+		ExitCode(3):              "code(3)",
+		ExitCode(-1):             "code(-1)",
+	}
+	for code, expected := range testCases {
+		code, expected := code, expected
+		t.Run(expected, func(t *testing.T) {
+			t.Parallel()
+			With(t).Verify(code.String()).Will(EqualTo(expected)).OrFail()
+			With(t).Verify(ParseExitCode(expected)).Will(EqualTo(code, nil)).OrFail()
+		})
+	}
+}
+
+func TestParseExitCode(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		value         string
+		expectedCode  ExitCode
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"name":              {value: "misconfiguration", expectedCode: ExitCodeMisconfiguration},
+		"code":              {value: "code(42)", expectedCode: ExitCode(42)},
+		"number":            {value: "2", expectedCode: ExitCodeMisconfiguration},
+		"unknown name":      {value: "failure", expectedError: `^invalid exit code 'failure'$`},
+		"invalid code":      {value: "code(x)", expectedError: `^invalid exit code 'code\(x\)'$`},
+		"unterminated code": {value: "code(1", expectedError: `^invalid exit code 'code\(1'$`},
+		"empty":             {value: "", expectedError: `^invalid exit code ''$`},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			code, err := ParseExitCode(tc.value)
+			if tc.expectedError != "" {
+				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(err).Will(BeNil()).OrFail()
+				With(t).Verify(code).Will(EqualTo(tc.expectedCode)).OrFail()
+			}
+		})
+	}
+}
+
+func TestExecuteCustomExitCodes(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		action           Action
+		hooks            []any
+		expectedExitCode ExitCode
+	}
+	notFoundErr := &exitCodeError{error: errors.New("not found"), exitCode: 3}
+	testCases := map[string]testCase{
+		"action error without exit code": {
+			action:           &TrackingAction{errorToReturnOnCall: errors.New("failed")},
+			expectedExitCode: ExitCodeError,
+		},
+		"action error with exit code": {
+			action:           &TrackingAction{errorToReturnOnCall: notFoundErr},
+			expectedExitCode: 3,
+		},
+		"wrapped action error with exit code": {
+			action:           &TrackingAction{errorToReturnOnCall: fmt.Errorf("failed: %w", notFoundErr)},
+			expectedExitCode: 3,
+		},
+		"pre-run hook error with exit code": {
+			action:           &TrackingAction{},
+			hooks:            []any{&TrackingPreRunHook{errorToReturnOnCall: notFoundErr}},
+			expectedExitCode: 3,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			postRunHook := &TrackingPostRunHook{}
+			root := MustNew("cmd", "desc", "", tc.action, append(tc.hooks, postRunHook))
+			With(t).Verify(ExecuteWithContext(context.Background(), io.Discard, root, nil, nil)).Will(EqualTo(tc.expectedExitCode)).OrFail()
+			With(t).Verify(postRunHook.providedExitCode).Will(EqualTo(tc.expectedExitCode)).OrFail()
+		})
+	}
+}
+
+func TestExecuteErrorHandler(t *testing.T) {
+	t.Parallel()
+	type handledError struct {
+		err   string
+		phase Phase
+	}
+	type testCase struct {
+		action           Action
+		hooks            []any
+		args             []string
+		expectedErrors   []handledError
+		expectedExitCode ExitCode
+	}
+	testCases := map[string]testCase{
+		"no error": {
+			action:           &TrackingAction{},
+			expectedExitCode: ExitCodeSuccess,
+		},
+		"parse error": {
+			action:           &TrackingAction{},
+			args:             []string{"--bad-flag"},
+			expectedErrors:   []handledError{{err: "unknown flag: --bad-flag", phase: ParsePhase}},
+			expectedExitCode: 10,
+		},
+		"pre-run hook error": {
+			action:           &TrackingAction{},
+			hooks:            []any{&TrackingPreRunHook{errorToReturnOnCall: errors.New("pre-run failed")}},
+			expectedErrors:   []handledError{{err: "pre-run failed", phase: PreRunPhase}},
+			expectedExitCode: 11,
+		},
+		"action error": {
+			action:           &TrackingAction{errorToReturnOnCall: errors.New("action failed")},
+			expectedErrors:   []handledError{{err: "action failed", phase: ActionPhase}},
+			expectedExitCode: 12,
+		},
+		"action & post-run hook errors": {
+			action:           &TrackingAction{errorToReturnOnCall: errors.New("action failed")},
+			hooks:            []any{&TrackingPostRunHook{errorToReturnOnCall: errors.New("post-run failed")}},
+			expectedErrors:   []handledError{{err: "action failed", phase: ActionPhase}, {err: "post-run failed", phase: PostRunPhase}},
+			expectedExitCode: 13,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var handledErrors []handledError
+			handler := func(err error, phase Phase) ExitCode {
+				handledErrors = append(handledErrors, handledError{err: err.Error(), phase: phase})
+				return ExitCode(10 + int(phase))
+			}
+			root := MustNew("cmd", "desc", "", tc.action, tc.hooks)
+			b := &bytes.Buffer{}
+			exitCode := ExecuteWithContext(context.Background(), b, root, tc.args, nil, WithErrorHandler(handler))
+			With(t).Verify(exitCode).Will(EqualTo(tc.expectedExitCode)).OrFail()
+			With(t).Verify(handledErrors).Will(EqualTo(tc.expectedErrors, cmp.AllowUnexported(handledError{}))).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo("")).OrFail()
+		})
+	}
+}
+
+func TestExecuteDryRun(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		args             []string
+		envVars          map[string]string
+		expectedOut      string
+		expectedExitCode ExitCode
+	}
+	testCases := map[string]testCase{
+		"valid command line": {
+			args:             []string{"sub", "--name=n1", "a", "b"},
+			envVars:          map[string]string{"PORT": "8080"},
+			expectedOut:      "command: cmd sub\nflags:\n  --help=false (default)\n  --name=n1 (cli)\n  --port=8080 (env)\n  --wait=1s (default)\npositionals: a b\n",
+			expectedExitCode: ExitCodeSuccess,
+		},
+		"missing required flag": {
+			args:             []string{"sub"},
+			expectedOut:      "required flag is missing: --name\nUsage: cmd sub [-h, --help] --name=VALUE [--port=VALUE] [--wait=VALUE] [ARGS...]\n",
+			expectedExitCode: ExitCodeMisconfiguration,
+		},
+		"invalid flag value": {
+			args:             []string{"sub", "--name=n1", "--port=abc"},
+			expectedOut:      "invalid value \"abc\" for flag -port: invalid value 'abc' for flag 'port': invalid syntax\nUsage: cmd sub [-h, --help] --name=VALUE [--port=VALUE] [--wait=VALUE] [ARGS...]\n",
+			expectedExitCode: ExitCodeMisconfiguration,
+		},
+		"command without action": {
+			expectedOut:      "command: cmd (no action, would print help)\nflags:\n  --help=false (default)\npositionals: \n",
+			expectedExitCode: ExitCodeSuccess,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			action := &TrackingAction{}
+			preRunHook := &TrackingPreRunHook{}
+			postRunHook := &TrackingPostRunHook{}
+			sub := MustNew("sub", "desc", "", &struct {
+				*TrackingAction
+				Name string        `flag:"true" required:"true"`
+				Port int           `flag:"true"`
+				Wait time.Duration `flag:"true"`
+				Args []string      `args:"true"`
+			}{TrackingAction: action, Wait: time.Second}, []any{preRunHook, postRunHook})
+			root := MustNew("cmd", "desc", "", nil, nil, sub)
+
+			b := &bytes.Buffer{}
+			With(t).Verify(ExecuteWithContext(context.Background(), b, root, tc.args, tc.envVars, WithDryRun(true))).Will(EqualTo(tc.expectedExitCode)).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOut)).OrFail()
+			With(t).Verify(action.callTime).Will(BeNil()).OrFail()
+			With(t).Verify(preRunHook.callTime).Will(BeNil()).OrFail()
+			With(t).Verify(postRunHook.callTime).Will(BeNil()).OrFail()
+		})
+	}
+}
+
+func TestPhaseString(t *testing.T) {
+	t.Parallel()
+	With(t).Verify(ParsePhase.String()).Will(EqualTo("parse")).OrFail()
+	With(t).Verify(PreRunPhase.String()).Will(EqualTo("pre-run")).OrFail()
+	With(t).Verify(ActionPhase.String()).Will(EqualTo("action")).OrFail()
+	With(t).Verify(PostRunPhase.String()).Will(EqualTo("post-run")).OrFail()
+	With(t).Verify(Phase(7).String()).Will(EqualTo("phase(7)")).OrFail()
+}
+
+func TestExecuteE(t *testing.T) {
+	t.Parallel()
+	actionErr := errors.New("action failed")
+	preRunErr := errors.New("pre-run failed")
+	postRunErr := errors.New("post-run failed")
+
+	t.Run("success", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+		exitCode, err := ExecuteE(context.Background(), io.Discard, root, nil, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeSuccess)).OrFail()
+		With(t).Verify(err).Will(BeNil()).OrFail()
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+		exitCode, err := ExecuteE(context.Background(), io.Discard, root, []string{"--bad-flag"}, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeMisconfiguration)).OrFail()
+		var unknownFlagErr *ErrUnknownFlag
+		With(t).Verify(errors.As(err, &unknownFlagErr)).Will(EqualTo(true)).OrFail()
+		With(t).Verify(unknownFlagErr.Flag).Will(EqualTo("bad-flag")).OrFail()
+	})
+
+	t.Run("pre-run hook error", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &ActionWithConfig{}, []any{&TrackingPreRunHook{errorToReturnOnCall: preRunErr}})
+		exitCode, err := ExecuteE(context.Background(), io.Discard, root, nil, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeError)).OrFail()
+		With(t).Verify(errors.Is(err, preRunErr)).Will(EqualTo(true)).OrFail()
+	})
+
+	t.Run("action error", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &TrackingAction{errorToReturnOnCall: actionErr}, nil)
+		exitCode, err := ExecuteE(context.Background(), io.Discard, root, nil, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeError)).OrFail()
+		With(t).Verify(errors.Is(err, actionErr)).Will(EqualTo(true)).OrFail()
+	})
+
+	t.Run("post-run hook error is joined with action error", func(t *testing.T) {
+		t.Parallel()
+		root := MustNew("cmd", "desc", "", &TrackingAction{errorToReturnOnCall: actionErr}, []any{&TrackingPostRunHook{errorToReturnOnCall: postRunErr}})
+		exitCode, err := ExecuteE(context.Background(), io.Discard, root, nil, nil)
+		With(t).Verify(exitCode).Will(EqualTo(ExitCodeError)).OrFail()
+		With(t).Verify(errors.Is(err, actionErr)).Will(EqualTo(true)).OrFail()
+		With(t).Verify(errors.Is(err, postRunErr)).Will(EqualTo(true)).OrFail()
+	})
+}
+
+func TestExecuteOptions(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		commandFactory func() *Command
+		args           []string
+		opts           func(stdout, stderr *bytes.Buffer) []ExecuteOption
+		expectedOutput string
+		expectedStdout string
+		expectedStderr string
+	}
+	testCases := map[string]testCase{
+		"default writer": {
+			args:           []string{"--bad"},
+			opts:           func(*bytes.Buffer, *bytes.Buffer) []ExecuteOption { return []ExecuteOption{WithWidth(30)} },
+			expectedOutput: "unknown flag: --bad\nUsage: cmd [-h, --help] \n    [--my-flag=VALUE]\n",
+		},
+		"errors written to stderr": {
+			args: []string{"--bad"},
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80)}
+			},
+			expectedStderr: "unknown flag: --bad\nUsage: cmd [-h, --help] [--my-flag=VALUE]\n",
+		},
+		"missing required flag written to stderr": {
+			commandFactory: func() *Command {
+				return MustNew("cmd", "desc", "", &struct {
+					Action
+					Name string `required:"true"`
+				}{Action: ActionFunc(func(context.Context) error { return nil })}, nil)
+			},
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80)}
+			},
+			expectedStderr: "required flag is missing: --name\nUsage: cmd [-h, --help] --name=VALUE\n",
+		},
+		"deprecation warning written to stderr": {
+			commandFactory: func() *Command {
+				cmd := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+				cmd.SetDeprecated("use 'other' instead")
+				return cmd
+			},
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80)}
+			},
+			expectedStderr: "command \"cmd\" is deprecated: use 'other' instead\n",
+		},
+		"version written to stdout": {
+			commandFactory: func() *Command {
+				cmd := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+				With(t).Verify(cmd.SetVersion("1.2.3")).Will(Succeed()).OrFail()
+				return cmd
+			},
+			args: []string{"--version"},
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80)}
+			},
+			expectedStdout: "cmd 1.2.3\n",
+		},
+		"help of non-runnable command written to stdout": {
+			commandFactory: func() *Command { return MustNew("cmd", "desc", "", nil, nil) },
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80)}
+			},
+			expectedStdout: "cmd: desc\n\nUsage:\n    cmd [-h, --help]\n\nFlags:\n" +
+				"    [-h, --help]  Show this help screen and exit. (default value: false, \n" +
+				"                  environment variable: HELP)\n\n",
+		},
+		"help written to stdout": {
+			args: []string{"--help"},
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80)}
+			},
+			expectedStdout: "cmd: desc\n\nUsage:\n    cmd [-h, --help] [--my-flag=VALUE]\n\nFlags:\n" +
+				"    [-h, --help]       Show this help screen and exit. (default value: false, \n" +
+				"                       environment variable: HELP)\n" +
+				"    [--my-flag=VALUE]  environment variable: MY_FLAG\n\n",
+		},
+		"colorized help": {
+			args: []string{"--help"},
+			opts: func(stdout, stderr *bytes.Buffer) []ExecuteOption {
+				return []ExecuteOption{WithStdout(stdout), WithStderr(stderr), WithWidth(80), WithColor(true)}
+			},
+			expectedStdout: "cmd: desc\n\n\x1b[1mUsage:\x1b[0m\n    cmd [-h, --help] [--my-flag=VALUE]\n\n\x1b[1mFlags:\x1b[0m\n" +
+				"    \x1b[1m[-h, --help]\x1b[0m       Show this help screen and exit. (default value: false, \n" +
+				"                       environment variable: HELP)\n" +
+				"    \x1b[1m[--my-flag=VALUE]\x1b[0m  environment variable: MY_FLAG\n\n",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			root := MustNew("cmd", "desc", "", &ActionWithConfig{}, nil)
+			if tc.commandFactory != nil {
+				root = tc.commandFactory()
+			}
+			output, stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+			ExecuteWithContext(context.Background(), output, root, tc.args, nil, tc.opts(stdout, stderr)...)
+			With(t).Verify(output.String()).Will(EqualTo(tc.expectedOutput)).OrFail()
+			With(t).Verify(stdout.String()).Will(EqualTo(tc.expectedStdout)).OrFail()
+			With(t).Verify(stderr.String()).Will(EqualTo(tc.expectedStderr)).OrFail()
+		})
+	}
+}
+
+func TestExecuteWithLogger(t *testing.T) {
+	t.Parallel()
+	sub := MustNew("sub", "desc", "", &ActionWithConfig{}, []any{&PreRunHookWithConfig{}, &PostRunHookWithConfig{}})
+	root := MustNew("cmd", "desc", "", nil, []any{&TrackingPostRunHook{}}, sub)
+
+	b := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(b, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey || a.Key == "duration" {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+	exitCode := ExecuteWithContext(context.Background(), io.Discard, root, []string{"sub", "a1"}, nil, WithLogger(logger))
+	With(t).Verify(exitCode).Will(EqualTo(ExitCodeSuccess)).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`level=DEBUG msg="Resolved command" command="cmd sub" positionals=[a1]
+level=DEBUG msg="Running pre-run hook" command="cmd sub" hook=*command.PreRunHookWithConfig
+level=DEBUG msg="Pre-run hook finished" command="cmd sub" hook=*command.PreRunHookWithConfig error=<nil>
+level=DEBUG msg="Running action" command="cmd sub" action=*command.ActionWithConfig
+level=DEBUG msg="Action finished" command="cmd sub" action=*command.ActionWithConfig error=<nil>
+level=DEBUG msg="Running post-run hook" command="cmd sub" hook=*command.PostRunHookWithConfig
+level=DEBUG msg="Post-run hook finished" command="cmd sub" hook=*command.PostRunHookWithConfig error=<nil>
+level=DEBUG msg="Running post-run hook" command=cmd hook=*command.TrackingPostRunHook
+level=DEBUG msg="Post-run hook finished" command=cmd hook=*command.TrackingPostRunHook error=<nil>
+`)).OrFail()
+}
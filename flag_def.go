@@ -2,14 +2,175 @@ package command
 
 import (
 	"cmp"
+	"encoding"
+	"encoding/base64"
 	"encoding/csv"
+	"encoding/hex"
 	"errors"
+	"flag"
 	"fmt"
+	"math"
+	"net"
+	"net/url"
 	"reflect"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	ipType       = reflect.TypeOf(net.IP{})
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	urlType      = reflect.TypeOf(url.URL{})
+
+	flagValueType       = reflect.TypeOf((*flag.Value)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isConfigStruct returns true if the given value is a struct that should be scanned for nested flags, rather than being
+// a flag value by itself (e.g. "net.IPNet", "url.URL" or a struct implementing "flag.Value").
+func isConfigStruct(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return false
+	}
+	t := v.Type()
+	return t != ipNetType && t != urlType && !isFlagValue(t) && !isTextUnmarshaler(t)
+}
+
+// isFlagValue returns true if pointers to the given type implement "flag.Value".
+func isFlagValue(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(flagValueType)
+}
+
+// isTextUnmarshaler returns true if pointers to the given type implement "encoding.TextUnmarshaler".
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// formatTargetValue formats the current value of the given flag target for display (e.g. in dry runs), preferring its
+// String method if it has one. Nil pointers are formatted as empty strings.
+func formatTargetValue(fv reflect.Value) string {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return ""
+		}
+		fv = fv.Elem()
+	}
+	if fv.CanAddr() {
+		if s, ok := fv.Addr().Interface().(fmt.Stringer); ok {
+			return s.String()
+		}
+	}
+	return fmt.Sprint(fv.Interface())
+}
+
+// isBytesType returns true if the given type is a byte slice (e.g. "[]byte") whose values are decoded as a whole using
+// the flag's encoding, rather than parsed by other means (e.g. "net.IP", "flag.Value" or "encoding.TextUnmarshaler").
+func isBytesType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 && t != ipType && !isFlagValue(t) && !isTextUnmarshaler(t)
+}
+
+// parseBool is similar to "strconv.ParseBool", except that it also accepts (case-insensitively) the literals commonly
+// given by scripts & configuration files: "yes", "y", "on" & "enabled" for true, and "no", "n", "off" & "disabled" for
+// false.
+func parseBool(sv string) (bool, error) {
+	switch strings.ToLower(sv) {
+	case "yes", "y", "on", "enabled":
+		return true, nil
+	case "no", "n", "off", "disabled":
+		return false, nil
+	default:
+		return strconv.ParseBool(sv)
+	}
+}
+
+// byteSizeUnits maps the (upper-cased) unit suffixes of byte sizes to their multipliers, ordered from largest to
+// smallest. Following the common (JEDEC) convention, "KB", "MB" & "GB" are binary multiples just like "KiB", "MiB" and
+// "GiB" (e.g. "10MB" is 10485760 bytes).
+var byteSizeUnits = []struct {
+	names      []string
+	multiplier uint64
+}{
+	{[]string{"TB", "TIB"}, 1 << 40},
+	{[]string{"GB", "GIB"}, 1 << 30},
+	{[]string{"MB", "MIB"}, 1 << 20},
+	{[]string{"KB", "KIB"}, 1 << 10},
+	{[]string{"B", ""}, 1},
+}
+
+// parseByteSize parses a human-readable byte size (e.g. "512", "64KiB" or "10MB") into a number of bytes.
+func parseByteSize(sv string) (uint64, error) {
+	s := strings.TrimSpace(sv)
+	i := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if i < 0 {
+		i = len(s)
+	}
+	if strings.HasPrefix(s, "-") {
+		return 0, fmt.Errorf("negative sizes are not supported")
+	} else if strings.HasPrefix(s[i:], ".") {
+		return 0, fmt.Errorf("fractional sizes are not supported")
+	}
+	number, unit := s[:i], strings.ToUpper(strings.TrimSpace(s[i:]))
+	for _, u := range byteSizeUnits {
+		if slices.Contains(u.names, unit) {
+			n, err := strconv.ParseUint(number, 10, 64)
+			if err != nil {
+				return 0, err
+			} else if n > math.MaxUint64/u.multiplier {
+				return 0, strconv.ErrRange
+			}
+			return n * u.multiplier, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown size unit '%s'", s[i:])
+}
+
+// formatByteSize formats the given number of bytes using the largest unit that represents it exactly (e.g. "10MB").
+func formatByteSize(n uint64) string {
+	for _, u := range byteSizeUnits {
+		if n != 0 && n%u.multiplier == 0 {
+			return strconv.FormatUint(n/u.multiplier, 10) + u.names[0]
+		}
+	}
+	return "0"
+}
+
+// Encodings of byte slice flag values, as given in the "encoding" tag.
+const (
+	bytesEncodingRaw    = "raw"
+	bytesEncodingBase64 = "base64"
+	bytesEncodingHex    = "hex"
+)
+
+// decodeBytes decodes the given value using the given encoding.
+func decodeBytes(encoding, sv string) ([]byte, error) {
+	switch encoding {
+	case bytesEncodingBase64:
+		return base64.StdEncoding.DecodeString(sv)
+	case bytesEncodingHex:
+		return hex.DecodeString(sv)
+	case bytesEncodingRaw:
+		return []byte(sv), nil
+	default:
+		return nil, fmt.Errorf("%w: encoding '%s'", errors.ErrUnsupported, encoding)
+	}
+}
+
+// encodeBytes encodes the given bytes using the given encoding (the reverse of decodeBytes).
+func encodeBytes(encoding string, b []byte) string {
+	switch encoding {
+	case bytesEncodingBase64:
+		return base64.StdEncoding.EncodeToString(b)
+	case bytesEncodingHex:
+		return hex.EncodeToString(b)
+	default:
+		return string(b)
+	}
+}
+
 type ErrInvalidValue struct {
 	Cause error
 	Value string
@@ -26,11 +187,27 @@ func (e *ErrInvalidValue) Unwrap() error {
 
 type flagInfo struct {
 	Name         string
+	Short        *string
 	EnvVarName   *string
+	EnvFallbacks []string
 	HasValue     bool
+	Count        bool
+	Stdin        bool
 	ValueName    *string
 	Description  *string
+	Choices      []string
 	Required     *bool
+	Hidden       *bool
+	Deprecated   *string
+	Group        *string
+	Pattern      *regexp.Regexp
+	Min          *string
+	Max          *string
+	EnvPrefix    *string
+	Encoding     *string
+	ByteSize     bool
+	Prompt       *string
+	Secret       bool
 	DefaultValue string
 }
 
@@ -39,6 +216,7 @@ type flagDef struct {
 	Inherited bool
 	Targets   []reflect.Value
 	applied   bool
+	index     int // declaration order of the flag in its flag set
 }
 
 func (fd *flagDef) isRequired() bool {
@@ -57,101 +235,529 @@ func (fd *flagDef) getValueName() string {
 	}
 }
 
-func (fd *flagDef) setValue(sv string) error {
-	for _, fv := range fd.Targets {
-		switch fv.Kind() {
-		case reflect.Bool:
-			if b, err := strconv.ParseBool(sv); err != nil {
-				var ne *strconv.NumError
-				if errors.As(err, &ne) {
-					return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
-				} else {
-					return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
-				}
+// inferFromValue configures whether this flag requires a value in the CLI, and its default value, according to the type
+// & value of the given (non-pointer) field value.
+func (fd *flagDef) inferFromValue(fieldValue reflect.Value) error {
+	switch t := fieldValue.Type(); {
+	case isFlagValue(t):
+		// Honor the "IsBoolFlag" convention of the "flag" package for flags that do not require a value
+		v := fieldValue.Addr().Interface().(flag.Value)
+		if bf, ok := v.(interface{ IsBoolFlag() bool }); ok && bf.IsBoolFlag() {
+			fd.HasValue = false
+		} else {
+			fd.HasValue = true
+		}
+		fd.DefaultValue = v.String()
+	case t == durationType:
+		fd.HasValue = true
+		fd.DefaultValue = time.Duration(fieldValue.Int()).String()
+	case isBytesType(t):
+		fd.HasValue = true
+		fd.DefaultValue = encodeBytes(defaultIfNil(fd.Encoding, bytesEncodingRaw), fieldValue.Bytes())
+	case t == ipType:
+		fd.HasValue = true
+		if ip := fieldValue.Interface().(net.IP); ip != nil {
+			fd.DefaultValue = ip.String()
+		}
+	case t == ipNetType:
+		fd.HasValue = true
+		if ipNet := fieldValue.Interface().(net.IPNet); ipNet.IP != nil {
+			fd.DefaultValue = ipNet.String()
+		}
+	case t == urlType:
+		fd.HasValue = true
+		u := fieldValue.Interface().(url.URL)
+		fd.DefaultValue = u.String()
+	case isTextUnmarshaler(t):
+		// Default value can only be inferred if the type can also marshal itself back to text
+		fd.HasValue = true
+		if m, ok := fieldValue.Addr().Interface().(encoding.TextMarshaler); ok {
+			if text, err := m.MarshalText(); err != nil {
+				return fmt.Errorf("failed marshaling default value: %w", err)
 			} else {
-				fv.SetBool(b)
+				fd.DefaultValue = string(text)
 			}
+		}
+	default:
+		switch fieldValue.Kind() {
+		case reflect.Bool:
+			fd.HasValue = false
+			fd.DefaultValue = strconv.FormatBool(fieldValue.Bool())
 		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-			if i, err := strconv.ParseInt(sv, 10, 64); err != nil {
-				var ne *strconv.NumError
-				if errors.As(err, &ne) {
-					return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
-				} else {
-					return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
-				}
+			fd.HasValue = !fd.Count
+			if fd.ByteSize && fieldValue.Int() > 0 {
+				fd.DefaultValue = formatByteSize(uint64(fieldValue.Int()))
 			} else {
-				fv.SetInt(i)
+				fd.DefaultValue = strconv.FormatInt(fieldValue.Int(), 10)
 			}
 		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			if ui, err := strconv.ParseUint(sv, 10, 64); err != nil {
-				var ne *strconv.NumError
-				if errors.As(err, &ne) {
-					return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
-				} else {
-					return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
-				}
+			fd.HasValue = !fd.Count
+			if fd.ByteSize {
+				fd.DefaultValue = formatByteSize(fieldValue.Uint())
 			} else {
-				fv.SetUint(ui)
+				fd.DefaultValue = strconv.FormatUint(fieldValue.Uint(), 10)
 			}
 		case reflect.Float32, reflect.Float64:
-			if f, err := strconv.ParseFloat(sv, 64); err != nil {
-				var ne *strconv.NumError
-				if errors.As(err, &ne) {
-					return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
-				} else {
-					return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
-				}
-			} else {
-				fv.SetFloat(f)
-			}
+			fd.HasValue = true
+			fd.DefaultValue = strconv.FormatFloat(fieldValue.Float(), 'g', -1, 64)
 		case reflect.String:
-			fv.SetString(sv)
+			fd.HasValue = true
+			fd.DefaultValue = fieldValue.String()
 		case reflect.Slice:
-			r := csv.NewReader(strings.NewReader(sv))
-			r.LazyQuotes = true
-			r.TrimLeadingSpace = true
-			rec, err := r.Read()
-			if err != nil {
+			fd.HasValue = true
+			var defaultValues []string
+			for i := 0; i < fieldValue.Len(); i++ {
+				defaultValues = append(defaultValues, fmt.Sprint(fieldValue.Index(i).Interface()))
+			}
+			if defaultValues != nil {
+				fd.DefaultValue = strings.Join(defaultValues, ",")
+			} else {
+				fd.DefaultValue = ""
+			}
+		case reflect.Map:
+			if t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+				return fmt.Errorf("unsupported field type: %s", t)
+			}
+			fd.HasValue = true
+			var defaultValues []string
+			for _, k := range fieldValue.MapKeys() {
+				defaultValues = append(defaultValues, k.String()+"="+fieldValue.MapIndex(k).String())
+			}
+			slices.Sort(defaultValues)
+			fd.DefaultValue = strings.Join(defaultValues, ",")
+		default:
+			// Unsupported flag field type
+			return fmt.Errorf("unsupported field type: %s", fieldValue.Kind())
+		}
+	}
+	return nil
+}
+
+func (fd *flagDef) setValue(sv string) error {
+	return fd.assignValue(sv, false, true)
+}
+
+// appendValue is similar to setValue, except that slice targets are appended to (and map targets are added to), rather
+// than replaced.
+func (fd *flagDef) appendValue(sv string) error {
+	return fd.assignValue(sv, true, true)
+}
+
+// setDefaultValue is similar to setValue, except that the value is not checked against the flag's minimum & maximum
+// bounds, since zero values are common defaults for optional flags.
+func (fd *flagDef) setDefaultValue(sv string) error {
+	return fd.assignValue(sv, false, false)
+}
+
+func (fd *flagDef) assignValue(sv string, accumulate, checkRange bool) error {
+	for _, fv := range fd.Targets {
+		if err := fd.setTargetValue(fv, sv, accumulate); err != nil {
+			return err
+		} else if checkRange {
+			if err := fd.checkRange(fv); err != nil {
+				return err
+			}
+		}
+	}
+	fd.applied = true
+	return nil
+}
+
+// increment increments all targets by one (only applicable for count flags).
+func (fd *flagDef) increment() error {
+	for _, fv := range fd.Targets {
+		switch fv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			fv.SetInt(fv.Int() + 1)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fv.SetUint(fv.Uint() + 1)
+		default:
+			return fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, fv.Kind())
+		}
+	}
+	fd.applied = true
+	return nil
+}
+
+// setMapEntry sets the given key to the given value in all map targets (only applicable for map flags, e.g. ones
+// populated from environment variables by their prefix).
+func (fd *flagDef) setMapEntry(key, value string) error {
+	for _, fv := range fd.Targets {
+		if fv.Kind() != reflect.Map {
+			return fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, fv.Kind())
+		} else if fv.IsNil() {
+			fv.Set(reflect.MakeMap(fv.Type()))
+		}
+		fv.SetMapIndex(reflect.ValueOf(key).Convert(fv.Type().Key()), reflect.ValueOf(value).Convert(fv.Type().Elem()))
+	}
+	fd.applied = true
+	return nil
+}
+
+// checkChoice verifies that the given value is one of the flag's allowed choices, if it has any.
+func (fd *flagDef) checkChoice(sv string) error {
+	if fd.Choices != nil && !slices.Contains(fd.Choices, sv) {
+		return &ErrInvalidValue{Cause: fmt.Errorf("must be one of: %s", strings.Join(fd.Choices, ", ")), Value: sv, Flag: fd.Name}
+	}
+	return nil
+}
+
+// checkPattern verifies that the given value matches the flag's pattern, if it has one.
+func (fd *flagDef) checkPattern(sv string) error {
+	if fd.Pattern != nil && !fd.Pattern.MatchString(sv) {
+		return &ErrInvalidValue{Cause: fmt.Errorf("must match pattern: %s", fd.Pattern), Value: sv, Flag: fd.Name}
+	}
+	return nil
+}
+
+// patternString returns the source text of the given pattern, or an empty string if it's nil.
+func patternString(pattern *regexp.Regexp) string {
+	if pattern == nil {
+		return ""
+	}
+	return pattern.String()
+}
+
+// checkRange verifies that the given target's value (or each of its elements, for slices) is within the flag's minimum
+// & maximum bounds, if it has any.
+func (fd *flagDef) checkRange(fv reflect.Value) error {
+	if fd.Min == nil && fd.Max == nil {
+		return nil
+	}
+
+	values := []reflect.Value{fv}
+	if fv.Kind() == reflect.Slice {
+		values = values[:0]
+		for i := 0; i < fv.Len(); i++ {
+			values = append(values, fv.Index(i))
+		}
+	}
+	for _, v := range values {
+		if (fd.Min != nil && compareToBound(v, *fd.Min) < 0) || (fd.Max != nil && compareToBound(v, *fd.Max) > 0) {
+			var cause error
+			switch {
+			case fd.Max == nil:
+				cause = fmt.Errorf("must be at least %s", *fd.Min)
+			case fd.Min == nil:
+				cause = fmt.Errorf("must be at most %s", *fd.Max)
+			default:
+				cause = fmt.Errorf("out of range [%s, %s]", *fd.Min, *fd.Max)
+			}
+			return &ErrInvalidValue{Cause: cause, Value: fmt.Sprint(v.Interface()), Flag: fd.Name}
+		}
+	}
+	return nil
+}
+
+// isNumericType returns true if the given type is an integer or floating-point number (durations excluded), or a slice
+// of such numbers (byte slices excluded), and can thus be bounded by minimum & maximum values.
+func isNumericType(t reflect.Type) bool {
+	if isBytesType(t) {
+		return false
+	} else if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t != durationType && (isIntegerKind(t.Kind()) || t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64)
+}
+
+// parseBound parses the given minimum or maximum bound into a value of the given numeric type (or the element type, for
+// slices of numbers).
+func parseBound(t reflect.Type, bound string) (reflect.Value, error) {
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(bound, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(bound, 10, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(bound, t.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		v.SetFloat(n)
+	default:
+		return reflect.Value{}, fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, t.Kind())
+	}
+	return v, nil
+}
+
+// compareToBound compares the given numeric value to the given bound (which must have been validated by parseBound),
+// returning -1 if the value is lower than the bound, 1 if it is higher, and 0 if they're equal.
+func compareToBound(v reflect.Value, bound string) int {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, _ := strconv.ParseInt(bound, 10, 64)
+		return cmp.Compare(v.Int(), n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, _ := strconv.ParseUint(bound, 10, 64)
+		return cmp.Compare(v.Uint(), n)
+	case reflect.Float32, reflect.Float64:
+		n, _ := strconv.ParseFloat(bound, 64)
+		return cmp.Compare(v.Float(), n)
+	default:
+		return 0
+	}
+}
+
+func (fd *flagDef) setTargetValue(fv reflect.Value, sv string, accumulate bool) error {
+
+	// Pointer targets are allocated when first set, and their pointees are set instead
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+
+	// Slices are checked per-element (below), whereas all other values (including byte slices) are checked as a whole
+	if fv.Kind() != reflect.Slice || fv.Type() == ipType || isBytesType(fv.Type()) {
+		if err := fd.checkChoice(sv); err != nil {
+			return err
+		} else if err := fd.checkPattern(sv); err != nil {
+			return err
+		}
+	}
+
+	// Types implementing "flag.Value" take precedence over any other parsing
+	if v, ok := fv.Addr().Interface().(flag.Value); ok {
+		if err := v.Set(sv); err != nil {
+			return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+		}
+		return nil
+	}
+
+	// Types that require special parsing, regardless of their underlying kind
+	switch fv.Type() {
+	case durationType:
+		if d, err := time.ParseDuration(sv); err != nil {
+			return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+		} else {
+			fv.SetInt(int64(d))
+		}
+		return nil
+	case ipType:
+		if ip := net.ParseIP(sv); ip == nil {
+			return &ErrInvalidValue{Cause: fmt.Errorf("invalid IP address"), Value: sv, Flag: fd.Name}
+		} else {
+			fv.Set(reflect.ValueOf(ip))
+		}
+		return nil
+	case ipNetType:
+		if _, ipNet, err := net.ParseCIDR(sv); err != nil {
+			return &ErrInvalidValue{Cause: fmt.Errorf("invalid CIDR address"), Value: sv, Flag: fd.Name}
+		} else {
+			fv.Set(reflect.ValueOf(*ipNet))
+		}
+		return nil
+	case urlType:
+		if u, err := url.Parse(sv); err != nil {
+			var ue *url.Error
+			if errors.As(err, &ue) {
+				return &ErrInvalidValue{Cause: ue.Err, Value: sv, Flag: fd.Name}
+			} else {
 				return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
 			}
+		} else if u.Scheme == "" {
+			return &ErrInvalidValue{Cause: fmt.Errorf("missing URL scheme"), Value: sv, Flag: fd.Name}
+		} else {
+			fv.Set(reflect.ValueOf(*u))
+		}
+		return nil
+	}
 
-			inValue := reflect.ValueOf(rec)
-
-			targetType := fv.Type().Elem()
-			outSlice := reflect.MakeSlice(reflect.SliceOf(targetType), inValue.Len(), inValue.Len())
-			for i, inElem := range rec {
-				var outElem interface{}
-				var err error
-				switch targetType.Kind() {
-				case reflect.String:
-					outElem = inElem
-				case reflect.Int:
-					outElem, err = strconv.Atoi(inElem)
-				case reflect.Float32:
-					if f64, parseErr := strconv.ParseFloat(inElem, 32); parseErr == nil {
-						outElem = float32(f64)
-					} else {
-						outElem = nil
-						err = parseErr
+	// Byte size flags accept human-readable sizes (e.g. "10MB") for integer fields
+	if fd.ByteSize {
+		if n, err := parseByteSize(sv); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+		} else if fv.CanInt() && (n > math.MaxInt64 || fv.OverflowInt(int64(n))) || fv.CanUint() && fv.OverflowUint(n) {
+			return &ErrInvalidValue{Cause: strconv.ErrRange, Value: sv, Flag: fd.Name}
+		} else if fv.CanInt() {
+			fv.SetInt(int64(n))
+		} else {
+			fv.SetUint(n)
+		}
+		return nil
+	}
+
+	// Types implementing "encoding.TextUnmarshaler" know how to parse themselves
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(sv)); err != nil {
+			return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+		}
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.Bool:
+		if b, err := parseBool(sv); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
+			} else {
+				return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+			}
+		} else {
+			fv.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, err := strconv.ParseInt(sv, 0, fv.Type().Bits()); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
+			} else {
+				return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+			}
+		} else {
+			fv.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if ui, err := strconv.ParseUint(sv, 0, fv.Type().Bits()); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
+			} else {
+				return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+			}
+		} else {
+			fv.SetUint(ui)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(sv, 64); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				return &ErrInvalidValue{Cause: ne.Err, Value: ne.Num, Flag: fd.Name}
+			} else {
+				return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+			}
+		} else {
+			fv.SetFloat(f)
+		}
+	case reflect.String:
+		fv.SetString(sv)
+	case reflect.Slice:
+		// Byte slices are decoded as a whole using the flag's encoding, rather than parsed as a list of numbers; each
+		// value replaces the previous one, since appending decoded bytes is rarely what's intended
+		if isBytesType(fv.Type()) {
+			if b, err := decodeBytes(defaultIfNil(fd.Encoding, bytesEncodingRaw), sv); err != nil {
+				return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+			} else {
+				fv.SetBytes(b)
+			}
+			return nil
+		}
+
+		r := csv.NewReader(strings.NewReader(sv))
+		r.LazyQuotes = true
+		r.TrimLeadingSpace = true
+		rec, err := r.Read()
+		if err != nil {
+			return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+		}
+
+		inValue := reflect.ValueOf(rec)
+
+		targetType := fv.Type().Elem()
+		outSlice := reflect.MakeSlice(reflect.SliceOf(targetType), inValue.Len(), inValue.Len())
+		for i, inElem := range rec {
+			if err := fd.checkChoice(inElem); err != nil {
+				return err
+			} else if err := fd.checkPattern(inElem); err != nil {
+				return err
+			}
+			outElem := outSlice.Index(i)
+			var err error
+			switch targetType.Kind() {
+			case reflect.String:
+				outElem.SetString(inElem)
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				if targetType == durationType {
+					var d time.Duration
+					if d, err = time.ParseDuration(inElem); err == nil {
+						outElem.SetInt(int64(d))
 					}
-				case reflect.Float64:
-					outElem, err = strconv.ParseFloat(inElem, 64)
-				case reflect.Bool:
-					outElem, err = strconv.ParseBool(inElem)
-				default:
-					return fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, fv.Kind())
+				} else {
+					var n int64
+					if n, err = strconv.ParseInt(inElem, 0, targetType.Bits()); err == nil {
+						outElem.SetInt(n)
+					}
+				}
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				var n uint64
+				if n, err = strconv.ParseUint(inElem, 0, targetType.Bits()); err == nil {
+					outElem.SetUint(n)
+				}
+			case reflect.Float32, reflect.Float64:
+				var f float64
+				if f, err = strconv.ParseFloat(inElem, targetType.Bits()); err == nil {
+					outElem.SetFloat(f)
 				}
-				if err != nil {
+			case reflect.Bool:
+				var b bool
+				if b, err = parseBool(inElem); err == nil {
+					outElem.SetBool(b)
+				}
+			default:
+				return fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, fv.Kind())
+			}
+			if err != nil {
+				var ne *strconv.NumError
+				if errors.As(err, &ne) {
+					return &ErrInvalidValue{Cause: ne.Err, Value: inElem, Flag: fd.Name}
+				} else {
 					return &ErrInvalidValue{Cause: err, Value: inElem, Flag: fd.Name}
 				}
-				outSlice.Index(i).Set(reflect.ValueOf(outElem).Convert(outSlice.Type().Elem()))
 			}
+		}
+		if accumulate {
+			fv.Set(reflect.AppendSlice(fv, outSlice))
+		} else {
 			fv.Set(outSlice)
-		default:
-			return fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, fv.Kind())
 		}
+	case reflect.Map:
+		if fv.Type().Key().Kind() != reflect.String || fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("%w: field type is '%s'", errors.ErrUnsupported, fv.Type())
+		}
+
+		r := csv.NewReader(strings.NewReader(sv))
+		r.LazyQuotes = true
+		r.TrimLeadingSpace = true
+		rec, err := r.Read()
+		if err != nil {
+			return &ErrInvalidValue{Cause: err, Value: sv, Flag: fd.Name}
+		}
+
+		// Like slices, the first value replaces the whole map, and later values add (or overwrite) keys in it; maps
+		// populated from environment variables by their prefix are always added to, rather than replaced
+		if fv.IsNil() || !accumulate && fd.EnvPrefix == nil {
+			fv.Set(reflect.MakeMap(fv.Type()))
+		}
+		for _, entry := range rec {
+			if k, v, found := strings.Cut(entry, "="); !found {
+				return &ErrInvalidValue{Cause: fmt.Errorf("expected KEY=VALUE"), Value: entry, Flag: fd.Name}
+			} else {
+				kv := reflect.ValueOf(k).Convert(fv.Type().Key())
+				vv := reflect.ValueOf(v).Convert(fv.Type().Elem())
+				fv.SetMapIndex(kv, vv)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: field kind is '%s'", errors.ErrUnsupported, fv.Kind())
 	}
-	fd.applied = true
 	return nil
 }
 
@@ -163,18 +769,54 @@ func (fd *flagDef) isLessThan(b *flagDef) bool {
 	} else if name > 0 {
 		return false
 	}
+	short := cmp.Compare(defaultIfNil(a.Short, ""), defaultIfNil(b.Short, ""))
+	if short < 0 {
+		return true
+	} else if short > 0 {
+		return false
+	}
 	envVarName := cmp.Compare(defaultIfNil(a.EnvVarName, ""), defaultIfNil(b.EnvVarName, ""))
 	if envVarName < 0 {
 		return true
 	} else if envVarName > 0 {
 		return false
 	}
+	envFallbacks := slices.Compare(a.EnvFallbacks, b.EnvFallbacks)
+	if envFallbacks < 0 {
+		return true
+	} else if envFallbacks > 0 {
+		return false
+	}
 	hasValue := cmp.Compare(intForBool(a.HasValue), intForBool(b.HasValue))
 	if hasValue < 0 {
 		return true
 	} else if hasValue > 0 {
 		return false
 	}
+	count := cmp.Compare(intForBool(a.Count), intForBool(b.Count))
+	if count < 0 {
+		return true
+	} else if count > 0 {
+		return false
+	}
+	byteSize := cmp.Compare(intForBool(a.ByteSize), intForBool(b.ByteSize))
+	if byteSize < 0 {
+		return true
+	} else if byteSize > 0 {
+		return false
+	}
+	secret := cmp.Compare(intForBool(a.Secret), intForBool(b.Secret))
+	if secret < 0 {
+		return true
+	} else if secret > 0 {
+		return false
+	}
+	stdin := cmp.Compare(intForBool(a.Stdin), intForBool(b.Stdin))
+	if stdin < 0 {
+		return true
+	} else if stdin > 0 {
+		return false
+	}
 	valueName := cmp.Compare(defaultIfNil(a.ValueName, ""), defaultIfNil(b.ValueName, ""))
 	if valueName < 0 {
 		return true
@@ -187,12 +829,72 @@ func (fd *flagDef) isLessThan(b *flagDef) bool {
 	} else if description > 0 {
 		return false
 	}
+	choices := slices.Compare(a.Choices, b.Choices)
+	if choices < 0 {
+		return true
+	} else if choices > 0 {
+		return false
+	}
 	required := cmp.Compare(intForBool(defaultIfNil(a.Required, false)), intForBool(defaultIfNil(b.Required, false)))
 	if required < 0 {
 		return true
 	} else if required > 0 {
 		return false
 	}
+	hidden := cmp.Compare(intForBool(defaultIfNil(a.Hidden, false)), intForBool(defaultIfNil(b.Hidden, false)))
+	if hidden < 0 {
+		return true
+	} else if hidden > 0 {
+		return false
+	}
+	deprecated := cmp.Compare(defaultIfNil(a.Deprecated, ""), defaultIfNil(b.Deprecated, ""))
+	if deprecated < 0 {
+		return true
+	} else if deprecated > 0 {
+		return false
+	}
+	pattern := cmp.Compare(patternString(a.Pattern), patternString(b.Pattern))
+	if pattern < 0 {
+		return true
+	} else if pattern > 0 {
+		return false
+	}
+	minimum := cmp.Compare(defaultIfNil(a.Min, ""), defaultIfNil(b.Min, ""))
+	if minimum < 0 {
+		return true
+	} else if minimum > 0 {
+		return false
+	}
+	maximum := cmp.Compare(defaultIfNil(a.Max, ""), defaultIfNil(b.Max, ""))
+	if maximum < 0 {
+		return true
+	} else if maximum > 0 {
+		return false
+	}
+	envPrefix := cmp.Compare(defaultIfNil(a.EnvPrefix, ""), defaultIfNil(b.EnvPrefix, ""))
+	if envPrefix < 0 {
+		return true
+	} else if envPrefix > 0 {
+		return false
+	}
+	encoding := cmp.Compare(defaultIfNil(a.Encoding, ""), defaultIfNil(b.Encoding, ""))
+	if encoding < 0 {
+		return true
+	} else if encoding > 0 {
+		return false
+	}
+	prompt := cmp.Compare(defaultIfNil(a.Prompt, ""), defaultIfNil(b.Prompt, ""))
+	if prompt < 0 {
+		return true
+	} else if prompt > 0 {
+		return false
+	}
+	group := cmp.Compare(defaultIfNil(a.Group, ""), defaultIfNil(b.Group, ""))
+	if group < 0 {
+		return true
+	} else if group > 0 {
+		return false
+	}
 	defaultValue := cmp.Compare(a.DefaultValue, b.DefaultValue)
 	if defaultValue < 0 {
 		return true
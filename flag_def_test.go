@@ -1,10 +1,15 @@
 package command
 
 import (
+	"fmt"
 	"math"
+	"net"
+	"net/url"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/arikkfir/justest"
 )
@@ -51,6 +56,49 @@ func TestFlagDefGetValueName(t *testing.T) {
 	}
 }
 
+type logLevel int
+
+func (l *logLevel) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "info":
+		*l = 0
+	case "debug":
+		*l = 1
+	default:
+		return fmt.Errorf("unknown log level")
+	}
+	return nil
+}
+
+func (l *logLevel) MarshalText() ([]byte, error) {
+	switch *l {
+	case 0:
+		return []byte("info"), nil
+	case 1:
+		return []byte("debug"), nil
+	default:
+		return nil, fmt.Errorf("unknown log level")
+	}
+}
+
+type upperCaseValue string
+
+func (v *upperCaseValue) Set(s string) error {
+	if s == "" {
+		return fmt.Errorf("must not be empty")
+	}
+	*v = upperCaseValue(strings.ToUpper(s))
+	return nil
+}
+
+func (v *upperCaseValue) String() string {
+	return string(*v)
+}
+
+func (v *upperCaseValue) UnmarshalText([]byte) error {
+	return fmt.Errorf("flag.Value should take precedence")
+}
+
 func TestFlagDefSetValue(t *testing.T) {
 	t.Parallel()
 	type Target struct {
@@ -68,15 +116,66 @@ func TestFlagDefSetValue(t *testing.T) {
 		F32  float32
 		F64  float64
 		S    string
+		D    time.Duration
+		IP   net.IP
+		Net  net.IPNet
+		URL  url.URL
+		LL   logLevel
+		UC   upperCaseValue
+		M    map[string]string
+		SI8  []int8
+		SI64 []int64
+		SUI  []uint
+		SU16 []uint16
+		SF32 []float32
+		SD   []time.Duration
+		Raw  []byte
 	}
 	type testCase struct {
 		target         *Target
 		targetsFactory func(tc *testCase) []reflect.Value
+		byteSize       bool
 		value          string
 		expectedTarget Target
 		expectedError  string
 	}
 	testCases := map[string]testCase{
+		"valid byte size": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I64")}
+			},
+			byteSize:       true,
+			value:          "10MB",
+			expectedTarget: Target{I64: 10485760},
+		},
+		"valid unsigned byte size": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UI32")}
+			},
+			byteSize:       true,
+			value:          "64kib",
+			expectedTarget: Target{UI32: 65536},
+		},
+		"byte size with invalid unit": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I64")}
+			},
+			byteSize:      true,
+			value:         "10XB",
+			expectedError: `^invalid value '10XB' for flag 'my-flag': unknown size unit 'XB'$`,
+		},
+		"overflowing byte size": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I16")}
+			},
+			byteSize:      true,
+			value:         "1MB",
+			expectedError: `^invalid value '1MB' for flag 'my-flag': value out of range$`,
+		},
 		"valid bool": {
 			target: &Target{},
 			targetsFactory: func(tc *testCase) []reflect.Value {
@@ -85,6 +184,14 @@ func TestFlagDefSetValue(t *testing.T) {
 			value:          "true",
 			expectedTarget: Target{B: true},
 		},
+		"valid bool literal": {
+			target: &Target{B: true},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("B")}
+			},
+			value:          "Off",
+			expectedTarget: Target{B: false},
+		},
 		"invalid bool": {
 			target: &Target{},
 			targetsFactory: func(tc *testCase) []reflect.Value {
@@ -253,6 +360,126 @@ func TestFlagDefSetValue(t *testing.T) {
 			value:         "abc",
 			expectedError: `^invalid value 'abc' for flag 'my-flag': invalid syntax$`,
 		},
+		"hexadecimal int": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I")}
+			},
+			value:          "0xFF_00",
+			expectedTarget: Target{I: 0xFF00},
+		},
+		"octal int": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I32")}
+			},
+			value:          "-0o755",
+			expectedTarget: Target{I32: -0o755},
+		},
+		"binary int": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I8")}
+			},
+			value:          "0b0111_1111",
+			expectedTarget: Target{I8: 0b01111111},
+		},
+		"decimal int with underscores": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I64")}
+			},
+			value:          "1_000_000",
+			expectedTarget: Target{I64: 1000000},
+		},
+		"leading zero int is octal": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I16")}
+			},
+			value:          "0755",
+			expectedTarget: Target{I16: 0755},
+		},
+		"overflowing int8": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I8")}
+			},
+			value:         "128",
+			expectedError: `^invalid value '128' for flag 'my-flag': value out of range$`,
+		},
+		"overflowing hexadecimal int16": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I16")}
+			},
+			value:         "0x8000",
+			expectedError: `^invalid value '0x8000' for flag 'my-flag': value out of range$`,
+		},
+		"invalid binary int": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I")}
+			},
+			value:         "0b102",
+			expectedError: `^invalid value '0b102' for flag 'my-flag': invalid syntax$`,
+		},
+		"misplaced underscore in int": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("I")}
+			},
+			value:         "1__000",
+			expectedError: `^invalid value '1__000' for flag 'my-flag': invalid syntax$`,
+		},
+		"hexadecimal uint": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UI16")}
+			},
+			value:          "0xFFFF",
+			expectedTarget: Target{UI16: 0xFFFF},
+		},
+		"binary uint": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UI8")}
+			},
+			value:          "0b1010_1010",
+			expectedTarget: Target{UI8: 0b10101010},
+		},
+		"overflowing uint8": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UI8")}
+			},
+			value:         "0x100",
+			expectedError: `^invalid value '0x100' for flag 'my-flag': value out of range$`,
+		},
+		"overflowing uint32": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UI32")}
+			},
+			value:         "4294967296",
+			expectedError: `^invalid value '4294967296' for flag 'my-flag': value out of range$`,
+		},
+		"hexadecimal int slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SI64")}
+			},
+			value:          "0x10,0o10,0b10,10",
+			expectedTarget: Target{SI64: []int64{16, 8, 2, 10}},
+		},
+		"hexadecimal uint slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SU16")}
+			},
+			value:          "0xFF_FF",
+			expectedTarget: Target{SU16: []uint16{0xFFFF}},
+		},
 		"valid float32": {
 			target: &Target{},
 			targetsFactory: func(tc *testCase) []reflect.Value {
@@ -285,6 +512,246 @@ func TestFlagDefSetValue(t *testing.T) {
 			value:         "abc",
 			expectedError: `^invalid value 'abc' for flag 'my-flag': invalid syntax$`,
 		},
+		"valid duration": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("D")}
+			},
+			value:          "2h30m",
+			expectedTarget: Target{D: 2*time.Hour + 30*time.Minute},
+		},
+		"invalid duration": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("D")}
+			},
+			value:         "abc",
+			expectedError: `^invalid value 'abc' for flag 'my-flag': time: invalid duration "abc"$`,
+		},
+		"valid IP": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("IP")}
+			},
+			value:          "10.0.0.1",
+			expectedTarget: Target{IP: net.ParseIP("10.0.0.1")},
+		},
+		"invalid IP": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("IP")}
+			},
+			value:         "10.0.0",
+			expectedError: `^invalid value '10.0.0' for flag 'my-flag': invalid IP address$`,
+		},
+		"valid IP network": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("Net")}
+			},
+			value:          "10.0.0.0/8",
+			expectedTarget: Target{Net: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)}},
+		},
+		"invalid IP network": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("Net")}
+			},
+			value:         "10.0.0.0",
+			expectedError: `^invalid value '10.0.0.0' for flag 'my-flag': invalid CIDR address$`,
+		},
+		"valid URL": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("URL")}
+			},
+			value:          "https://example.com/path",
+			expectedTarget: Target{URL: url.URL{Scheme: "https", Host: "example.com", Path: "/path"}},
+		},
+		"invalid URL": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("URL")}
+			},
+			value:         "https://example.com/%zz",
+			expectedError: `^invalid value 'https://example.com/%zz' for flag 'my-flag': invalid URL escape "%zz"$`,
+		},
+		"URL without scheme": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("URL")}
+			},
+			value:         "example.com/path",
+			expectedError: `^invalid value 'example.com/path' for flag 'my-flag': missing URL scheme$`,
+		},
+		"valid text unmarshaler": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("LL")}
+			},
+			value:          "debug",
+			expectedTarget: Target{LL: 1},
+		},
+		"invalid text unmarshaler": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("LL")}
+			},
+			value:         "trace",
+			expectedError: `^invalid value 'trace' for flag 'my-flag': unknown log level$`,
+		},
+		"valid flag value": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UC")}
+			},
+			value:          "abc",
+			expectedTarget: Target{UC: "ABC"},
+		},
+		"invalid flag value": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("UC")}
+			},
+			value:         "",
+			expectedError: `^invalid value '' for flag 'my-flag': must not be empty$`,
+		},
+		"valid map": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("M")}
+			},
+			value:          "env=prod,team=infra=core",
+			expectedTarget: Target{M: map[string]string{"env": "prod", "team": "infra=core"}},
+		},
+		"valid map replaces existing keys": {
+			target: &Target{M: map[string]string{"env": "dev", "region": "eu"}},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("M")}
+			},
+			value:          "env=prod",
+			expectedTarget: Target{M: map[string]string{"env": "prod"}},
+		},
+		"invalid map": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("M")}
+			},
+			value:         "env",
+			expectedError: `^invalid value 'env' for flag 'my-flag': expected KEY=VALUE$`,
+		},
+		"valid int8 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SI8")}
+			},
+			value:          "-128,0,127",
+			expectedTarget: Target{SI8: []int8{math.MinInt8, 0, math.MaxInt8}},
+		},
+		"overflowing int8 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SI8")}
+			},
+			value:         "1,128",
+			expectedError: `^invalid value '128' for flag 'my-flag': value out of range$`,
+		},
+		"valid int64 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SI64")}
+			},
+			value:          "1, " + strconv.FormatInt(math.MaxInt64, 10),
+			expectedTarget: Target{SI64: []int64{1, math.MaxInt64}},
+		},
+		"invalid int64 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SI64")}
+			},
+			value:         "1,abc",
+			expectedError: `^invalid value 'abc' for flag 'my-flag': invalid syntax$`,
+		},
+		"overflowing int64 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SI64")}
+			},
+			value:         "9223372036854775808",
+			expectedError: `^invalid value '9223372036854775808' for flag 'my-flag': value out of range$`,
+		},
+		"valid uint slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SUI")}
+			},
+			value:          "0,42," + strconv.FormatUint(math.MaxUint, 10),
+			expectedTarget: Target{SUI: []uint{0, 42, math.MaxUint}},
+		},
+		"negative uint slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SUI")}
+			},
+			value:         "1,-1",
+			expectedError: `^invalid value '-1' for flag 'my-flag': invalid syntax$`,
+		},
+		"valid uint16 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SU16")}
+			},
+			value:          "80,443,65535",
+			expectedTarget: Target{SU16: []uint16{80, 443, math.MaxUint16}},
+		},
+		"overflowing uint16 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SU16")}
+			},
+			value:         "80,65536",
+			expectedError: `^invalid value '65536' for flag 'my-flag': value out of range$`,
+		},
+		"valid float32 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SF32")}
+			},
+			value:          "1.5,-2.25",
+			expectedTarget: Target{SF32: []float32{1.5, -2.25}},
+		},
+		"overflowing float32 slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SF32")}
+			},
+			value:         "1e39",
+			expectedError: `^invalid value '1e39' for flag 'my-flag': value out of range$`,
+		},
+		"valid duration slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SD")}
+			},
+			value:          "1s,2m30s",
+			expectedTarget: Target{SD: []time.Duration{time.Second, 2*time.Minute + 30*time.Second}},
+		},
+		"invalid duration slice": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("SD")}
+			},
+			value:         "1s,abc",
+			expectedError: `^invalid value 'abc' for flag 'my-flag': time: invalid duration "abc"$`,
+		},
+		"raw bytes": {
+			target: &Target{},
+			targetsFactory: func(tc *testCase) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(tc.target).Elem().FieldByName("Raw")}
+			},
+			value:          "hello,world",
+			expectedTarget: Target{Raw: []byte("hello,world")},
+		},
 		"string": {
 			target: &Target{},
 			targetsFactory: func(tc *testCase) []reflect.Value {
@@ -298,7 +765,7 @@ func TestFlagDefSetValue(t *testing.T) {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			t.Parallel()
-			fd := &flagDef{flagInfo: flagInfo{Name: "my-flag"}, Targets: tc.targetsFactory(&tc)}
+			fd := &flagDef{flagInfo: flagInfo{Name: "my-flag", ByteSize: tc.byteSize}, Targets: tc.targetsFactory(&tc)}
 			err := fd.setValue(tc.value)
 			if tc.expectedError != "" {
 				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
@@ -309,3 +776,142 @@ func TestFlagDefSetValue(t *testing.T) {
 		})
 	}
 }
+
+func TestFlagDefSetValueEncodedBytes(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		encoding      string
+		value         string
+		expectedBytes []byte
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"valid base64": {
+			encoding:      "base64",
+			value:         "aGVsbG8=",
+			expectedBytes: []byte("hello"),
+		},
+		"invalid base64": {
+			encoding:      "base64",
+			value:         "aGVsbG8",
+			expectedError: `^invalid value 'aGVsbG8' for flag 'my-flag': illegal base64 data at input byte 4$`,
+		},
+		"valid hex": {
+			encoding:      "hex",
+			value:         "68656c6c6f",
+			expectedBytes: []byte("hello"),
+		},
+		"invalid hex": {
+			encoding:      "hex",
+			value:         "6865zz",
+			expectedError: `^invalid value '6865zz' for flag 'my-flag': encoding/hex: invalid byte: U\+007A 'z'$`,
+		},
+		"raw": {
+			encoding:      "raw",
+			value:         "aGVsbG8=",
+			expectedBytes: []byte("aGVsbG8="),
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var target []byte
+			fd := &flagDef{
+				flagInfo: flagInfo{Name: "my-flag", Encoding: &tc.encoding},
+				Targets:  []reflect.Value{reflect.ValueOf(&target).Elem()},
+			}
+			err := fd.setValue(tc.value)
+			if tc.expectedError != "" {
+				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(err).Will(BeNil()).OrFail()
+				With(t).Verify(target).Will(EqualTo(tc.expectedBytes)).OrFail()
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		value         string
+		expectedBytes uint64
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"plain number":    {value: "512", expectedBytes: 512},
+		"bytes":           {value: "512B", expectedBytes: 512},
+		"kilobytes":       {value: "2KB", expectedBytes: 2048},
+		"kibibytes":       {value: "2KiB", expectedBytes: 2048},
+		"megabytes":       {value: "10MB", expectedBytes: 10485760},
+		"mebibytes":       {value: "10MiB", expectedBytes: 10485760},
+		"gigabytes":       {value: "1GB", expectedBytes: 1073741824},
+		"gibibytes":       {value: "1gib", expectedBytes: 1073741824},
+		"terabytes":       {value: "3TB", expectedBytes: 3298534883328},
+		"space separated": {value: "4 MB", expectedBytes: 4194304},
+		"unknown unit":    {value: "4PB", expectedError: `^unknown size unit 'PB'$`},
+		"missing number":  {value: "MB", expectedError: `invalid syntax$`},
+		"negative number": {value: "-1MB", expectedError: `^negative sizes are not supported$`},
+		"fraction":        {value: "1.5GB", expectedError: `^fractional sizes are not supported$`},
+		"leading dot":     {value: ".5GB", expectedError: `^fractional sizes are not supported$`},
+		"overflow":        {value: "17179869184GB", expectedError: `^value out of range$`},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			n, err := parseByteSize(tc.value)
+			if tc.expectedError != "" {
+				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(err).Will(BeNil()).OrFail()
+				With(t).Verify(n).Will(EqualTo(tc.expectedBytes)).OrFail()
+			}
+		})
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	t.Parallel()
+	With(t).Verify(formatByteSize(0)).Will(EqualTo("0")).OrFail()
+	With(t).Verify(formatByteSize(512)).Will(EqualTo("512B")).OrFail()
+	With(t).Verify(formatByteSize(1536)).Will(EqualTo("1536B")).OrFail()
+	With(t).Verify(formatByteSize(2048)).Will(EqualTo("2KB")).OrFail()
+	With(t).Verify(formatByteSize(10485760)).Will(EqualTo("10MB")).OrFail()
+	With(t).Verify(formatByteSize(1 << 40)).Will(EqualTo("1TB")).OrFail()
+}
+
+func TestParseBool(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		values        []string
+		expectedValue bool
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"go true literals":    {values: []string{"1", "t", "T", "true", "TRUE", "True"}, expectedValue: true},
+		"go false literals":   {values: []string{"0", "f", "F", "false", "FALSE", "False"}, expectedValue: false},
+		"extra true literals": {values: []string{"yes", "YES", "y", "Y", "on", "On", "enabled", "ENABLED"}, expectedValue: true},
+		"extra false literals": {
+			values:        []string{"no", "NO", "n", "N", "off", "Off", "disabled", "DISABLED"},
+			expectedValue: false,
+		},
+		"invalid literals": {values: []string{"", "yeah", "nope", "2", "enable"}, expectedError: `invalid syntax$`},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			for _, v := range tc.values {
+				b, err := parseBool(v)
+				if tc.expectedError != "" {
+					With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+				} else {
+					With(t).Verify(err).Will(BeNil()).OrFail()
+					With(t).Verify(b).Will(EqualTo(tc.expectedValue)).OrFail()
+				}
+			}
+		})
+	}
+}
@@ -2,19 +2,53 @@ package command
 
 import (
 	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
 )
 
 type mergedFlagDef struct {
 	flagInfo
-	applied  bool
-	flagDefs []*flagDef
+	applied     bool
+	setByUser   bool
+	setByEnv    bool
+	setByConfig bool
+	flagDefs    []*flagDef
 }
 
-func (mfd *mergedFlagDef) addFlagDef(fd *flagDef) error {
+// getValueSource returns the source of this flag's final value, i.e. the last source it was applied from.
+func (mfd *mergedFlagDef) getValueSource() string {
+	switch {
+	case mfd.setByUser:
+		return ValueSourceCLI
+	case mfd.setByEnv:
+		return ValueSourceEnv
+	case mfd.setByConfig:
+		return ValueSourceConfig
+	default:
+		return ValueSourceDefault
+	}
+}
+
+// addFlagDef merges the given flag definition into this merged flag, failing if the two are incompatible. If
+// overridableDefault is true, the given flag definition is inherited from an ancestor command which this merged flag
+// re-declares, and therefore its default value is allowed to differ (the default value of this merged flag wins).
+func (mfd *mergedFlagDef) addFlagDef(fd *flagDef, overridableDefault bool) error {
 	if fd.Name != mfd.Name {
 		return fmt.Errorf("given flag '%s' has incompatible name - must be '%s'", fd.Name, mfd.Name)
 	}
 
+	if mfd.Short == nil {
+		if fd.Short != nil {
+			mfd.Short = fd.Short
+		}
+	} else if fd.Short != nil {
+		if *mfd.Short != *fd.Short {
+			return fmt.Errorf("flag '%s' has incompatible short name '%v' - must be '%v'", fd.Name, *fd.Short, *mfd.Short)
+		}
+	}
+
 	if mfd.EnvVarName == nil {
 		if fd.EnvVarName != nil {
 			mfd.EnvVarName = fd.EnvVarName
@@ -25,6 +59,16 @@ func (mfd *mergedFlagDef) addFlagDef(fd *flagDef) error {
 		}
 	}
 
+	if mfd.EnvFallbacks == nil {
+		if fd.EnvFallbacks != nil {
+			mfd.EnvFallbacks = fd.EnvFallbacks
+		}
+	} else if fd.EnvFallbacks != nil {
+		if !slices.Equal(mfd.EnvFallbacks, fd.EnvFallbacks) {
+			return fmt.Errorf("flag '%s' has incompatible fallback environment variable names '%s' - must be '%s'", fd.Name, strings.Join(fd.EnvFallbacks, ","), strings.Join(mfd.EnvFallbacks, ","))
+		}
+	}
+
 	if fd.HasValue != mfd.HasValue {
 		if mfd.HasValue {
 			return fmt.Errorf("given flag '%s' must have a value, but it does not", fd.Name)
@@ -33,6 +77,38 @@ func (mfd *mergedFlagDef) addFlagDef(fd *flagDef) error {
 		}
 	}
 
+	if fd.Count != mfd.Count {
+		if mfd.Count {
+			return fmt.Errorf("given flag '%s' must be a count flag, but it is not", fd.Name)
+		} else {
+			return fmt.Errorf("given flag '%s' must not be a count flag, but it is", fd.Name)
+		}
+	}
+
+	if fd.Secret != mfd.Secret {
+		if mfd.Secret {
+			return fmt.Errorf("given flag '%s' must be a secret flag, but it is not", fd.Name)
+		} else {
+			return fmt.Errorf("given flag '%s' must not be a secret flag, but it is", fd.Name)
+		}
+	}
+
+	if fd.ByteSize != mfd.ByteSize {
+		if mfd.ByteSize {
+			return fmt.Errorf("given flag '%s' must be a byte size flag, but it is not", fd.Name)
+		} else {
+			return fmt.Errorf("given flag '%s' must not be a byte size flag, but it is", fd.Name)
+		}
+	}
+
+	if fd.Stdin != mfd.Stdin {
+		if mfd.Stdin {
+			return fmt.Errorf("given flag '%s' must read from stdin, but it does not", fd.Name)
+		} else {
+			return fmt.Errorf("given flag '%s' must not read from stdin, but it does", fd.Name)
+		}
+	}
+
 	if mfd.ValueName == nil {
 		if fd.ValueName != nil {
 			mfd.ValueName = fd.ValueName
@@ -53,6 +129,16 @@ func (mfd *mergedFlagDef) addFlagDef(fd *flagDef) error {
 		}
 	}
 
+	if mfd.Choices == nil {
+		if fd.Choices != nil {
+			mfd.Choices = fd.Choices
+		}
+	} else if fd.Choices != nil {
+		if !slices.Equal(mfd.Choices, fd.Choices) {
+			return fmt.Errorf("flag '%s' has incompatible choices", fd.Name)
+		}
+	}
+
 	if mfd.Required == nil {
 		if fd.Required != nil {
 			mfd.Required = fd.Required
@@ -63,7 +149,97 @@ func (mfd *mergedFlagDef) addFlagDef(fd *flagDef) error {
 		}
 	}
 
-	if fd.DefaultValue != mfd.DefaultValue {
+	if mfd.Hidden == nil {
+		if fd.Hidden != nil {
+			mfd.Hidden = fd.Hidden
+		}
+	} else if *mfd.Hidden {
+		if fd.Hidden != nil && !*fd.Hidden {
+			return fmt.Errorf("flag '%s' is incompatibly visible - must be hidden", fd.Name)
+		}
+	}
+
+	if mfd.Deprecated == nil {
+		if fd.Deprecated != nil {
+			mfd.Deprecated = fd.Deprecated
+		}
+	} else if fd.Deprecated != nil {
+		if *mfd.Deprecated != *fd.Deprecated {
+			return fmt.Errorf("flag '%s' has incompatible deprecation message", fd.Name)
+		}
+	}
+
+	if mfd.Group == nil {
+		if fd.Group != nil {
+			mfd.Group = fd.Group
+		}
+	} else if fd.Group != nil {
+		if *mfd.Group != *fd.Group {
+			return fmt.Errorf("flag '%s' has incompatible group '%s' - must be '%s'", fd.Name, *fd.Group, *mfd.Group)
+		}
+	}
+
+	if mfd.Pattern == nil {
+		if fd.Pattern != nil {
+			mfd.Pattern = fd.Pattern
+		}
+	} else if fd.Pattern != nil {
+		if mfd.Pattern.String() != fd.Pattern.String() {
+			return fmt.Errorf("flag '%s' has incompatible pattern '%s' - must be '%s'", fd.Name, fd.Pattern, mfd.Pattern)
+		}
+	}
+
+	if mfd.Min == nil {
+		if fd.Min != nil {
+			mfd.Min = fd.Min
+		}
+	} else if fd.Min != nil {
+		if *mfd.Min != *fd.Min {
+			return fmt.Errorf("flag '%s' has incompatible minimum '%s' - must be '%s'", fd.Name, *fd.Min, *mfd.Min)
+		}
+	}
+
+	if mfd.Max == nil {
+		if fd.Max != nil {
+			mfd.Max = fd.Max
+		}
+	} else if fd.Max != nil {
+		if *mfd.Max != *fd.Max {
+			return fmt.Errorf("flag '%s' has incompatible maximum '%s' - must be '%s'", fd.Name, *fd.Max, *mfd.Max)
+		}
+	}
+
+	if mfd.Prompt == nil {
+		if fd.Prompt != nil {
+			mfd.Prompt = fd.Prompt
+		}
+	} else if fd.Prompt != nil {
+		if *mfd.Prompt != *fd.Prompt {
+			return fmt.Errorf("flag '%s' has incompatible prompt '%s' - must be '%s'", fd.Name, *fd.Prompt, *mfd.Prompt)
+		}
+	}
+
+	if mfd.EnvPrefix == nil {
+		if fd.EnvPrefix != nil {
+			mfd.EnvPrefix = fd.EnvPrefix
+		}
+	} else if fd.EnvPrefix != nil {
+		if *mfd.EnvPrefix != *fd.EnvPrefix {
+			return fmt.Errorf("flag '%s' has incompatible environment variables prefix '%s' - must be '%s'", fd.Name, *fd.EnvPrefix, *mfd.EnvPrefix)
+		}
+	}
+
+	if mfd.Encoding == nil {
+		if fd.Encoding != nil {
+			mfd.Encoding = fd.Encoding
+		}
+	} else if fd.Encoding != nil {
+		if *mfd.Encoding != *fd.Encoding {
+			return fmt.Errorf("flag '%s' has incompatible encoding '%s' - must be '%s'", fd.Name, *fd.Encoding, *mfd.Encoding)
+		}
+	}
+
+	if fd.DefaultValue != mfd.DefaultValue && !overridableDefault {
 		return fmt.Errorf("flag '%s' has incompatible default value '%s' - must be '%s'", fd.Name, fd.DefaultValue, mfd.DefaultValue)
 	}
 
@@ -81,10 +257,115 @@ func (mfd *mergedFlagDef) setValue(v string) error {
 	return nil
 }
 
+// setDefaultValue sets the flag's default value (see flagDef.setDefaultValue).
+func (mfd *mergedFlagDef) setDefaultValue() error {
+	mfd.applied = true
+	for _, fd := range mfd.flagDefs {
+		if err := fd.setDefaultValue(mfd.DefaultValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendValue is similar to setValue, except that slice targets are appended to (and map targets are added to), rather
+// than replaced.
+func (mfd *mergedFlagDef) appendValue(v string) error {
+	mfd.applied = true
+	for _, fd := range mfd.flagDefs {
+		if err := fd.appendValue(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// increment increments the value of this flag by one (only applicable for count flags).
+func (mfd *mergedFlagDef) increment() error {
+	mfd.applied = true
+	for _, fd := range mfd.flagDefs {
+		if err := fd.increment(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setMapEntry sets the given key to the given value in this flag's map targets (see flagDef.setMapEntry).
+func (mfd *mergedFlagDef) setMapEntry(key, value string) error {
+	mfd.applied = true
+	for _, fd := range mfd.flagDefs {
+		if err := fd.setMapEntry(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveValue resolves a value given by the user (e.g. in the CLI) for this flag: values of the form "@path" are
+// replaced by the (trimmed) contents of the file at the given path, and values starting with "@@" are unescaped into a
+// literal value starting with "@". Other values are returned as is.
+func (mfd *mergedFlagDef) resolveValue(v string) (string, error) {
+	if !mfd.HasValue || !strings.HasPrefix(v, "@") {
+		return v, nil
+	} else if strings.HasPrefix(v, "@@") {
+		return v[1:], nil
+	} else if data, err := os.ReadFile(v[1:]); err != nil {
+		return "", fmt.Errorf("failed reading value of flag '%s' from file: %w", mfd.Name, err)
+	} else {
+		return strings.TrimSpace(string(data)), nil
+	}
+}
+
+// getEnvVarNames returns the names of the environment variables this flag is bound to, in order of precedence (the
+// primary name first, followed by any fallback names).
+func (mfd *mergedFlagDef) getEnvVarNames() []string {
+	if mfd.EnvVarName == nil {
+		return nil
+	}
+	return append([]string{*mfd.EnvVarName}, mfd.EnvFallbacks...)
+}
+
+// lookupEnvVar looks up this flag's value in the given environment variables. A flag bound to a single environment
+// variable is considered found if that variable is present, even if empty; a flag with fallback names takes the value
+// of the first of its environment variables which is present and non-empty.
+func (mfd *mergedFlagDef) lookupEnvVar(envVars map[string]string) (string, bool) {
+	if mfd.EnvVarName == nil {
+		return "", false
+	} else if len(mfd.EnvFallbacks) == 0 {
+		v, found := envVars[*mfd.EnvVarName]
+		return v, found
+	}
+	for _, name := range mfd.getEnvVarNames() {
+		if v := envVars[name]; v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// hasZeroDefault returns true if this flag's default value is the zero value of its type (e.g. "false" for booleans).
+// Flags of pointer types only have a default value if their pointer was set, which is never considered a zero value.
+func (mfd *mergedFlagDef) hasZeroDefault() bool {
+	t := mfd.flagDefs[0].Targets[0].Type()
+	if t.Kind() == reflect.Ptr {
+		return mfd.DefaultValue == ""
+	}
+	zero := &flagDef{flagInfo: flagInfo{Name: mfd.Name, Count: mfd.Count, ByteSize: mfd.ByteSize, Encoding: mfd.Encoding}}
+	if err := zero.inferFromValue(reflect.New(t).Elem()); err != nil {
+		return false
+	}
+	return zero.DefaultValue == mfd.DefaultValue
+}
+
 func (mfd *mergedFlagDef) isRequired() bool {
 	return mfd.Required != nil && *mfd.Required
 }
 
+func (mfd *mergedFlagDef) isHidden() bool {
+	return mfd.Hidden != nil && *mfd.Hidden
+}
+
 func (mfd *mergedFlagDef) isMissing() bool {
 	return mfd.isRequired() && !mfd.applied
 }
@@ -100,3 +381,8 @@ func (mfd *mergedFlagDef) getValueName() string {
 		return ""
 	}
 }
+
+// getNegatedName returns the name of the flag that negates this flag (only applicable for boolean flags).
+func (mfd *mergedFlagDef) getNegatedName() string {
+	return "no-" + mfd.Name
+}
@@ -12,10 +12,11 @@ func TestMergedFlagDefAddFlagDef(t *testing.T) {
 	t.Parallel()
 
 	type testCase struct {
-		mfd           *mergedFlagDef
-		fd            *flagDef
-		expectedError string
-		verifier      func(t T, tc *testCase)
+		mfd                *mergedFlagDef
+		fd                 *flagDef
+		overridableDefault bool
+		expectedError      string
+		verifier           func(t T, tc *testCase)
 	}
 
 	testCases := map[string]testCase{
@@ -129,20 +130,60 @@ func TestMergedFlagDefAddFlagDef(t *testing.T) {
 			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag", Required: &[]bool{false}[0]}},
 			expectedError: `flag 'my-flag' is incompatibly optional - must be required`,
 		},
+		"given choices when existing has no choices are used": {
+			mfd: &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag"}},
+			fd:  &flagDef{flagInfo: flagInfo{Name: "my-flag", Choices: []string{"a", "b"}}},
+			verifier: func(t T, tc *testCase) {
+				With(t).Verify(tc.mfd.Choices).Will(EqualTo([]string{"a", "b"})).OrFail()
+			},
+		},
+		"unexpected choices": {
+			mfd:           &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", Choices: []string{"a", "b"}}},
+			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag", Choices: []string{"a", "c"}}},
+			expectedError: `flag 'my-flag' has incompatible choices`,
+		},
+		"unexpected short name": {
+			mfd:           &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", Short: ptrOf("a")}},
+			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag", Short: ptrOf("b")}},
+			expectedError: `flag 'my-flag' has incompatible short name 'b' - must be 'a'`,
+		},
+		"unexpected visibility": {
+			mfd:           &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", Hidden: ptrOf(true)}},
+			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag", Hidden: ptrOf(false)}},
+			expectedError: `flag 'my-flag' is incompatibly visible - must be hidden`,
+		},
+		"unexpected stdin": {
+			mfd:           &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", Stdin: true}},
+			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag"}},
+			expectedError: `given flag 'my-flag' must read from stdin, but it does not`,
+		},
+		"unexpected count": {
+			mfd:           &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", Count: true}},
+			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag"}},
+			expectedError: `given flag 'my-flag' must be a count flag, but it is not`,
+		},
 		"unexpected default value": {
 			mfd:           &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", DefaultValue: "abc"}},
 			fd:            &flagDef{flagInfo: flagInfo{Name: "my-flag", DefaultValue: "abcdef"}},
 			expectedError: `flag 'my-flag' has incompatible default value 'abcdef' - must be 'abc'`,
 		},
+		"overridden default value": {
+			mfd:                &mergedFlagDef{flagInfo: flagInfo{Name: "my-flag", DefaultValue: "abc"}},
+			fd:                 &flagDef{flagInfo: flagInfo{Name: "my-flag", DefaultValue: "abcdef"}},
+			overridableDefault: true,
+			verifier: func(t T, tc *testCase) {
+				With(t).Verify(tc.mfd.DefaultValue).Will(EqualTo("abc")).OrFail()
+			},
+		},
 	}
 	for name, tc := range testCases {
 		tc := tc
 		t.Run(name, func(t *testing.T) {
 			if tc.expectedError != "" {
-				With(t).Verify(tc.mfd.addFlagDef(tc.fd)).Will(Fail(tc.expectedError)).OrFail()
+				With(t).Verify(tc.mfd.addFlagDef(tc.fd, tc.overridableDefault)).Will(Fail(tc.expectedError)).OrFail()
 				With(t).Verify(slices.Contains(tc.mfd.flagDefs, tc.fd)).Will(EqualTo(false)).OrFail()
 			} else {
-				With(t).Verify(tc.mfd.addFlagDef(tc.fd)).Will(Succeed()).OrFail()
+				With(t).Verify(tc.mfd.addFlagDef(tc.fd, tc.overridableDefault)).Will(Succeed()).OrFail()
 				With(t).Verify(slices.Contains(tc.mfd.flagDefs, tc.fd)).Will(EqualTo(true)).OrFail()
 			}
 			if tc.verifier != nil {
@@ -172,6 +213,28 @@ func TestMergedFlagDefSetValue(t *testing.T) {
 	With(t).Verify(targets).Will(EqualTo([3]string{"v1", "v1", "v1"})).OrFail()
 }
 
+func TestMergedFlagDefAppendValue(t *testing.T) {
+	t.Parallel()
+
+	targets := [2][]string{}
+	mfd := &mergedFlagDef{
+		flagInfo: flagInfo{
+			Name:     "my-flag",
+			HasValue: true,
+		},
+		flagDefs: []*flagDef{
+			{flagInfo: flagInfo{Name: "my-flag", HasValue: true}, Targets: []reflect.Value{reflect.ValueOf(&targets).Elem().Index(0)}},
+			{flagInfo: flagInfo{Name: "my-flag", HasValue: true}, Targets: []reflect.Value{reflect.ValueOf(&targets).Elem().Index(1)}},
+		},
+	}
+
+	With(t).Verify(mfd.setValue("v1,v2")).Will(Succeed()).OrFail()
+	With(t).Verify(mfd.appendValue("v3")).Will(Succeed()).OrFail()
+	With(t).Verify(targets).Will(EqualTo([2][]string{{"v1", "v2", "v3"}, {"v1", "v2", "v3"}})).OrFail()
+	With(t).Verify(mfd.setValue("v4")).Will(Succeed()).OrFail()
+	With(t).Verify(targets).Will(EqualTo([2][]string{{"v4"}, {"v4"}})).OrFail()
+}
+
 func TestMergedFlagDefIsRequired(t *testing.T) {
 	t.Parallel()
 
@@ -2,15 +2,20 @@ package command
 
 import (
 	"cmp"
+	"encoding"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"os"
+	"path/filepath"
 	"reflect"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type Tag string
@@ -18,12 +23,47 @@ type Tag string
 const (
 	TagFlag        Tag = "flag"
 	TagName        Tag = "name"
+	TagShort       Tag = "short"
 	TagEnv         Tag = "env"
 	TagValueName   Tag = "value-name"
 	TagDescription Tag = "desc"
 	TagRequired    Tag = "required"
 	TagInherited   Tag = "inherited"
 	TagArgs        Tag = "args"
+	TagArg         Tag = "arg"
+	TagRawArgs     Tag = "rawargs"
+	TagChoices     Tag = "choices"
+	TagCount       Tag = "count"
+	TagStdin       Tag = "stdin"
+	TagHidden      Tag = "hidden"
+	TagDeprecated  Tag = "deprecated"
+	TagGroup       Tag = "group"
+	TagPattern     Tag = "pattern"
+	TagMin         Tag = "min"
+	TagMax         Tag = "max"
+	TagEncoding    Tag = "encoding"
+	TagByteSize    Tag = "bytesize"
+	TagPrompt      Tag = "prompt"
+	TagSecret      Tag = "secret"
+	TagEnvPrefix   Tag = "envprefix"
+)
+
+// Sources of flag values, as reported by [Command.ValueSources].
+const (
+	ValueSourceDefault = "default"
+	ValueSourceConfig  = "config"
+	ValueSourceEnv     = "env"
+	ValueSourceCLI     = "cli"
+)
+
+// noEnvVarName is the value of the "env" tag that disables binding a flag to an environment variable.
+const noEnvVarName = "-"
+
+// Default names of the built-in help flag (see [HelpConfig]); the short name is only given to it if no other flag uses
+// it. The long name can be changed via [Command.SetHelpFlag].
+const (
+	helpFlagName      = "help"
+	helpFlagShortName = "h"
 )
 
 type ErrInvalidTag struct {
@@ -41,18 +81,31 @@ func (e *ErrInvalidTag) Unwrap() error {
 }
 
 type ErrUnknownFlag struct {
-	Cause error
-	Flag  string
+	Cause      error
+	Flag       string
+	Suggestion string
 }
 
 func (e *ErrUnknownFlag) Error() string {
-	return fmt.Sprintf("unknown flag: --%s", e.Flag)
+	if e.Suggestion != "" {
+		return fmt.Sprintf("unknown flag: %s (did you mean %s?)", formatFlagArg(e.Flag), formatFlagArg(e.Suggestion))
+	}
+	return fmt.Sprintf("unknown flag: %s", formatFlagArg(e.Flag))
 }
 
 func (e *ErrUnknownFlag) Unwrap() error {
 	return e.Cause
 }
 
+// formatFlagArg formats the given flag name as given in the command line: single-character (short) names are prefixed
+// with a single dash (e.g. "-h"), and other names with two dashes (e.g. "--help").
+func formatFlagArg(name string) string {
+	if utf8.RuneCountInString(name) == 1 {
+		return "-" + name
+	}
+	return "--" + name
+}
+
 type ErrRequiredFlagMissing struct {
 	Cause error
 	Flag  string
@@ -66,10 +119,129 @@ func (e *ErrRequiredFlagMissing) Unwrap() error {
 	return e.Cause
 }
 
+type ErrMutuallyExclusiveFlags struct {
+	Flags []string
+}
+
+func (e *ErrMutuallyExclusiveFlags) Error() string {
+	return fmt.Sprintf("mutually exclusive flags given together: --%s", strings.Join(e.Flags, ", --"))
+}
+
+type ErrRequiredFlagGroupMissing struct {
+	Flags []string
+}
+
+func (e *ErrRequiredFlagGroupMissing) Error() string {
+	return fmt.Sprintf("at least one of the flags is required: --%s", strings.Join(e.Flags, ", --"))
+}
+
+// FlagSortMode determines the order in which flags are listed in help screens & usage lines.
+type FlagSortMode int
+
+const (
+	SortAlphabetical FlagSortMode = iota // Flags are sorted by name (the default)
+	SortDeclaration                      // Flags are listed in the order they are declared in their structs
+)
+
+// ErrPositionalsCount is returned when the number of positional arguments given to a command does not match its
+// positional arguments specification (see [Command.SetPositionalSpec]).
+type ErrPositionalsCount struct {
+	Usage  string
+	Min    int
+	Max    int
+	Actual int
+}
+
+func (e *ErrPositionalsCount) Error() string {
+	var expected string
+	switch {
+	case e.Min == e.Max:
+		expected = fmt.Sprintf("exactly %d", e.Min)
+	case e.Max < 0:
+		expected = fmt.Sprintf("at least %d", e.Min)
+	case e.Min == 0:
+		expected = fmt.Sprintf("at most %d", e.Max)
+	default:
+		expected = fmt.Sprintf("between %d and %d", e.Min, e.Max)
+	}
+	return fmt.Sprintf("expected %s positional arguments (%s), got %d", expected, e.Usage, e.Actual)
+}
+
+// positionalsSpec describes the names & number of positional arguments a command accepts.
+type positionalsSpec struct {
+	names []string
+	min   int
+	max   int // negative for no maximum
+}
+
+// usage returns the usage string of positional arguments described by this spec, e.g. "SRC [DST]" or "FILE...".
+func (ps *positionalsSpec) usage() string {
+	var parts []string
+	for i, name := range ps.names {
+		if ps.max < 0 || ps.max > len(ps.names) {
+			if i == len(ps.names)-1 {
+				name += "..."
+			}
+		}
+		if i >= ps.min {
+			name = "[" + name + "]"
+		}
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// verify returns an [ErrPositionalsCount] error if the number of given positional arguments does not match this spec.
+func (ps *positionalsSpec) verify(positionals []string) error {
+	if len(positionals) < ps.min || (ps.max >= 0 && len(positionals) > ps.max) {
+		return &ErrPositionalsCount{Usage: ps.usage(), Min: ps.min, Max: ps.max, Actual: len(positionals)}
+	}
+	return nil
+}
+
+// ErrInvalidPositional is returned when a positional argument cannot be converted to the type of the field it is bound
+// to (see the "arg" tag).
+type ErrInvalidPositional struct {
+	Cause    error
+	Position int // 1-based position of the positional argument
+	Name     string
+	Value    string
+}
+
+func (e *ErrInvalidPositional) Error() string {
+	return fmt.Sprintf("invalid value '%s' for positional argument #%d (%s): %s", e.Value, e.Position, e.Name, e.Cause)
+}
+
+func (e *ErrInvalidPositional) Unwrap() error {
+	return e.Cause
+}
+
 type flagSet struct {
-	flags              []*flagDef
-	parent             *flagSet
-	positionalsTargets []*[]string
+	flags                   []*flagDef
+	parent                  *flagSet
+	sortMode                *FlagSortMode
+	hideZeroDefaults        *bool
+	showFlagAliases         *bool
+	flagAliases             map[string]string
+	envPrefix               string
+	validators              map[string]func(string) error
+	normalizers             map[string]func(string) (string, error)
+	defaultValues           map[string]string
+	positionalsTargets      []*[]string
+	typedPositionalsTargets []reflect.Value
+	positionalTargets       []*flagDef
+	positionalsSpec         *positionalsSpec
+	rawArgsTargets          []*[]string
+	valueSources            map[string]string
+	configFileFlagName      string
+	helpFlagName            string
+	noHelpFlag              bool
+	configDecoders          map[string]ConfigDecoder
+	exclusiveFlagGroups     [][]string
+	oneRequiredFlagGroups   [][]string
+	stdin                   io.Reader
+	promptMissing           bool
+	interactive             *bool // overrides whether stdin is considered an interactive terminal
 }
 
 func newFlagSet(parent *flagSet, objects ...reflect.Value) (*flagSet, error) {
@@ -101,6 +273,38 @@ func (fs *flagSet) hasFlags() bool {
 	return false
 }
 
+func (fs *flagSet) hasPositionalsTargets() bool {
+	if len(fs.positionalTargets) > 0 {
+		return true
+	}
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if len(cfs.positionalsTargets) > 0 || len(cfs.typedPositionalsTargets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// hasRawArgsTargets returns true if this flag set or any of its parents has fields receiving the raw arguments given
+// after the "--" separator (see [TagRawArgs]).
+func (fs *flagSet) hasRawArgsTargets() bool {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if len(cfs.rawArgsTargets) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setRawArgs sets the given raw arguments to the raw arguments targets of this flag set & its parents.
+func (fs *flagSet) setRawArgs(rawArgs []string) {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		for _, target := range cfs.rawArgsTargets {
+			*target = rawArgs
+		}
+	}
+}
+
 func (fs *flagSet) readFlagsFromStruct(s reflect.Value, defaultInherited bool) error {
 	for i := 0; i < s.NumField(); i++ {
 		fieldValue := s.Field(i)
@@ -146,14 +350,32 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 		flagTag = TagName
 		fd.flagInfo.Name = tag
 	}
+	if tag, ok := structField.Tag.Lookup(string(TagShort)); ok {
+		if tag == "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagShort, Value: tag}
+		} else if len([]rune(tag)) != 1 || tag == "-" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must be a single character"), Tag: TagShort, Value: tag}
+		}
+		flagTag = TagShort
+		fd.flagInfo.Short = &tag
+	}
 	if tag, ok := structField.Tag.Lookup(string(TagEnv)); ok {
 		if tag == "" {
 			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagEnv, Value: tag}
-		} else {
-			tag = strings.ToUpper(tag)
+		}
+		names := strings.Split(strings.ToUpper(tag), ",")
+		for _, name := range names {
+			if name == "" {
+				return &ErrInvalidTag{Cause: fmt.Errorf("must not contain empty names"), Tag: TagEnv, Value: tag}
+			} else if len(names) > 1 && name == noEnvVarName {
+				return &ErrInvalidTag{Cause: fmt.Errorf("'%s' cannot be combined with other names", noEnvVarName), Tag: TagEnv, Value: tag}
+			}
 		}
 		flagTag = TagEnv
-		fd.flagInfo.EnvVarName = &tag
+		fd.flagInfo.EnvVarName = &names[0]
+		if len(names) > 1 {
+			fd.flagInfo.EnvFallbacks = names[1:]
+		}
 	}
 	if tag, ok := structField.Tag.Lookup(string(TagValueName)); ok {
 		if tag == "" {
@@ -180,6 +402,32 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 			fd.flagInfo.Required = ptrOf(v)
 		}
 	}
+	if tag, ok := structField.Tag.Lookup(string(TagHidden)); ok {
+		if v, err := strconv.ParseBool(tag); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidTag{Cause: err, Tag: TagHidden, Value: tag}
+		} else {
+			flagTag = TagHidden
+			fd.flagInfo.Hidden = ptrOf(v)
+		}
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagDeprecated)); ok {
+		if tag == "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagDeprecated, Value: tag}
+		}
+		flagTag = TagDeprecated
+		fd.flagInfo.Deprecated = &tag
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagGroup)); ok {
+		if tag == "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagGroup, Value: tag}
+		}
+		flagTag = TagGroup
+		fd.flagInfo.Group = &tag
+	}
 	if tag, ok := structField.Tag.Lookup(string(TagInherited)); ok {
 		if v, err := strconv.ParseBool(tag); err != nil {
 			var ne *strconv.NumError
@@ -192,6 +440,135 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 			fd.Inherited = v
 		}
 	}
+	if tag, ok := structField.Tag.Lookup(string(TagChoices)); ok {
+		var choices []string
+		for _, choice := range strings.Split(tag, ",") {
+			if choice = strings.TrimSpace(choice); choice == "" {
+				return &ErrInvalidTag{Cause: fmt.Errorf("must not contain empty choices"), Tag: TagChoices, Value: tag}
+			} else {
+				choices = append(choices, choice)
+			}
+		}
+		flagTag = TagChoices
+		fd.flagInfo.Choices = choices
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagPattern)); ok {
+		if !isStringOrStringSlice(fieldValue.Type()) {
+			return &ErrInvalidTag{Cause: fmt.Errorf("only supported for string & string slice fields"), Tag: TagPattern, Value: tag}
+		} else if pattern, err := regexp.Compile(tag); err != nil {
+			return &ErrInvalidTag{Cause: err, Tag: TagPattern, Value: tag}
+		} else {
+			flagTag = TagPattern
+			fd.flagInfo.Pattern = pattern
+		}
+	}
+	for _, bound := range []struct {
+		tag    Tag
+		target **string
+	}{{TagMin, &fd.flagInfo.Min}, {TagMax, &fd.flagInfo.Max}} {
+		if tag, ok := structField.Tag.Lookup(string(bound.tag)); ok {
+			if !isNumericType(fieldValue.Type()) {
+				return &ErrInvalidTag{Cause: fmt.Errorf("only supported for numeric fields"), Tag: bound.tag, Value: tag}
+			} else if _, err := parseBound(fieldValue.Type(), tag); err != nil {
+				var ne *strconv.NumError
+				if errors.As(err, &ne) {
+					err = ne.Err
+				}
+				return &ErrInvalidTag{Cause: err, Tag: bound.tag, Value: tag}
+			}
+			flagTag = bound.tag
+			*bound.target = &tag
+		}
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagEnvPrefix)); ok {
+		if t := fieldValue.Type(); t.Kind() != reflect.Map || t.Key().Kind() != reflect.String || t.Elem().Kind() != reflect.String {
+			return &ErrInvalidTag{Cause: fmt.Errorf("only supported for map[string]string fields"), Tag: TagEnvPrefix, Value: tag}
+		} else if tag == "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagEnvPrefix, Value: tag}
+		}
+		tag = strings.ToUpper(tag)
+		flagTag = TagEnvPrefix
+		fd.flagInfo.EnvPrefix = &tag
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagEncoding)); ok {
+		if t := fieldValue.Type(); !isBytesType(t) && (t.Kind() != reflect.Ptr || !isBytesType(t.Elem())) {
+			return &ErrInvalidTag{Cause: fmt.Errorf("only supported for []byte fields"), Tag: TagEncoding, Value: tag}
+		} else if tag != bytesEncodingRaw && tag != bytesEncodingBase64 && tag != bytesEncodingHex {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must be one of: %s, %s, %s", bytesEncodingBase64, bytesEncodingHex, bytesEncodingRaw), Tag: TagEncoding, Value: tag}
+		}
+		flagTag = TagEncoding
+		fd.flagInfo.Encoding = &tag
+	}
+	if fd.flagInfo.Min != nil && fd.flagInfo.Max != nil {
+		if minimum, err := parseBound(fieldValue.Type(), *fd.flagInfo.Min); err != nil {
+			return err
+		} else if compareToBound(minimum, *fd.flagInfo.Max) > 0 {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be lower than minimum %s", *fd.flagInfo.Min), Tag: TagMax, Value: *fd.flagInfo.Max}
+		}
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagCount)); ok {
+		if v, err := strconv.ParseBool(tag); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidTag{Cause: err, Tag: TagCount, Value: tag}
+		} else if v && !isIntegerKind(fieldValue.Kind()) {
+			return &ErrInvalidTag{Cause: fmt.Errorf("only supported for integer fields"), Tag: TagCount, Value: tag}
+		} else {
+			flagTag = TagCount
+			fd.flagInfo.Count = v
+		}
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagByteSize)); ok {
+		if v, err := strconv.ParseBool(tag); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidTag{Cause: err, Tag: TagByteSize, Value: tag}
+		} else if t := fieldValue.Type(); v && (!isIntegerKind(t.Kind()) || t == durationType) {
+			return &ErrInvalidTag{Cause: fmt.Errorf("only supported for integer fields"), Tag: TagByteSize, Value: tag}
+		} else if v && fd.flagInfo.Count {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used with the '%s' tag", TagCount), Tag: TagByteSize, Value: tag}
+		} else {
+			flagTag = TagByteSize
+			fd.flagInfo.ByteSize = v
+		}
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagPrompt)); ok {
+		if tag == "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagPrompt, Value: tag}
+		}
+		flagTag = TagPrompt
+		fd.flagInfo.Prompt = &tag
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagSecret)); ok {
+		if v, err := strconv.ParseBool(tag); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidTag{Cause: err, Tag: TagSecret, Value: tag}
+		} else {
+			flagTag = TagSecret
+			fd.flagInfo.Secret = v
+		}
+	}
+	if tag, ok := structField.Tag.Lookup(string(TagStdin)); ok {
+		if v, err := strconv.ParseBool(tag); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidTag{Cause: err, Tag: TagStdin, Value: tag}
+		} else if v && fieldValue.Kind() != reflect.String {
+			return &ErrInvalidTag{Cause: fmt.Errorf("only supported for string fields"), Tag: TagStdin, Value: tag}
+		} else {
+			flagTag = TagStdin
+			fd.flagInfo.Stdin = v
+		}
+	}
 	if tag, ok := structField.Tag.Lookup(string(TagArgs)); ok {
 		if v, err := strconv.ParseBool(tag); err != nil {
 			var ne *strconv.NumError
@@ -204,10 +581,41 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 		}
 	}
 
-	if fieldValue.Kind() == reflect.Struct {
+	var rawArgs bool
+	if tag, ok := structField.Tag.Lookup(string(TagRawArgs)); ok {
+		if v, err := strconv.ParseBool(tag); err != nil {
+			var ne *strconv.NumError
+			if errors.As(err, &ne) {
+				err = ne.Err
+			}
+			return &ErrInvalidTag{Cause: err, Tag: TagRawArgs, Value: tag}
+		} else if v && args {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used with the '%s' tag", TagArgs), Tag: TagRawArgs, Value: tag}
+		} else {
+			rawArgs = v
+		}
+	}
+
+	var argName string
+	if tag, ok := structField.Tag.Lookup(string(TagArg)); ok {
+		if tag == "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must not be empty"), Tag: TagArg, Value: tag}
+		} else if args {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used with the '%s' tag", TagArgs), Tag: TagArg, Value: tag}
+		} else if rawArgs {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used with the '%s' tag", TagRawArgs), Tag: TagArg, Value: tag}
+		}
+		argName = tag
+	}
+
+	if isConfigStruct(fieldValue) {
 		// Struct fields are only containers for other fields; if the struct is tagged with "args" or any flag tag, fail
 		if args {
 			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used on struct fields"), Tag: TagArgs, Value: strconv.FormatBool(args)}
+		} else if rawArgs {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used on struct fields"), Tag: TagRawArgs, Value: strconv.FormatBool(rawArgs)}
+		} else if argName != "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used on struct fields"), Tag: TagArg, Value: argName}
 		} else if flagTag != "" {
 			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be used on struct fields"), Tag: flagTag, Value: structField.Tag.Get(string(flagTag))}
 		} else if err := fs.readFlagsFromStruct(fieldValue, fd.Inherited); err != nil {
@@ -215,7 +623,7 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 		} else {
 			return nil
 		}
-	} else if !args && flagTag == "" {
+	} else if !args && !rawArgs && argName == "" && flagTag == "" {
 		// Neither a positional args target nor a flag - do nothing and exit
 		return nil
 	} else if !fieldValue.CanAddr() {
@@ -225,61 +633,99 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 		// Field must be settable or we will not be able to update it with CLI arguments
 		return fmt.Errorf("not settable")
 	} else if args {
-		// If field is tagged with "args", it cannot also serve as a flag; it also must be of type "[]string"
+		// If field is tagged with "args", it cannot also serve as a flag; it also must be of type "[]string", or a slice
+		// of a type implementing "encoding.TextUnmarshaler" (in which case each positional argument is parsed by it)
 		if flagTag != "" {
 			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be a flag as well"), Tag: TagArgs, Value: strconv.FormatBool(args)}
 		} else if structField.Type.ConvertibleTo(reflect.TypeOf([]string{})) {
 			fs.positionalsTargets = append(fs.positionalsTargets, fieldValue.Addr().Interface().(*[]string))
 			return nil
+		} else if structField.Type.Kind() == reflect.Slice && isTextUnmarshaler(structField.Type.Elem()) {
+			fs.typedPositionalsTargets = append(fs.typedPositionalsTargets, fieldValue)
+			return nil
+		} else {
+			return &ErrInvalidTag{Cause: fmt.Errorf("must be typed as []string or a slice of encoding.TextUnmarshaler"), Tag: TagArgs, Value: strconv.FormatBool(args)}
+		}
+	} else if rawArgs {
+		// If field is tagged with "rawargs", it cannot also serve as a flag; it also must be of type "[]string"
+		if flagTag != "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be a flag as well"), Tag: TagRawArgs, Value: strconv.FormatBool(rawArgs)}
+		} else if structField.Type.ConvertibleTo(reflect.TypeOf([]string{})) {
+			fs.rawArgsTargets = append(fs.rawArgsTargets, fieldValue.Addr().Interface().(*[]string))
+			return nil
 		} else {
-			return &ErrInvalidTag{Cause: fmt.Errorf("must be typed as []string"), Tag: TagArgs, Value: strconv.FormatBool(args)}
+			return &ErrInvalidTag{Cause: fmt.Errorf("must be typed as []string"), Tag: TagRawArgs, Value: strconv.FormatBool(rawArgs)}
+		}
+	} else if argName != "" {
+		// If field is tagged with "arg", it cannot also serve as a flag; it is bound to the positional argument at the
+		// same position as the field's declaration order among other such fields, and converted like flag values are
+		if flagTag != "" {
+			return &ErrInvalidTag{Cause: fmt.Errorf("cannot be a flag as well"), Tag: TagArg, Value: argName}
+		} else if k := fieldValue.Kind(); k == reflect.Slice && fieldValue.Type() != ipType || k == reflect.Map {
+			return &ErrInvalidTag{Cause: fmt.Errorf("unsupported field type: %s", fieldValue.Type()), Tag: TagArg, Value: argName}
 		}
+		fs.positionalTargets = append(fs.positionalTargets, &flagDef{
+			flagInfo: flagInfo{Name: argName, HasValue: true},
+			Targets:  []reflect.Value{fieldValue},
+		})
+		return nil
 	}
 
-	// Configure whether flag should be given a value in the CLI, and the default value if one is not provided
-	switch fieldValue.Kind() {
-	case reflect.Bool:
-		fd.HasValue = false
-		fd.DefaultValue = "false"
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		fd.HasValue = true
-		fd.DefaultValue = strconv.FormatInt(fieldValue.Int(), 10)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		fd.HasValue = true
-		fd.DefaultValue = strconv.FormatUint(fieldValue.Uint(), 10)
-	case reflect.Float32, reflect.Float64:
-		fd.HasValue = true
-		fd.DefaultValue = strconv.FormatFloat(fieldValue.Float(), 'g', -1, 64)
-	case reflect.String:
-		fd.HasValue = true
-		fd.DefaultValue = fieldValue.String()
-	case reflect.Slice:
-		fd.HasValue = true
-		var defaultValues []string
-		for i := 0; i < fieldValue.Len(); i++ {
-			defaultValues = append(defaultValues, fieldValue.Index(i).String())
-		}
-		if defaultValues != nil {
-			fd.DefaultValue = strings.Join(defaultValues, ",")
+	// Pointer fields are only set if the flag is given, so nil pointers have no default value; otherwise, the flag is
+	// configured according to the pointee's type & value
+	hasDefaultValue := true
+	if fieldValue.Kind() == reflect.Ptr {
+		if elemType := fieldValue.Type().Elem(); elemType.Kind() == reflect.Ptr || isConfigStruct(reflect.New(elemType).Elem()) {
+			return fmt.Errorf("unsupported field type: %s", fieldValue.Type())
+		} else if fieldValue.IsNil() {
+			hasDefaultValue = false
+			fieldValue = reflect.New(elemType).Elem()
 		} else {
-			fd.DefaultValue = ""
+			fieldValue = fieldValue.Elem()
 		}
-	default:
-		// Unsupported flag field type
-		return fmt.Errorf("unsupported field type: %s", fieldValue.Kind())
+	}
+
+	// Configure whether flag should be given a value in the CLI, and the default value if one is not provided
+	if err := fd.inferFromValue(fieldValue); err != nil {
+		return err
+	}
+	if !hasDefaultValue {
+		fd.DefaultValue = ""
 	}
 
 	// Otherwise, this is a flag - check if it has already been registered?
 	for _, fdi := range fs.flags {
 		if fdi.Name == fd.Name {
+			if fdi.Short == nil {
+				fdi.Short = fd.Short
+			} else if fd.Short != nil && *fdi.Short != *fd.Short {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine short name"), Tag: TagShort, Value: *fd.Short}
+			}
 			if fdi.EnvVarName == nil {
 				fdi.EnvVarName = fd.EnvVarName
 			} else if fd.EnvVarName != nil && *fdi.EnvVarName != *fd.EnvVarName {
 				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine environment variable name"), Tag: TagEnv, Value: *fd.EnvVarName}
 			}
+			if fdi.EnvFallbacks == nil {
+				fdi.EnvFallbacks = fd.EnvFallbacks
+			} else if fd.EnvFallbacks != nil && !slices.Equal(fdi.EnvFallbacks, fd.EnvFallbacks) {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine fallback environment variable names"), Tag: TagEnv, Value: strings.Join(fd.EnvFallbacks, ",")}
+			}
 			if fdi.HasValue != fd.HasValue {
 				return fmt.Errorf("incompatible field types detected (is one a bool and another isn't?)")
 			}
+			if fdi.Count != fd.Count {
+				return fmt.Errorf("incompatible count status detected: '%v' vs '%v'", fdi.Count, fd.Count)
+			}
+			if fdi.ByteSize != fd.ByteSize {
+				return fmt.Errorf("incompatible byte size status detected: '%v' vs '%v'", fdi.ByteSize, fd.ByteSize)
+			}
+			if fdi.Stdin != fd.Stdin {
+				return fmt.Errorf("incompatible stdin status detected: '%v' vs '%v'", fdi.Stdin, fd.Stdin)
+			}
+			if fdi.Secret != fd.Secret {
+				return fmt.Errorf("incompatible secret status detected: '%v' vs '%v'", fdi.Secret, fd.Secret)
+			}
 			if fdi.ValueName == nil {
 				fdi.ValueName = fd.ValueName
 			} else if fd.ValueName != nil && *fdi.ValueName != *fd.ValueName {
@@ -290,11 +736,61 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 			} else if fd.Description != nil && *fdi.Description != *fd.Description {
 				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine description"), Tag: TagDescription, Value: *fd.Description}
 			}
+			if fdi.Choices == nil {
+				fdi.Choices = fd.Choices
+			} else if fd.Choices != nil && !slices.Equal(fdi.Choices, fd.Choices) {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine choices"), Tag: TagChoices, Value: strings.Join(fd.Choices, ",")}
+			}
 			if fdi.Required == nil {
 				fdi.Required = fd.Required
 			} else if fd.Required != nil && *fdi.Required != *fd.Required {
 				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine required status"), Tag: TagRequired, Value: strconv.FormatBool(*fd.Required)}
 			}
+			if fdi.Hidden == nil {
+				fdi.Hidden = fd.Hidden
+			} else if fd.Hidden != nil && *fdi.Hidden != *fd.Hidden {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine hidden status"), Tag: TagHidden, Value: strconv.FormatBool(*fd.Hidden)}
+			}
+			if fdi.Deprecated == nil {
+				fdi.Deprecated = fd.Deprecated
+			} else if fd.Deprecated != nil && *fdi.Deprecated != *fd.Deprecated {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine deprecation message"), Tag: TagDeprecated, Value: *fd.Deprecated}
+			}
+			if fdi.Pattern == nil {
+				fdi.Pattern = fd.Pattern
+			} else if fd.Pattern != nil && fdi.Pattern.String() != fd.Pattern.String() {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine pattern"), Tag: TagPattern, Value: fd.Pattern.String()}
+			}
+			if fdi.Min == nil {
+				fdi.Min = fd.Min
+			} else if fd.Min != nil && *fdi.Min != *fd.Min {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine minimum"), Tag: TagMin, Value: *fd.Min}
+			}
+			if fdi.Max == nil {
+				fdi.Max = fd.Max
+			} else if fd.Max != nil && *fdi.Max != *fd.Max {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine maximum"), Tag: TagMax, Value: *fd.Max}
+			}
+			if fdi.EnvPrefix == nil {
+				fdi.EnvPrefix = fd.EnvPrefix
+			} else if fd.EnvPrefix != nil && *fdi.EnvPrefix != *fd.EnvPrefix {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine environment variables prefix"), Tag: TagEnvPrefix, Value: *fd.EnvPrefix}
+			}
+			if fdi.Encoding == nil {
+				fdi.Encoding = fd.Encoding
+			} else if fd.Encoding != nil && *fdi.Encoding != *fd.Encoding {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine encoding"), Tag: TagEncoding, Value: *fd.Encoding}
+			}
+			if fdi.Prompt == nil {
+				fdi.Prompt = fd.Prompt
+			} else if fd.Prompt != nil && *fdi.Prompt != *fd.Prompt {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine prompt"), Tag: TagPrompt, Value: *fd.Prompt}
+			}
+			if fdi.Group == nil {
+				fdi.Group = fd.Group
+			} else if fd.Group != nil && *fdi.Group != *fd.Group {
+				return &ErrInvalidTag{Cause: fmt.Errorf("cannot redefine group"), Tag: TagGroup, Value: *fd.Group}
+			}
 			if fdi.DefaultValue != fd.DefaultValue {
 				return fmt.Errorf("incompatible default values detected: '%s' vs '%s'", fdi.DefaultValue, fd.DefaultValue)
 			}
@@ -306,40 +802,73 @@ func (fs *flagSet) readFlagFromField(fieldValue reflect.Value, structField refle
 		}
 	}
 
-	// New flag, add it as is
+	// New flag, add it as is (remembering its declaration order)
+	fd.index = len(fs.flags)
 	fs.flags = append(fs.flags, fd)
 	return nil
 }
 
 func (fs *flagSet) getMergedFlagDefs() ([]*mergedFlagDef, error) {
 	flags := make(map[string]*mergedFlagDef)
-	for cfs := fs; cfs != nil; cfs = cfs.parent {
+	declarations := make(map[string][2]int) // flag name -> [distance of declaring flag set, index in that flag set]
+	distance := 0
+	for cfs := fs; cfs != nil; cfs, distance = cfs.parent, distance+1 {
 		for _, fd := range cfs.flags {
 			if cfs == fs || fd.Inherited {
 				if mfd, ok := flags[fd.Name]; !ok {
+					declarations[fd.Name] = [2]int{distance, fd.index}
 					flags[fd.Name] = &mergedFlagDef{
 						flagInfo: flagInfo{
 							Name:         fd.Name,
+							Short:        fd.Short,
 							EnvVarName:   fd.EnvVarName,
+							EnvFallbacks: fd.EnvFallbacks,
 							HasValue:     fd.HasValue,
+							Count:        fd.Count,
+							Stdin:        fd.Stdin,
+							ByteSize:     fd.ByteSize,
 							ValueName:    fd.ValueName,
 							Description:  fd.Description,
+							Choices:      fd.Choices,
 							Required:     fd.Required,
+							Hidden:       fd.Hidden,
+							Deprecated:   fd.Deprecated,
+							Group:        fd.Group,
+							Pattern:      fd.Pattern,
+							Min:          fd.Min,
+							Max:          fd.Max,
+							EnvPrefix:    fd.EnvPrefix,
+							Encoding:     fd.Encoding,
+							Prompt:       fd.Prompt,
+							Secret:       fd.Secret,
 							DefaultValue: fd.DefaultValue,
 						},
 						applied:  false,
 						flagDefs: []*flagDef{fd},
 					}
-				} else if err := mfd.addFlagDef(fd); err != nil {
+				} else if err := mfd.addFlagDef(fd, distance > declarations[fd.Name][0]); err != nil {
 					return nil, err
 				}
 			}
 		}
 	}
+	// Give the built-in help flag its short name, unless another flag already uses it
+	if help, ok := flags[fs.getHelpFlagName()]; ok && help.Short == nil {
+		shortNameTaken := false
+		for _, mfd := range flags {
+			shortNameTaken = shortNameTaken || defaultIfNil(mfd.Short, "") == helpFlagShortName || mfd.Name == helpFlagShortName
+		}
+		if !shortNameTaken {
+			help.Short = ptrOf(helpFlagShortName)
+		}
+	}
 	var mergedFlagDefs []*mergedFlagDef
+	envPrefix := fs.getEnvPrefix()
 	for _, mfd := range flags {
 		if mfd.EnvVarName == nil {
-			mfd.EnvVarName = ptrOf(flagNameToEnvVarName(mfd.Name))
+			mfd.EnvVarName = ptrOf(envPrefix + flagNameToEnvVarName(mfd.Name))
+		} else if *mfd.EnvVarName == noEnvVarName {
+			mfd.EnvVarName = nil
 		}
 		if mfd.ValueName == nil {
 			mfd.ValueName = ptrOf("VALUE")
@@ -347,84 +876,577 @@ func (fs *flagSet) getMergedFlagDefs() ([]*mergedFlagDef, error) {
 		if mfd.Required == nil {
 			mfd.Required = ptrOf(false)
 		}
+		if v, ok := fs.getDefaultValue(mfd.Name); ok {
+			mfd.DefaultValue = v
+		}
 		sort.Slice(mfd.flagDefs, func(ai, bi int) bool { return mfd.flagDefs[ai].isLessThan(mfd.flagDefs[bi]) })
 		mergedFlagDefs = append(mergedFlagDefs, mfd)
 	}
-	sort.Slice(mergedFlagDefs, func(ai, bi int) bool { return cmp.Less(mergedFlagDefs[ai].Name, mergedFlagDefs[bi].Name) })
+	if fs.getSortMode() == SortDeclaration {
+		// Flags of this flag set come first (in declaration order), followed by flags inherited from its parents
+		sort.Slice(mergedFlagDefs, func(ai, bi int) bool {
+			a, b := declarations[mergedFlagDefs[ai].Name], declarations[mergedFlagDefs[bi].Name]
+			return a[0] < b[0] || a[0] == b[0] && a[1] < b[1]
+		})
+	} else {
+		sort.Slice(mergedFlagDefs, func(ai, bi int) bool { return cmp.Less(mergedFlagDefs[ai].Name, mergedFlagDefs[bi].Name) })
+	}
 	return mergedFlagDefs, nil
 }
 
-func (fs *flagSet) apply(envVars map[string]string, args []string) error {
-	if args == nil {
-		args = []string{}
-	}
-	if envVars == nil {
-		envVars = make(map[string]string)
+// getValidator returns the validator registered for the given flag in this flag set, or in the closest parent flag set
+// that provides it as an inherited flag; nil is returned if there's no such validator.
+func (fs *flagSet) getValidator(name string) func(string) error {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs != fs && !slices.ContainsFunc(cfs.flags, func(fd *flagDef) bool { return fd.Name == name && fd.Inherited }) {
+			continue
+		} else if validator, ok := cfs.validators[name]; ok {
+			return validator
+		}
 	}
+	return nil
+}
 
-	stdFs := flag.NewFlagSet("", flag.ContinueOnError)
-	stdFs.SetOutput(io.Discard)
+// getNormalizer returns the normalizer registered for the given flag in this flag set, or in the closest parent flag set
+// that provides it as an inherited flag; nil is returned if there's no such normalizer.
+func (fs *flagSet) getNormalizer(name string) func(string) (string, error) {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs != fs && !slices.ContainsFunc(cfs.flags, func(fd *flagDef) bool { return fd.Name == name && fd.Inherited }) {
+			continue
+		} else if normalizer, ok := cfs.normalizers[name]; ok {
+			return normalizer
+		}
+	}
+	return nil
+}
 
-	// Merge flags from this flag set and its parents
-	mergedFlagDefs, err := fs.getMergedFlagDefs()
-	if err != nil {
-		return err
+// getDefaultValue returns the default value set for the given flag in this flag set, or in the closest parent flag set
+// that provides it as an inherited flag (see [Command.SetDefault]); false is returned if there's no such default value.
+func (fs *flagSet) getDefaultValue(name string) (string, bool) {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs != fs && !slices.ContainsFunc(cfs.flags, func(fd *flagDef) bool { return fd.Name == name && fd.Inherited }) {
+			continue
+		} else if v, ok := cfs.defaultValues[name]; ok {
+			return v, true
+		}
 	}
+	return "", false
+}
 
-	// Iterate flags and define them in the stdlib FlagSet
-	for _, mfd := range mergedFlagDefs {
+// validate invokes the validator of the given flag (if any) with the given value given by the user.
+func (fs *flagSet) validate(mfd *mergedFlagDef, v string) error {
+	if validator := fs.getValidator(mfd.Name); validator != nil {
+		if err := validator(v); err != nil {
+			return &ErrInvalidValue{Cause: err, Value: v, Flag: mfd.Name}
+		}
+	}
+	return nil
+}
 
-		// By definition, for the same name - all flags have the same "HasValue" value, so it should be safe to just
-		// take it from the first one
-		if mfd.HasValue {
-			stdFs.Func(mfd.Name, "", func(v string) error { return mfd.setValue(v) })
+// normalize invokes the normalizer of the given flag (if any) with the given value, returning the normalized value.
+func (fs *flagSet) normalize(mfd *mergedFlagDef, v string) (string, error) {
+	if normalizer := fs.getNormalizer(mfd.Name); normalizer != nil {
+		if nv, err := normalizer(v); err != nil {
+			return "", &ErrInvalidValue{Cause: err, Value: v, Flag: mfd.Name}
 		} else {
-			stdFs.BoolFunc(mfd.Name, "", func(string) error { return mfd.setValue("true") })
+			return nv, nil
 		}
+	}
+	return v, nil
+}
 
-		// Set the field's default value so it's marked as "applied" (and thus the "required" validation will ignore it)
-		if mfd.DefaultValue != "" {
-			if err := mfd.setValue(mfd.DefaultValue); err != nil {
-				return fmt.Errorf("failed applying default value for flag '%s': %w", mfd.Name, err)
-			}
+// getEnvPrefix returns the prefix of environment variable names derived from flag names, which is taken from the
+// top-most flag set (i.e. closest to the root) that has one.
+func (fs *flagSet) getEnvPrefix() string {
+	var prefix string
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.envPrefix != "" {
+			prefix = cfs.envPrefix
 		}
+	}
+	return prefix
+}
 
-		// Set the value to the flag's corresponding environment variable, if one was given
-		// Important this is done here, so it overrides the default value set earlier
-		if v, found := envVars[*mfd.EnvVarName]; found {
-			if err := mfd.setValue(v); err != nil {
-				return err
-			}
+// getSortMode returns the sort mode of this flag set, which is inherited from its parents unless set explicitly, and
+// defaults to [SortAlphabetical].
+func (fs *flagSet) getSortMode() FlagSortMode {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.sortMode != nil {
+			return *cfs.sortMode
 		}
 	}
+	return SortAlphabetical
+}
 
-	// Parse the given arguments, which will result in all CLI flags being set
-	if err := stdFs.Parse(args); err != nil {
-		re := regexp.MustCompile(`^flag provided but not defined: -(.+)$`)
-		if matches := re.FindStringSubmatch(err.Error()); matches != nil {
-			return &ErrUnknownFlag{Cause: err, Flag: matches[1]}
+// isHidingZeroDefaults returns whether default values equal to the zero values of their flags' types are omitted from
+// help screens, which is inherited from parent flag sets unless set explicitly, and defaults to false.
+func (fs *flagSet) isHidingZeroDefaults() bool {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.hideZeroDefaults != nil {
+			return *cfs.hideZeroDefaults
 		}
-		return err
 	}
+	return false
+}
 
-	// Verify all required flags have been set
-	for _, mfd := range mergedFlagDefs {
-		if mfd.isMissing() {
-			return &ErrRequiredFlagMissing{Cause: err, Flag: mfd.Name}
+// isShowingFlagAliases returns whether flag aliases are listed on help screens, which is inherited from parent flag sets
+// unless set explicitly, and defaults to false.
+func (fs *flagSet) isShowingFlagAliases() bool {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.showFlagAliases != nil {
+			return *cfs.showFlagAliases
 		}
 	}
+	return false
+}
 
-	// Apply positionals
-	positionals := stdFs.Args()
+// getFlagAliases returns the flag aliases registered in this flag set and its parents (see [Command.AliasFlag]), mapped
+// to the names of their canonical flags. Aliases of flags unavailable in this flag set are included as well.
+func (fs *flagSet) getFlagAliases() map[string]string {
+	aliases := make(map[string]string)
 	for cfs := fs; cfs != nil; cfs = cfs.parent {
-		for _, target := range cfs.positionalsTargets {
-			*target = positionals
+		for alias, name := range cfs.flagAliases {
+			if _, ok := aliases[alias]; !ok {
+				aliases[alias] = name
+			}
 		}
 	}
-	return nil
+	return aliases
 }
 
-func (fs *flagSet) printFlagsSingleLine(b io.Writer) error {
+// getVisibleMergedFlagDefs is similar to getMergedFlagDefs, except that hidden flags are excluded.
+func (fs *flagSet) getVisibleMergedFlagDefs() ([]*mergedFlagDef, error) {
+	mergedFlagDefs, err := fs.getMergedFlagDefs()
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(mergedFlagDefs, (*mergedFlagDef).isHidden), nil
+}
+
+// getFlagsByName returns the merged flags of this flag set & its parents, mapped by their names, short names & aliases. Invalid
+// flag definitions are ignored here, as they are reported when the flag set is applied.
+func (fs *flagSet) getFlagsByName() map[string]*mergedFlagDef {
+	mergedFlagDefs, _ := fs.getMergedFlagDefs()
+	return mapFlagsByName(mergedFlagDefs, fs.getFlagAliases())
+}
+
+// isMissingFlagValue returns true if the last of the given CLI flags (along with the values given to them as separate
+// arguments, as split by inferCommandAndArgs) takes a value, yet none was given to it.
+func (fs *flagSet) isMissingFlagValue(flags []string) bool {
+	flagsByName := fs.getFlagsByName()
+	for i := 0; i < len(flags); i++ {
+		if flagArgs, needsValue := expandFlagArg(flagsByName, flags[i]); needsValue {
+			if i+1 >= len(flags) {
+				return true
+			}
+			i++
+		} else if i+1 < len(flags) && consumesBoolValue(flagsByName, flagArgs[len(flagArgs)-1], flags[i+1]) {
+			i++
+		}
+	}
+	return false
+}
+
+// mapFlagsByName maps the given merged flags by their names & short names, the negated names of boolean flags (e.g.
+// "no-verbose"), as well as by the given aliases (mapped to the names of their canonical flags; aliases of flags not given
+// are ignored).
+func mapFlagsByName(mergedFlagDefs []*mergedFlagDef, aliases map[string]string) map[string]*mergedFlagDef {
+	flagsByName := make(map[string]*mergedFlagDef, len(mergedFlagDefs))
+	for _, mfd := range mergedFlagDefs {
+		flagsByName[mfd.Name] = mfd
+		if mfd.Short != nil {
+			flagsByName[*mfd.Short] = mfd
+		}
+	}
+	for _, mfd := range mergedFlagDefs {
+		if negatedName := mfd.getNegatedName(); !mfd.HasValue && !mfd.Count {
+			if _, taken := flagsByName[negatedName]; !taken {
+				flagsByName[negatedName] = mfd
+			}
+		}
+	}
+	for alias, name := range aliases {
+		if _, taken := flagsByName[alias]; !taken {
+			if mfd, ok := flagsByName[name]; ok {
+				flagsByName[alias] = mfd
+			}
+		}
+	}
+	return flagsByName
+}
+
+// expandFlagArg expands the given flag argument, if it is a cluster of single-dash short flags (e.g. "-abc" is expanded
+// to "-a -b -c"), as getopt does. If a short flag in the cluster takes a value, the rest of the cluster is used as its
+// value (e.g. "-vofile" is expanded to "-v -o file"). Arguments that are not such clusters (e.g. "--name", "-n" or
+// "-name" for a flag called "name") are returned as-is.
+//
+// The returned boolean is true if the last flag takes a value that was not given in the argument itself, in which case
+// the next argument is its value (e.g. "--name value" or "-vo file").
+func expandFlagArg(flagsByName map[string]*mergedFlagDef, arg string) ([]string, bool) {
+	name, _, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-"), "=")
+	if mfd, found := flagsByName[name]; found || strings.HasPrefix(arg, "--") || len([]rune(name)) < 2 {
+		return []string{arg}, found && mfd.HasValue && !hasValue
+	}
+
+	runes := []rune(arg[1:])
+	var expanded []string
+	for i, r := range runes {
+		mfd, found := flagsByName[string(r)]
+		if !found {
+			// Not a cluster of known short flags; leave it to the stdlib flag set to report the unknown flag
+			return []string{arg}, false
+		}
+		expanded = append(expanded, "-"+string(r))
+		if mfd.HasValue {
+			if value := string(runes[i+1:]); value != "" {
+				return append(expanded, value), false
+			}
+			return expanded, true
+		}
+	}
+	return expanded, false
+}
+
+// consumesBoolValue returns true if the given (expanded) flag argument is a boolean flag (or its negated name) given
+// without a value, and the given next argument is an explicit "true" or "false" value for it (e.g. "--verbose false" or
+// "--no-verbose false"), which should therefore be consumed as the flag's value rather than be treated as a positional
+// argument. Other boolean literals (e.g. "yes" or "off") are only accepted in the "--flag=value" form, as they are too
+// likely to be positional arguments.
+func consumesBoolValue(flagsByName map[string]*mergedFlagDef, flagArg, next string) bool {
+	if !strings.HasPrefix(flagArg, "-") || (next != "true" && next != "false") {
+		return false
+	}
+	name, _, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(flagArg, "-"), "-"), "=")
+	mfd, found := flagsByName[name]
+	return found && !hasValue && !mfd.HasValue && !mfd.Count
+}
+
+// expandShortFlags expands all clusters of single-dash short flags in the given arguments (see expandFlagArg), stopping
+// at the first non-flag argument (or the "--" separator), just like the stdlib flag set does.
+func expandShortFlags(mergedFlagDefs []*mergedFlagDef, aliases map[string]string, args []string) []string {
+	flagsByName := mapFlagsByName(mergedFlagDefs, aliases)
+	var expanded []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" || arg == "-" || !strings.HasPrefix(arg, "-") {
+			return append(expanded, args[i:]...)
+		}
+		flagArgs, needsValue := expandFlagArg(flagsByName, arg)
+		if needsValue && i+1 < len(args) {
+			i++
+			flagArgs = append(flagArgs, args[i])
+		} else if last := len(flagArgs) - 1; i+1 < len(args) && consumesBoolValue(flagsByName, flagArgs[last], args[i+1]) {
+			// The stdlib flag set only accepts values of boolean flags in the "--flag=value" form
+			i++
+			flagArgs[last] += "=" + args[i]
+		}
+		expanded = append(expanded, flagArgs...)
+	}
+	return expanded
+}
+
+// valueResolver resolves values given to flags (see mergedFlagDef.resolveValue), reading "-" values of stdin flags from
+// stdin (which can only be done once per invocation).
+type valueResolver struct {
+	stdin         io.Reader
+	stdinFlagName string
+}
+
+func (r *valueResolver) resolve(mfd *mergedFlagDef, v string) (string, error) {
+	if !mfd.Stdin || v != "-" {
+		return mfd.resolveValue(v)
+	} else if r.stdinFlagName != "" {
+		return "", fmt.Errorf("flag '%s' cannot read from stdin, since it was already read by flag '%s'", mfd.Name, r.stdinFlagName)
+	}
+	r.stdinFlagName = mfd.Name
+	stdin := r.stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	if data, err := io.ReadAll(stdin); err != nil {
+		return "", fmt.Errorf("failed reading value of flag '%s' from stdin: %w", mfd.Name, err)
+	} else {
+		return string(data), nil
+	}
+}
+
+// applyDefaultValues sets the default values of the given flags, so they're marked as "applied" (and thus the "required"
+// validation will ignore them).
+func (fs *flagSet) applyDefaultValues(mergedFlagDefs []*mergedFlagDef) error {
+	for _, mfd := range mergedFlagDefs {
+		if mfd.DefaultValue != "" {
+			if err := mfd.setDefaultValue(); err != nil {
+				return fmt.Errorf("failed applying default value for flag '%s': %w", mfd.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyConfigFile sets the values of the given flags from the configuration file, if one was given (see readConfigFile).
+// Multiple values given to the same flag are appended to each other.
+func (fs *flagSet) applyConfigFile(mergedFlagDefs []*mergedFlagDef, envVars map[string]string, args []string, resolver *valueResolver) error {
+	configValues, err := fs.readConfigFile(mergedFlagDefs, envVars, args)
+	if err != nil {
+		return err
+	}
+	for _, mfd := range mergedFlagDefs {
+		for i, v := range configValues[mfd.Name] {
+			if v, err = resolver.resolve(mfd, v); err != nil {
+				return err
+			} else if i == 0 {
+				err = mfd.setValue(v)
+			} else {
+				err = mfd.appendValue(v)
+			}
+			if err != nil {
+				return err
+			}
+			mfd.setByConfig = true
+		}
+	}
+	return nil
+}
+
+// applyEnvVars sets the values of the given flags from their corresponding environment variables, if given. Flags not
+// bound to an environment variable (tagged with `env:"-"`) are skipped.
+func (fs *flagSet) applyEnvVars(mergedFlagDefs []*mergedFlagDef, envVars map[string]string, resolver *valueResolver) error {
+	for _, mfd := range mergedFlagDefs {
+		if mfd.EnvVarName != nil {
+			if v, found := mfd.lookupEnvVar(envVars); found {
+				if v, err := resolver.resolve(mfd, v); err != nil {
+					return err
+				} else if v, err := fs.normalize(mfd, v); err != nil {
+					return err
+				} else if err := mfd.setValue(v); err != nil {
+					return err
+				}
+				mfd.setByEnv = true
+			}
+		}
+		if mfd.EnvPrefix != nil {
+			var names []string
+			for name := range envVars {
+				if key, found := strings.CutPrefix(name, *mfd.EnvPrefix); found && key != "" {
+					names = append(names, name)
+				}
+			}
+			slices.Sort(names)
+			for _, name := range names {
+				if err := mfd.setMapEntry(strings.TrimPrefix(name, *mfd.EnvPrefix), envVars[name]); err != nil {
+					return err
+				}
+				mfd.setByEnv = true
+			}
+		}
+	}
+	return nil
+}
+
+// applyCLIArgs parses the given CLI arguments using a stdlib FlagSet, setting the values of the given flags & marking
+// them as set by the user. The positional arguments (i.e. the arguments remaining after the flags) are returned.
+func (fs *flagSet) applyCLIArgs(mergedFlagDefs []*mergedFlagDef, args []string, resolver *valueResolver) ([]string, error) {
+	stdFs := flag.NewFlagSet("", flag.ContinueOnError)
+	stdFs.SetOutput(io.Discard)
+
+	// Iterate flags and define them in the stdlib FlagSet
+	for _, mfd := range mergedFlagDefs {
+
+		// By definition, for the same name - all flags have the same "HasValue" value, so it should be safe to just
+		// take it from the first one
+		if mfd.HasValue {
+			// Repeated CLI occurrences accumulate into slice targets; only the first occurrence replaces the value
+			// applied from the default value, configuration file or environment variable
+			stdFs.Func(mfd.Name, "", func(v string) error {
+				v, err := resolver.resolve(mfd, v)
+				if err != nil {
+					return err
+				} else if v, err = fs.normalize(mfd, v); err != nil {
+					return err
+				}
+				if mfd.setByUser {
+					err = mfd.appendValue(v)
+				} else {
+					mfd.setByUser = true
+					err = mfd.setValue(v)
+				}
+				if err != nil {
+					return err
+				}
+				return fs.validate(mfd, v)
+			})
+		} else if mfd.Count {
+			stdFs.BoolFunc(mfd.Name, "", func(string) error {
+				mfd.setByUser = true
+				return mfd.increment()
+			})
+		} else {
+			// Boolean flags may be given an explicit value (e.g. "--verbose=false", or "--verbose false" - see
+			// consumesBoolValue); otherwise the stdlib flag set gives them "true"
+			stdFs.BoolFunc(mfd.Name, "", func(v string) error {
+				v, err := fs.normalize(mfd, v)
+				if err != nil {
+					return err
+				}
+				b, err := parseBool(v)
+				if err != nil {
+					return &ErrInvalidValue{Cause: strconv.ErrSyntax, Value: v, Flag: mfd.Name}
+				}
+				mfd.setByUser = true
+				v = strconv.FormatBool(b)
+				if err := mfd.setValue(v); err != nil {
+					return err
+				}
+				return fs.validate(mfd, v)
+			})
+		}
+	}
+
+	// Register short aliases & negated forms of boolean flags, now that all long flag names have been defined
+	for _, mfd := range mergedFlagDefs {
+		if mfd.Short != nil {
+			if f := stdFs.Lookup(*mfd.Short); f != nil {
+				return nil, fmt.Errorf("short name '%s' of flag '%s' conflicts with another flag", *mfd.Short, mfd.Name)
+			}
+			stdFs.Var(stdFs.Lookup(mfd.Name).Value, *mfd.Short, "")
+		}
+		if !mfd.HasValue && !mfd.Count {
+			// Explicitly defined flags take precedence over negated forms of boolean flags
+			if negatedName := mfd.getNegatedName(); stdFs.Lookup(negatedName) == nil {
+				stdFs.BoolFunc(negatedName, "", func(v string) error {
+					v, err := fs.normalize(mfd, v)
+					if err != nil {
+						return err
+					}
+					b, err := parseBool(v)
+					if err != nil {
+						return &ErrInvalidValue{Cause: strconv.ErrSyntax, Value: v, Flag: mfd.Name}
+					}
+					mfd.setByUser = true
+					v = strconv.FormatBool(!b)
+					if err := mfd.setValue(v); err != nil {
+						return err
+					}
+					return fs.validate(mfd, v)
+				})
+			}
+		}
+	}
+
+	// Register flag aliases, sharing the values of their canonical flags (aliases of flags unavailable here are ignored)
+	aliases := fs.getFlagAliases()
+	aliasNames := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		aliasNames = append(aliasNames, alias)
+	}
+	slices.Sort(aliasNames)
+	for _, alias := range aliasNames {
+		if f := stdFs.Lookup(aliases[alias]); f != nil {
+			if stdFs.Lookup(alias) != nil {
+				return nil, fmt.Errorf("alias '%s' of flag '%s' conflicts with another flag", alias, aliases[alias])
+			}
+			stdFs.Var(f.Value, alias, "")
+		}
+	}
+
+	// Parse the given arguments, which will result in all CLI flags being set
+	if err := stdFs.Parse(args); err != nil {
+		re := regexp.MustCompile(`^flag provided but not defined: -(.+)$`)
+		if matches := re.FindStringSubmatch(err.Error()); matches != nil {
+			return nil, &ErrUnknownFlag{Cause: err, Flag: matches[1], Suggestion: suggestFlagName(mergedFlagDefs, matches[1])}
+		} else if errors.Is(err, flag.ErrHelp) {
+			// The standard library treats undefined "-h" & "-help" flags specially, e.g. when the built-in help flag is
+			// renamed (see [Command.SetHelpFlag])
+			name := helpFlagName
+			for _, arg := range args {
+				if arg == "--" {
+					break
+				} else if n, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); strings.HasPrefix(arg, "-") && (n == "h" || n == "help") {
+					name = n
+					break
+				}
+			}
+			return nil, &ErrUnknownFlag{Cause: err, Flag: name, Suggestion: suggestFlagName(mergedFlagDefs, name)}
+		}
+		return nil, err
+	}
+	return stdFs.Args(), nil
+}
+
+// isPromptingForMissingFlags returns whether this flag set, or any of its parents, prompts for missing required flags.
+func (fs *flagSet) isPromptingForMissingFlags() bool {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.promptMissing {
+			return true
+		}
+	}
+	return false
+}
+
+// applyPrompts prompts the user for the values of the given required flags that are still missing, if prompting is
+// enabled (see [Command.SetPromptForMissingFlags]) and stdin is an interactive terminal. Prompts are written to the
+// given writer, and values of secret flags are not echoed back. Prompted values are considered as given by the user.
+// Flags left empty by the user remain missing.
+func (fs *flagSet) applyPrompts(w io.Writer, mergedFlagDefs []*mergedFlagDef) error {
+	if !fs.isPromptingForMissingFlags() {
+		return nil
+	}
+
+	stdin := fs.stdin
+	if stdin == nil {
+		stdin = os.Stdin
+	}
+	f, isFile := stdin.(*os.File)
+	if fs.interactive != nil {
+		if !*fs.interactive {
+			return nil
+		}
+	} else if !isFile || !isTerminal(f) {
+		return nil
+	}
+
+	for _, mfd := range mergedFlagDefs {
+		if !mfd.isMissing() {
+			continue
+		}
+
+		_, _ = fmt.Fprintf(w, "%s: ", defaultIfNil(mfd.Prompt, fmt.Sprintf("Enter a value for --%s", mfd.Name)))
+		var v string
+		var err error
+		if mfd.Secret && isFile {
+			v, err = readSecretLine(f)
+			_, _ = fmt.Fprintln(w)
+		} else {
+			v, err = readLine(stdin)
+		}
+		if errors.Is(err, io.EOF) || (err == nil && v == "") {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed reading value of flag '%s': %w", mfd.Name, err)
+		}
+
+		if v, err = mfd.resolveValue(v); err != nil {
+			return err
+		} else if v, err = fs.normalize(mfd, v); err != nil {
+			return err
+		} else if err := mfd.setValue(v); err != nil {
+			return err
+		}
+		mfd.setByUser = true
+		if err := fs.validate(mfd, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (fs *flagSet) apply(w io.Writer, envVars map[string]string, args []string) error {
+	if args == nil {
+		args = []string{}
+	}
+	if envVars == nil {
+		envVars = make(map[string]string)
+	}
 
 	// Merge flags from this flag set and its parents
 	mergedFlagDefs, err := fs.getMergedFlagDefs()
@@ -432,6 +1454,245 @@ func (fs *flagSet) printFlagsSingleLine(b io.Writer) error {
 		return err
 	}
 
+	// Expand clusters of short flags (e.g. "-abc") into separate flags
+	args = expandShortFlags(mergedFlagDefs, fs.getFlagAliases(), args)
+
+	// Apply values from all sources, in order of increasing precedence: default values, configuration file values,
+	// environment variables & CLI arguments; each layer overrides the values applied by the layers before it, and only
+	// the last one marks flags as set by the user
+	resolver := &valueResolver{stdin: fs.stdin}
+	if err := fs.applyDefaultValues(mergedFlagDefs); err != nil {
+		return err
+	} else if err := fs.applyConfigFile(mergedFlagDefs, envVars, args, resolver); err != nil {
+		return err
+	} else if err := fs.applyEnvVars(mergedFlagDefs, envVars, resolver); err != nil {
+		return err
+	}
+	positionals, err := fs.applyCLIArgs(mergedFlagDefs, args, resolver)
+	if err != nil {
+		return err
+	}
+
+	// Prompt for the values of required flags that are still missing, if enabled
+	if err := fs.applyPrompts(w, mergedFlagDefs); err != nil {
+		return err
+	}
+
+	// Record the source of each flag's final value
+	fs.valueSources = make(map[string]string, len(mergedFlagDefs))
+	for _, mfd := range mergedFlagDefs {
+		fs.valueSources[mfd.Name] = mfd.getValueSource()
+	}
+
+	// Warn about deprecated flags given in the command line
+	for _, mfd := range mergedFlagDefs {
+		if mfd.setByUser && mfd.Deprecated != nil {
+			_, _ = fmt.Fprintf(w, "flag --%s is deprecated: %s\n", mfd.Name, *mfd.Deprecated)
+		}
+	}
+
+	// Verify no more than one flag of each mutually exclusive flags group has been set
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		for _, group := range cfs.exclusiveFlagGroups {
+			var setFlags []string
+			for _, mfd := range mergedFlagDefs {
+				if mfd.setByUser && slices.Contains(group, mfd.Name) {
+					setFlags = append(setFlags, mfd.Name)
+				}
+			}
+			if len(setFlags) > 1 {
+				return &ErrMutuallyExclusiveFlags{Flags: setFlags}
+			}
+		}
+	}
+
+	// Verify all required flags have been set
+	for _, mfd := range mergedFlagDefs {
+		if mfd.isMissing() {
+			return &ErrRequiredFlagMissing{Cause: err, Flag: mfd.Name}
+		}
+	}
+
+	// Verify at least one flag of each one-required flags group has been set (checked after individually required
+	// flags, and in order of declaration, starting at this flag set, for deterministic error reporting)
+	// Groups whose flags are not all available (e.g. not inherited from a parent flag set) are ignored
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		for _, group := range cfs.oneRequiredFlagGroups {
+			available, set := 0, false
+			for _, mfd := range mergedFlagDefs {
+				if slices.Contains(group, mfd.Name) {
+					available++
+					set = set || mfd.setByUser
+				}
+			}
+			if available == len(group) && !set {
+				return &ErrRequiredFlagGroupMissing{Flags: group}
+			}
+		}
+	}
+
+	// Verify the number of positionals matches the positionals specification, if one was set, and apply them
+	if fs.positionalsSpec != nil {
+		if err := fs.positionalsSpec.verify(positionals); err != nil {
+			return err
+		}
+	}
+	for i, target := range fs.positionalTargets {
+		if i >= len(positionals) {
+			break
+		} else if err := target.setValue(positionals[i]); err != nil {
+			var ive *ErrInvalidValue
+			if errors.As(err, &ive) {
+				err = ive.Cause
+			}
+			return &ErrInvalidPositional{Cause: err, Position: i + 1, Name: target.Name, Value: positionals[i]}
+		}
+	}
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		for _, target := range cfs.positionalsTargets {
+			*target = positionals
+		}
+		for _, target := range cfs.typedPositionalsTargets {
+			if err := setTypedPositionals(target, positionals); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// setTypedPositionals sets the given slice target to the given positional arguments, each parsed by the
+// "encoding.TextUnmarshaler" implementation of the slice's element type.
+func setTypedPositionals(target reflect.Value, positionals []string) error {
+	values := reflect.MakeSlice(target.Type(), len(positionals), len(positionals))
+	for i, positional := range positionals {
+		u := values.Index(i).Addr().Interface().(encoding.TextUnmarshaler)
+		if err := u.UnmarshalText([]byte(positional)); err != nil {
+			return &ErrInvalidPositional{Cause: err, Position: i + 1, Name: "ARGS", Value: positional}
+		}
+	}
+	target.Set(values)
+	return nil
+}
+
+// suggestFlagName returns the name of the given flag most similar to the given unknown flag name, or an empty string
+// if none is similar enough.
+func suggestFlagName(mergedFlagDefs []*mergedFlagDef, name string) string {
+	var names []string
+	for _, mfd := range mergedFlagDefs {
+		names = append(names, mfd.Name)
+	}
+	return findClosest(name, names, 2)
+}
+
+// getHelpFlagName returns the name of the built-in help flag, which may be customized by the root command (see
+// [Command.SetHelpFlag]), or an empty string if it was disabled (see [Command.DisableAutoHelp]).
+func (fs *flagSet) getHelpFlagName() string {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.noHelpFlag {
+			return ""
+		} else if cfs.helpFlagName != "" {
+			return cfs.helpFlagName
+		}
+	}
+	return helpFlagName
+}
+
+// getConfigFileFlagName returns the name of the flag pointing to the configuration file, or an empty string if
+// configuration files are not enabled for this flag set (or any of its parents).
+func (fs *flagSet) getConfigFileFlagName() string {
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if cfs.configFileFlagName != "" {
+			return cfs.configFileFlagName
+		}
+	}
+	return ""
+}
+
+// readConfigFile reads the configuration file specified in the given CLI arguments (or, if missing there, in the given
+// environment variables), and returns its values mapped by flag name. Since the configuration file must be loaded before
+// environment variables & CLI arguments are applied, its flag is located by scanning the arguments directly.
+func (fs *flagSet) readConfigFile(mergedFlagDefs []*mergedFlagDef, envVars map[string]string, args []string) (map[string][]string, error) {
+	flagName := fs.getConfigFileFlagName()
+	if flagName == "" {
+		return nil, nil
+	}
+
+	// Find the configuration file path
+	var path string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		} else if arg == "-"+flagName || arg == "--"+flagName {
+			if i+1 < len(args) {
+				path = args[i+1]
+			}
+			i++
+		} else if v, found := strings.CutPrefix(arg, "-"+flagName+"="); found {
+			path = v
+		} else if v, found := strings.CutPrefix(arg, "--"+flagName+"="); found {
+			path = v
+		}
+	}
+	knownFlags := make(map[string]bool)
+	for _, mfd := range mergedFlagDefs {
+		knownFlags[mfd.Name] = true
+		if path == "" && mfd.Name == flagName && mfd.EnvVarName != nil {
+			path, _ = mfd.lookupEnvVar(envVars)
+		}
+	}
+	if path == "" {
+		return nil, nil
+	}
+
+	// Read & decode the configuration file, using the decoder registered for its extension
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading config file: %w", err)
+	}
+	defer f.Close()
+	values, err := fs.getConfigDecoder(filepath.Ext(path)).Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing config file '%s': %w", path, err)
+	}
+	for name := range values {
+		if !knownFlags[name] {
+			return nil, fmt.Errorf("invalid config file '%s': %w", path, &ErrUnknownFlag{Flag: name, Suggestion: suggestFlagName(mergedFlagDefs, name)})
+		}
+	}
+	return values, nil
+}
+
+// getConfigDecoder returns the configuration file decoder registered for the given file extension in the top-most flag
+// set (i.e. closest to the root) that has one, falling back to the decoder registered for the empty extension, and then
+// to [JSONConfigDecoder].
+func (fs *flagSet) getConfigDecoder(ext string) ConfigDecoder {
+	var decoder, fallback ConfigDecoder
+	for cfs := fs; cfs != nil; cfs = cfs.parent {
+		if d, ok := cfs.configDecoders[normalizeConfigFileExt(ext)]; ok {
+			decoder = d
+		}
+		if d, ok := cfs.configDecoders[""]; ok {
+			fallback = d
+		}
+	}
+	if decoder != nil {
+		return decoder
+	} else if fallback != nil {
+		return fallback
+	}
+	return JSONConfigDecoder
+}
+
+func (fs *flagSet) printFlagsSingleLine(b io.Writer) error {
+
+	// Merge flags from this flag set and its parents, excluding hidden flags
+	mergedFlagDefs, err := fs.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
 	space := false
 	for _, fd := range mergedFlagDefs {
 		if space {
@@ -443,6 +1704,9 @@ func (fs *flagSet) printFlagsSingleLine(b io.Writer) error {
 			_, _ = fmt.Fprint(b, "[")
 		}
 
+		if fd.Short != nil {
+			_, _ = fmt.Fprintf(b, "-%s, ", *fd.Short)
+		}
 		valueName := fd.getValueName()
 		if valueName != "" {
 			_, _ = fmt.Fprintf(b, "--%s=%s", fd.Name, valueName)
@@ -453,20 +1717,66 @@ func (fs *flagSet) printFlagsSingleLine(b io.Writer) error {
 			_, _ = fmt.Fprint(b, "]")
 		}
 	}
-	if len(fs.positionalsTargets) > 0 {
+	if fs.positionalsSpec != nil && len(fs.positionalsSpec.names) > 0 {
+		if space {
+			_, _ = fmt.Fprint(b, " ")
+		}
+		_, _ = fmt.Fprint(b, fs.positionalsSpec.usage())
+	} else {
+		for _, target := range fs.positionalTargets {
+			if space {
+				_, _ = fmt.Fprint(b, " ")
+			}
+			_, _ = fmt.Fprintf(b, "[%s]", target.Name)
+			space = true
+		}
+		if len(fs.positionalsTargets) > 0 || len(fs.typedPositionalsTargets) > 0 {
+			if space {
+				_, _ = fmt.Fprint(b, " ")
+			}
+			_, _ = fmt.Fprint(b, "[ARGS...]")
+		}
+	}
+	if len(fs.rawArgsTargets) > 0 {
 		if space {
 			_, _ = fmt.Fprint(b, " ")
 		}
-		_, _ = fmt.Fprint(b, "[ARGS...]")
+		_, _ = fmt.Fprint(b, "[-- RAW_ARGS...]")
 	}
 
 	return nil
 }
 
-func (fs *flagSet) printFlagsMultiLine(ww *WrappingWriter, basePrefix string) error {
+// flagsColGutter is the number of spaces separating flag names from their descriptions in help screens.
+const flagsColGutter = 2
 
-	// Merge flags from this flag set and its parents
-	mergedFlagDefs, err := fs.getMergedFlagDefs()
+// getFlagGroups returns the names of the groups of the visible flags in this flag set & its parents, in the order they
+// first appear in. Flags without a group belong to the "" group, which is always first (if there are any such flags).
+func (fs *flagSet) getFlagGroups() ([]string, error) {
+	mergedFlagDefs, err := fs.getVisibleMergedFlagDefs()
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, fd := range mergedFlagDefs {
+		if group := defaultIfNil(fd.Group, ""); !slices.Contains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	if i := slices.Index(groups, ""); i > 0 {
+		groups = slices.Insert(slices.Delete(groups, i, i+1), 0, "")
+	}
+	return groups, nil
+}
+
+// printFlagsMultiLine prints the visible flags of the given group (or flags without a group, if the given group is
+// empty). Note that the description column is computed from all visible flags, regardless of their group, so that
+// descriptions are aligned across all groups of the help screen.
+func (fs *flagSet) printFlagsMultiLine(ww *WrappingWriter, basePrefix, group string, color bool) error {
+
+	// Merge flags from this flag set and its parents, excluding hidden flags
+	mergedFlagDefs, err := fs.getVisibleMergedFlagDefs()
 	if err != nil {
 		return err
 	}
@@ -481,6 +1791,9 @@ func (fs *flagSet) printFlagsMultiLine(ww *WrappingWriter, basePrefix string) er
 		} else {
 			fullFlagName = fmt.Sprintf("--%s", fd.Name)
 		}
+		if fd.Short != nil {
+			fullFlagName = fmt.Sprintf("-%s, %s", *fd.Short, fullFlagName)
+		}
 		if fd.Required == nil || !*fd.Required {
 			fullFlagName = "[" + fullFlagName + "]"
 		}
@@ -490,12 +1803,37 @@ func (fs *flagSet) printFlagsMultiLine(ww *WrappingWriter, basePrefix string) er
 		}
 	}
 
-	descriptionStartColumn := flagsColWidth + (10 - flagsColWidth%10)
+	// Descriptions start right after the longest flag name (plus a gutter), but are always left with at least half of the
+	// available width; descriptions of flags whose names are too long for that start on the following line
+	descriptionStartColumn := flagsColWidth + flagsColGutter
+	if maxDescriptionStartColumn := (ww.width - len(basePrefix)) / 2; descriptionStartColumn > maxDescriptionStartColumn {
+		descriptionStartColumn = maxDescriptionStartColumn
+	}
+	hideZeroDefaults := fs.isHidingZeroDefaults()
+	flagAliases := make(map[string][]string)
+	if fs.isShowingFlagAliases() {
+		for alias, name := range fs.getFlagAliases() {
+			flagAliases[name] = append(flagAliases[name], "--"+alias)
+		}
+	}
 	for _, fd := range mergedFlagDefs {
+		if defaultIfNil(fd.Group, "") != group {
+			continue
+		}
+
+		// Flag names are bold when colorized, and required flags are highlighted as well
 		flagName := fullFlagNames[fd.Name]
-		_, _ = fmt.Fprint(ww, flagName)
-		_, _ = fmt.Fprint(ww, strings.Repeat(" ", descriptionStartColumn-len(flagName)))
-		_ = ww.SetLinePrefix(basePrefix + strings.Repeat(" ", descriptionStartColumn))
+		_ = ww.SetLinePrefixes(basePrefix, basePrefix+strings.Repeat(" ", descriptionStartColumn))
+		if fd.isRequired() {
+			_, _ = fmt.Fprint(ww, colorize(flagName, ansiBoldYellow, color))
+		} else {
+			_, _ = fmt.Fprint(ww, colorize(flagName, ansiBold, color))
+		}
+		if len(flagName)+flagsColGutter > descriptionStartColumn {
+			_, _ = fmt.Fprintln(ww)
+		} else {
+			_, _ = fmt.Fprint(ww, strings.Repeat(" ", descriptionStartColumn-len(flagName)))
+		}
 
 		// Build flag description
 		hasDescription := fd.Description != nil && *fd.Description != ""
@@ -505,7 +1843,7 @@ func (fs *flagSet) printFlagsMultiLine(ww *WrappingWriter, basePrefix string) er
 			sep = " ("
 		}
 
-		if fd.DefaultValue != "" {
+		if fd.DefaultValue != "" && !(hideZeroDefaults && fd.hasZeroDefault()) {
 			if sep != "" {
 				_, _ = fmt.Fprint(ww, sep)
 			}
@@ -516,11 +1854,42 @@ func (fs *flagSet) printFlagsMultiLine(ww *WrappingWriter, basePrefix string) er
 			if sep != "" {
 				_, _ = fmt.Fprint(ww, sep)
 			}
-			_, _ = fmt.Fprintf(ww, "environment variable: %s", *fd.EnvVarName)
+			if len(fd.EnvFallbacks) > 0 {
+				_, _ = fmt.Fprintf(ww, "environment variables: %s", strings.Join(fd.getEnvVarNames(), ", "))
+			} else {
+				_, _ = fmt.Fprintf(ww, "environment variable: %s", *fd.EnvVarName)
+			}
+			sep = ", "
 		}
-		if hasDescription {
+		if fd.EnvPrefix != nil {
+			if sep != "" {
+				_, _ = fmt.Fprint(ww, sep)
+			}
+			_, _ = fmt.Fprintf(ww, "environment variables: %s*", *fd.EnvPrefix)
+			sep = ", "
+		}
+		if hasDescription && sep == ", " {
 			_, _ = fmt.Fprint(ww, ")")
 		}
+		if len(fd.Choices) > 0 {
+			_, _ = fmt.Fprintf(ww, " (one of: %s)", strings.Join(fd.Choices, ", "))
+		}
+		if fd.Count {
+			_, _ = fmt.Fprint(ww, " (repeatable)")
+		}
+		if fd.Stdin {
+			_, _ = fmt.Fprint(ww, " (use - to read from stdin)")
+		}
+		if aliases := flagAliases[fd.Name]; len(aliases) > 0 {
+			slices.Sort(aliases)
+			_, _ = fmt.Fprintf(ww, " (aliases: %s)", strings.Join(aliases, ", "))
+		}
+		if fd.Deprecated != nil {
+			_, _ = fmt.Fprintf(ww, " (DEPRECATED: %s)", *fd.Deprecated)
+		}
+		if !fd.HasValue && fd.DefaultValue == "true" {
+			_, _ = fmt.Fprintf(ww, " (negate with --%s)", fd.getNegatedName())
+		}
 
 		_ = ww.SetLinePrefix(basePrefix)
 		_, _ = fmt.Fprintln(ww)
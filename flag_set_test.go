@@ -3,8 +3,17 @@ package command
 import (
 	"bytes"
 	stdcmp "cmp"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/arikkfir/justest"
 	"github.com/google/go-cmp/cmp"
@@ -131,6 +140,32 @@ func TestNewFlagSet(t *testing.T) {
 				}
 			},
 		},
+		"field with multi-character 'short' tag is rejected": {
+			config: &struct {
+				MyField string `short:"ab"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "short:\\"ab\\"" \}.MyField': invalid tag 'short=ab': must be a single character$`,
+		},
+		"value of 'short' tag is used": {
+			config: &struct {
+				MyField bool `short:"v"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", Short: ptrOf("v"), DefaultValue: "false"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"redefining 'short' tag is rejected": {
+			config: &struct {
+				F1 bool `name:"my-field" short:"a"`
+				F2 bool `name:"my-field" short:"b"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 bool "name:\\"my-field\\" short:\\"a\\""; F2 bool "name:\\"my-field\\" short:\\"b\\"" \}.F2': invalid tag 'short=b': cannot redefine short name$`,
+		},
 		"field with empty 'env' tag is rejected": {
 			config: &struct {
 				MyField string `env:""`
@@ -150,6 +185,38 @@ func TestNewFlagSet(t *testing.T) {
 				}
 			},
 		},
+		"value of 'env' tag with fallback names is split and uppercased": {
+			config: &struct {
+				MyField string `env:"a,b_old,c"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", EnvVarName: ptrOf("A"), EnvFallbacks: []string{"B_OLD", "C"}, HasValue: true},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"field with empty name in 'env' tag is rejected": {
+			config: &struct {
+				MyField string `env:"A,,B"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "env:\\"A,,B\\"" \}.MyField': invalid tag 'env=A,,B': must not contain empty names$`,
+		},
+		"field with '-' combined with other names in 'env' tag is rejected": {
+			config: &struct {
+				MyField string `env:"A,-"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "env:\\"A,-\\"" \}.MyField': invalid tag 'env=A,-': '-' cannot be combined with other names$`,
+		},
+		"redefining fallback names in 'env' tag is rejected": {
+			config: &struct {
+				F1 string `name:"my-field" env:"A,B"`
+				F2 string `name:"my-field" env:"A,C"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" env:\\"A,B\\""; F2 string "name:\\"my-field\\" env:\\"A,C\\"" \}.F2': invalid tag 'env=C': cannot redefine fallback environment variable names$`,
+		},
 		"field with empty 'value-name' tag is rejected": {
 			config: &struct {
 				MyField string `value-name:""`
@@ -195,6 +262,260 @@ func TestNewFlagSet(t *testing.T) {
 				}
 			},
 		},
+		"field with empty choice in 'choices' tag is rejected": {
+			config: &struct {
+				MyField string `choices:"a,,b"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "choices:\\"a,,b\\"" \}.MyField': invalid tag 'choices=a,,b': must not contain empty choices$`,
+		},
+		"value of 'choices' tag is used": {
+			config: &struct {
+				MyField string `choices:"json, yaml,text"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, Choices: []string{"json", "yaml", "text"}},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"redefining 'choices' tag is rejected": {
+			config: &struct {
+				F1 string `name:"my-field" choices:"a,b"`
+				F2 string `name:"my-field" choices:"a,c"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" choices:\\"a,b\\""; F2 string "name:\\"my-field\\" choices:\\"a,c\\"" \}.F2': invalid tag 'choices=a,c': cannot redefine choices$`,
+		},
+		"redefining 'hidden' tag is rejected": {
+			config: &struct {
+				F1 string `name:"my-field" hidden:"true"`
+				F2 string `name:"my-field" hidden:"false"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" hidden:\\"true\\""; F2 string "name:\\"my-field\\" hidden:\\"false\\"" \}.F2': invalid tag 'hidden=false': cannot redefine hidden status$`,
+		},
+		"field with 'deprecated' tag": {
+			config: &struct {
+				MyField string `deprecated:"use --other instead"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, Deprecated: ptrOf("use --other instead")},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"field with empty 'deprecated' tag is rejected": {
+			config: &struct {
+				MyField string `deprecated:""`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "deprecated:\\"\\"" \}.MyField': invalid tag 'deprecated=': must not be empty$`,
+		},
+		"redefining 'deprecated' tag is rejected": {
+			config: &struct {
+				F1 string `name:"my-field" deprecated:"a"`
+				F2 string `name:"my-field" deprecated:"b"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" deprecated:\\"a\\""; F2 string "name:\\"my-field\\" deprecated:\\"b\\"" \}.F2': invalid tag 'deprecated=b': cannot redefine deprecation message$`,
+		},
+		"field with 'group' tag": {
+			config: &struct {
+				MyField string `group:"Networking"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, Group: ptrOf("Networking")},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"field with empty 'group' tag is rejected": {
+			config: &struct {
+				MyField string `group:""`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "group:\\"\\"" \}.MyField': invalid tag 'group=': must not be empty$`,
+		},
+		"redefining 'group' tag is rejected": {
+			config: &struct {
+				F1 string `name:"my-field" group:"a"`
+				F2 string `name:"my-field" group:"b"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" group:\\"a\\""; F2 string "name:\\"my-field\\" group:\\"b\\"" \}.F2': invalid tag 'group=b': cannot redefine group$`,
+		},
+		"field with 'pattern' tag of non-string type is rejected": {
+			config: &struct {
+				MyField int `pattern:"^[0-9]+$"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField int "pattern:\\"\^\[0-9\]\+\$\\"" \}.MyField': invalid tag 'pattern=\^\[0-9\]\+\$': only supported for string & string slice fields$`,
+		},
+		"field with invalid 'pattern' tag is rejected": {
+			config: &struct {
+				MyField string `pattern:"[a-z"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "pattern:\\"\[a-z\\"" \}.MyField': invalid tag 'pattern=\[a-z': error parsing regexp: missing closing \]: .*$`,
+		},
+		"redefining 'pattern' tag is rejected": {
+			config: &struct {
+				F1 string `name:"my-field" pattern:"a"`
+				F2 string `name:"my-field" pattern:"b"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" pattern:\\"a\\""; F2 string "name:\\"my-field\\" pattern:\\"b\\"" \}.F2': invalid tag 'pattern=b': cannot redefine pattern$`,
+		},
+		"field with 'min' tag of non-numeric type is rejected": {
+			config: &struct {
+				MyField string `min:"1"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "min:\\"1\\"" \}.MyField': invalid tag 'min=1': only supported for numeric fields$`,
+		},
+		"field with non-numeric 'max' tag is rejected": {
+			config: &struct {
+				MyField int `max:"abc"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField int "max:\\"abc\\"" \}.MyField': invalid tag 'max=abc': invalid syntax$`,
+		},
+		"field with negative 'min' tag of unsigned type is rejected": {
+			config: &struct {
+				MyField uint `min:"-1"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField uint "min:\\"-1\\"" \}.MyField': invalid tag 'min=-1': invalid syntax$`,
+		},
+		"field with 'max' tag lower than 'min' tag is rejected": {
+			config: &struct {
+				MyField int `min:"10" max:"1"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField int "min:\\"10\\" max:\\"1\\"" \}.MyField': invalid tag 'max=1': must not be lower than minimum 10$`,
+		},
+		"redefining 'min' tag is rejected": {
+			config: &struct {
+				F1 int `name:"my-field" min:"1"`
+				F2 int `name:"my-field" min:"2"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 int "name:\\"my-field\\" min:\\"1\\""; F2 int "name:\\"my-field\\" min:\\"2\\"" \}.F2': invalid tag 'min=2': cannot redefine minimum$`,
+		},
+		"field with 'encoding' tag of non-byte-slice type is rejected": {
+			config: &struct {
+				MyField string `encoding:"hex"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "encoding:\\"hex\\"" \}.MyField': invalid tag 'encoding=hex': only supported for \[\]byte fields$`,
+		},
+		"field with unknown 'encoding' tag is rejected": {
+			config: &struct {
+				MyField []byte `encoding:"base32"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField \[\]uint8 "encoding:\\"base32\\"" \}.MyField': invalid tag 'encoding=base32': must be one of: base64, hex, raw$`,
+		},
+		"redefining 'encoding' tag is rejected": {
+			config: &struct {
+				F1 []byte `name:"my-field" encoding:"hex"`
+				F2 []byte `name:"my-field" encoding:"base64"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 \[\]uint8 "name:\\"my-field\\" encoding:\\"hex\\""; F2 \[\]uint8 "name:\\"my-field\\" encoding:\\"base64\\"" \}.F2': invalid tag 'encoding=base64': cannot redefine encoding$`,
+		},
+		"field with 'bytesize' tag": {
+			config: &struct {
+				MyField int64 `bytesize:"true"`
+			}{MyField: 10 * 1024 * 1024},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, ByteSize: true, DefaultValue: "10MB"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"field with 'bytesize' tag and zero value": {
+			config: &struct {
+				MyField uint64 `bytesize:"true"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, ByteSize: true, DefaultValue: "0"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"field with 'prompt' & 'secret' tags": {
+			config: &struct {
+				MyField string `prompt:"Enter your API token" secret:"true"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, Prompt: ptrOf("Enter your API token"), Secret: true},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"field with 'envprefix' tag of non-map type is rejected": {
+			config: &struct {
+				MyField string `envprefix:"MYAPP_"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "envprefix:\\"MYAPP_\\"" \}.MyField': invalid tag 'envprefix=MYAPP_': only supported for map\[string\]string fields$`,
+		},
+		"field with empty 'envprefix' tag is rejected": {
+			config: &struct {
+				MyField map[string]string `envprefix:""`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField map\[string\]string "envprefix:\\"\\"" \}.MyField': invalid tag 'envprefix=': must not be empty$`,
+		},
+		"field with empty 'prompt' tag is rejected": {
+			config: &struct {
+				MyField string `prompt:""`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "prompt:\\"\\"" \}.MyField': invalid tag 'prompt=': must not be empty$`,
+		},
+		"field with invalid 'secret' tag is rejected": {
+			config: &struct {
+				MyField string `secret:"bad"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "secret:\\"bad\\"" \}.MyField': invalid tag 'secret=bad': invalid syntax$`,
+		},
+		"field with 'bytesize' tag of non-integer type is rejected": {
+			config: &struct {
+				MyField string `bytesize:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "bytesize:\\"true\\"" \}.MyField': invalid tag 'bytesize=true': only supported for integer fields$`,
+		},
+		"field with 'bytesize' tag of duration type is rejected": {
+			config: &struct {
+				MyField time.Duration `bytesize:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField time.Duration "bytesize:\\"true\\"" \}.MyField': invalid tag 'bytesize=true': only supported for integer fields$`,
+		},
+		"field with 'stdin' tag of non-string type is rejected": {
+			config: &struct {
+				MyField int `stdin:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField int "stdin:\\"true\\"" \}.MyField': invalid tag 'stdin=true': only supported for string fields$`,
+		},
+		"field with 'count' tag of non-integer type is rejected": {
+			config: &struct {
+				MyField string `count:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "count:\\"true\\"" \}.MyField': invalid tag 'count=true': only supported for integer fields$`,
+		},
+		"field with 'count=true' tag is a count flag without a value": {
+			config: &struct {
+				MyField int `count:"true"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", Count: true, DefaultValue: "0"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
 		"bad 'required' tag": {
 			config: &struct {
 				MyField string `required:"bad-value"`
@@ -329,7 +650,7 @@ func TestNewFlagSet(t *testing.T) {
 			config: &struct {
 				MyField int `args:"true"`
 			}{},
-			expectedError: `^invalid field 'struct \{ MyField int "args:\\"true\\"" \}.MyField': invalid tag 'args=true': must be typed as \[\]string$`,
+			expectedError: `^invalid field 'struct \{ MyField int "args:\\"true\\"" \}.MyField': invalid tag 'args=true': must be typed as \[\]string or a slice of encoding.TextUnmarshaler$`,
 		},
 		"struct field cannot use 'args' tag": {
 			config: &struct {
@@ -337,52 +658,197 @@ func TestNewFlagSet(t *testing.T) {
 			}{},
 			expectedError: `^invalid field 'struct \{ MyField struct \{\} "args:\\"true\\"" \}.MyField': invalid tag 'args=true': cannot be used on struct fields$`,
 		},
-		"flag name is inferred from field name": {
+		"field with empty 'arg' tag is rejected": {
 			config: &struct {
-				MyField int `flag:"true"`
+				MyField int `arg:""`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField int "arg:\\"\\"" \}.MyField': invalid tag 'arg=': must not be empty$`,
+		},
+		"field with 'name' and 'arg' tags is rejected": {
+			config: &struct {
+				MyField int `name:"f" arg:"COUNT"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField int "name:\\"f\\" arg:\\"COUNT\\"" \}.MyField': invalid tag 'arg=COUNT': cannot be a flag as well$`,
+		},
+		"field with 'args' and 'arg' tags is rejected": {
+			config: &struct {
+				MyField []string `args:"true" arg:"FILES"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField \[\]string "args:\\"true\\" arg:\\"FILES\\"" \}.MyField': invalid tag 'arg=FILES': cannot be used with the 'args' tag$`,
+		},
+		"field with 'args' and 'rawargs' tags is rejected": {
+			config: &struct {
+				MyField []string `args:"true" rawargs:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField \[\]string "args:\\"true\\" rawargs:\\"true\\"" \}.MyField': invalid tag 'rawargs=true': cannot be used with the 'args' tag$`,
+		},
+		"field with 'name' and 'rawargs' tags is rejected": {
+			config: &struct {
+				MyField []string `name:"f" rawargs:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField \[\]string "name:\\"f\\" rawargs:\\"true\\"" \}.MyField': invalid tag 'rawargs=true': cannot be a flag as well$`,
+		},
+		"field with 'rawargs' of non-slice type is rejected": {
+			config: &struct {
+				MyField string `rawargs:"true"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField string "rawargs:\\"true\\"" \}.MyField': invalid tag 'rawargs=true': must be typed as \[\]string$`,
+		},
+		"field with invalid 'rawargs' tag is rejected": {
+			config: &struct {
+				MyField []string `rawargs:"bad"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField \[\]string "rawargs:\\"bad\\"" \}.MyField': invalid tag 'rawargs=bad': invalid syntax$`,
+		},
+		"field with 'arg' of slice type is rejected": {
+			config: &struct {
+				MyField []int `arg:"COUNTS"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField \[\]int "arg:\\"COUNTS\\"" \}.MyField': invalid tag 'arg=COUNTS': unsupported field type: \[\]int$`,
+		},
+		"pointer field has no default value if nil": {
+			config: &struct {
+				MyField *int `flag:"true"`
 			}{},
 			expectedFlags: func(tc *testCase) []*flagDef {
 				return []*flagDef{
 					{
-						flagInfo: flagInfo{Name: "my-field", HasValue: true, DefaultValue: "0"},
+						flagInfo: flagInfo{Name: "my-field", HasValue: true},
 						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
 					},
 				}
 			},
 		},
-		"tag 'value-name' is not allowed for bool fields": {
+		"pointer field has its pointee's default value if not nil": {
 			config: &struct {
-				MyField bool `value-name:"VAL"`
+				MyField *bool `flag:"true"`
+			}{MyField: ptrOf(true)},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", DefaultValue: "true"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"pointer to pointer field is rejected": {
+			config: &struct {
+				MyField **int `flag:"true"`
 			}{},
-			expectedError: `^invalid field 'struct \{ MyField bool "value-name:\\"VAL\\"" \}.MyField': invalid tag 'value-name=VAL': not supported for bool fields$`,
+			expectedError: `^invalid field 'struct \{ MyField \*\*int "flag:\\"true\\"" \}.MyField': unsupported field type: \*\*int$`,
 		},
-		"nested config": {
+		"redefining disabled environment variable binding is rejected": {
 			config: &struct {
-				OuterField1 string   `name:"outer-field1" env:"OUTER_FIELD1" value-name:"outer-V1" desc:"outer-desc1" required:"true" inherited:"true"`
-				OuterField2 string   `name:"outer-field2" env:"OUTER_FIELD2" value-name:"outer-V2" desc:"outer-desc2" required:"false" inherited:"false"`
-				OuterArgs   []string `args:"true"`
-				MyStruct    struct {
-					InnerField1 string   `name:"inner-field1" env:"INNER_FIELD1" value-name:"inner-V1" desc:"inner-desc1" required:"true" inherited:"true"`
-					InnerField2 string   `name:"inner-field2" env:"INNER_FIELD2" value-name:"inner-V2" desc:"inner-desc2" required:"false" inherited:"false"`
-					InnerArgs   []string `args:"true"`
+				F1 string `name:"my-field" env:"-"`
+				F2 string `name:"my-field" env:"MY_FIELD"`
+			}{},
+			expectedError: `^invalid field 'struct \{ F1 string "name:\\"my-field\\" env:\\"-\\""; F2 string "name:\\"my-field\\" env:\\"MY_FIELD\\"" \}.F2': invalid tag 'env=MY_FIELD': cannot redefine environment variable name$`,
+		},
+		"flag name is inferred from field name": {
+			config: &struct {
+				MyField int `flag:"true"`
+			}{},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, DefaultValue: "0"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
 				}
-			}{
-				OuterField1: "out1",
-				OuterField2: "out2",
-				MyStruct: struct {
-					InnerField1 string   `name:"inner-field1" env:"INNER_FIELD1" value-name:"inner-V1" desc:"inner-desc1" required:"true" inherited:"true"`
-					InnerField2 string   `name:"inner-field2" env:"INNER_FIELD2" value-name:"inner-V2" desc:"inner-desc2" required:"false" inherited:"false"`
-					InnerArgs   []string `args:"true"`
-				}{
-					InnerField1: "inner1",
-					InnerField2: "inner2",
-				},
 			},
+		},
+		"duration default value is formatted as a duration": {
+			config: &struct {
+				MyField time.Duration `flag:"true"`
+			}{MyField: 1500 * time.Millisecond},
 			expectedFlags: func(tc *testCase) []*flagDef {
 				return []*flagDef{
 					{
-						flagInfo: flagInfo{
-							Name:         "outer-field1",
+						flagInfo: flagInfo{Name: "my-field", HasValue: true, DefaultValue: "1.5s"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("MyField")},
+					},
+				}
+			},
+		},
+		"URL default value is formatted as a URL": {
+			config: &struct {
+				Endpoint url.URL `flag:"true"`
+			}{Endpoint: url.URL{Scheme: "https", Host: "example.com", Path: "/path"}},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "endpoint", HasValue: true, DefaultValue: "https://example.com/path"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("Endpoint")},
+					},
+				}
+			},
+		},
+		"text unmarshaler default value is marshaled as text": {
+			config: &struct {
+				Level logLevel `flag:"true"`
+			}{Level: 1},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "level", HasValue: true, DefaultValue: "debug"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("Level")},
+					},
+				}
+			},
+		},
+		"flag value default value is taken from its String method": {
+			config: &struct {
+				Name upperCaseValue `flag:"true"`
+			}{Name: "ABC"},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{Name: "name", HasValue: true, DefaultValue: "ABC"},
+						Targets:  []reflect.Value{reflect.ValueOf(tc.config).Elem().FieldByName("Name")},
+					},
+				}
+			},
+		},
+		"map of non-string values is rejected": {
+			config: &struct {
+				Labels map[string]int `name:"label"`
+			}{},
+			expectedError: `^invalid field 'struct \{ Labels map\[string\]int "name:\\"label\\"" \}.Labels': unsupported field type: map\[string\]int$`,
+		},
+		"tag 'value-name' is not allowed for bool fields": {
+			config: &struct {
+				MyField bool `value-name:"VAL"`
+			}{},
+			expectedError: `^invalid field 'struct \{ MyField bool "value-name:\\"VAL\\"" \}.MyField': invalid tag 'value-name=VAL': not supported for bool fields$`,
+		},
+		"nested config": {
+			config: &struct {
+				OuterField1 string   `name:"outer-field1" env:"OUTER_FIELD1" value-name:"outer-V1" desc:"outer-desc1" required:"true" inherited:"true"`
+				OuterField2 string   `name:"outer-field2" env:"OUTER_FIELD2" value-name:"outer-V2" desc:"outer-desc2" required:"false" inherited:"false"`
+				OuterArgs   []string `args:"true"`
+				MyStruct    struct {
+					InnerField1 string   `name:"inner-field1" env:"INNER_FIELD1" value-name:"inner-V1" desc:"inner-desc1" required:"true" inherited:"true"`
+					InnerField2 string   `name:"inner-field2" env:"INNER_FIELD2" value-name:"inner-V2" desc:"inner-desc2" required:"false" inherited:"false"`
+					InnerArgs   []string `args:"true"`
+				}
+			}{
+				OuterField1: "out1",
+				OuterField2: "out2",
+				MyStruct: struct {
+					InnerField1 string   `name:"inner-field1" env:"INNER_FIELD1" value-name:"inner-V1" desc:"inner-desc1" required:"true" inherited:"true"`
+					InnerField2 string   `name:"inner-field2" env:"INNER_FIELD2" value-name:"inner-V2" desc:"inner-desc2" required:"false" inherited:"false"`
+					InnerArgs   []string `args:"true"`
+				}{
+					InnerField1: "inner1",
+					InnerField2: "inner2",
+				},
+			},
+			expectedFlags: func(tc *testCase) []*flagDef {
+				return []*flagDef{
+					{
+						flagInfo: flagInfo{
+							Name:         "outer-field1",
 							EnvVarName:   ptrOf("OUTER_FIELD1"),
 							HasValue:     true,
 							ValueName:    ptrOf("outer-V1"),
@@ -593,6 +1059,7 @@ func TestNewFlagSet(t *testing.T) {
 						Will(EqualTo(
 							expectedFlags,
 							cmp.AllowUnexported(flagDef{}),
+							cmpopts.IgnoreFields(flagDef{}, "index"),
 							cmpopts.SortSlices(func(a *flagDef, b *flagDef) bool { return stdcmp.Less(a.Name, b.Name) }),
 						)).
 						OrFail()
@@ -633,6 +1100,257 @@ func TestFlagSetWithArrays(t *testing.T) {
 	With(t).Verify(f.DefaultValue).Will(EqualTo("v1,v2")).OrFail()
 }
 
+func TestFlagSetWithNonStringArrays(t *testing.T) {
+	t.Parallel()
+
+	config := &struct {
+		Ports    []uint16        `flag:"true"`
+		Timeouts []time.Duration `flag:"true"`
+	}{Ports: []uint16{80, 443}, Timeouts: []time.Duration{time.Second}}
+
+	valueOfConfig := reflect.ValueOf(config)
+	fs, err := newFlagSet(nil, valueOfConfig)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	if len(fs.flags) != 2 {
+		t.Fatalf("Expected 2 flags, got %d", len(fs.flags))
+	}
+	With(t).Verify(fs.flags[0].DefaultValue).Will(EqualTo("80,443")).OrFail()
+	With(t).Verify(fs.flags[1].DefaultValue).Will(EqualTo("1s")).OrFail()
+
+	With(t).Verify(fs.apply(io.Discard, nil, []string{"--ports=8080,8443", "--timeouts=5s,1m"})).Will(Succeed()).OrFail()
+	With(t).Verify(config.Ports).Will(EqualTo([]uint16{8080, 8443})).OrFail()
+	With(t).Verify(config.Timeouts).Will(EqualTo([]time.Duration{5 * time.Second, time.Minute})).OrFail()
+}
+
+func TestFlagSetWithBytes(t *testing.T) {
+	t.Parallel()
+
+	config := &struct {
+		Key   []byte `encoding:"base64"`
+		Salt  []byte `encoding:"hex"`
+		Token []byte `flag:"true"`
+	}{Key: []byte("hello"), Salt: []byte{0xca, 0xfe}, Token: []byte("secret")}
+
+	valueOfConfig := reflect.ValueOf(config)
+	fs, err := newFlagSet(nil, valueOfConfig)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	if len(fs.flags) != 3 {
+		t.Fatalf("Expected 3 flags, got %d", len(fs.flags))
+	}
+	With(t).Verify(fs.flags[0].Name).Will(EqualTo("key")).OrFail()
+	With(t).Verify(fs.flags[0].Encoding).Will(EqualTo(ptrOf("base64"))).OrFail()
+	With(t).Verify(fs.flags[0].DefaultValue).Will(EqualTo("aGVsbG8=")).OrFail()
+	With(t).Verify(fs.flags[1].DefaultValue).Will(EqualTo("cafe")).OrFail()
+	With(t).Verify(fs.flags[2].Encoding).Will(BeNil()).OrFail()
+	With(t).Verify(fs.flags[2].DefaultValue).Will(EqualTo("secret")).OrFail()
+
+	With(t).Verify(fs.apply(io.Discard, nil, []string{"--key=d29ybGQ=", "--salt=beef", "--token=a,b"})).Will(Succeed()).OrFail()
+	With(t).Verify(config.Key).Will(EqualTo([]byte("world"))).OrFail()
+	With(t).Verify(config.Salt).Will(EqualTo([]byte{0xbe, 0xef})).OrFail()
+	With(t).Verify(config.Token).Will(EqualTo([]byte("a,b"))).OrFail()
+}
+
+func TestFlagSetWithMaps(t *testing.T) {
+	t.Parallel()
+
+	config := &struct {
+		Labels map[string]string `name:"label"`
+	}{Labels: map[string]string{"team": "infra", "env": "prod"}}
+
+	valueOfConfig := reflect.ValueOf(config)
+	fs, err := newFlagSet(nil, valueOfConfig)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	if len(fs.flags) != 1 {
+		t.Fatalf("Expected 1 flag, got %d", len(fs.flags))
+	}
+
+	f := fs.flags[0]
+	With(t).Verify(f.Name).Will(EqualTo("label")).OrFail()
+	With(t).Verify(f.HasValue).Will(EqualTo(true)).OrFail()
+	With(t).Verify(f.DefaultValue).Will(EqualTo("env=prod,team=infra")).OrFail()
+}
+
+func TestFlagSetApplyEnvPrefix(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Secrets map[string]string `name:"secret" envprefix:"myapp_secret_"`
+	}
+	type testCase struct {
+		envVars         map[string]string
+		args            []string
+		expectedSecrets map[string]string
+		expectedSource  string
+	}
+	testCases := map[string]testCase{
+		"no matching environment variables": {
+			envVars:        map[string]string{"MYAPP_OTHER": "x", "MYAPP_SECRET_": "empty key"},
+			expectedSource: ValueSourceDefault,
+		},
+		"matching environment variables populate the map": {
+			envVars:         map[string]string{"MYAPP_SECRET_DB": "pw1", "MYAPP_SECRET_API_KEY": "a,b=c", "MYAPP_OTHER": "x"},
+			expectedSecrets: map[string]string{"DB": "pw1", "API_KEY": "a,b=c"},
+			expectedSource:  ValueSourceEnv,
+		},
+		"CLI values are merged, overriding environment variables of the same key": {
+			envVars:         map[string]string{"MYAPP_SECRET_DB": "pw1", "MYAPP_SECRET_CACHE": "pw2"},
+			args:            []string{"--secret", "DB=pw3", "--secret", "QUEUE=pw4"},
+			expectedSecrets: map[string]string{"DB": "pw3", "CACHE": "pw2", "QUEUE": "pw4"},
+			expectedSource:  ValueSourceCLI,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(cfg))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			With(t).Verify(fs.flags[0].EnvPrefix).Will(EqualTo(ptrOf("MYAPP_SECRET_"))).OrFail()
+			With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Succeed()).OrFail()
+			With(t).Verify(cfg.Secrets).Will(EqualTo(tc.expectedSecrets)).OrFail()
+			With(t).Verify(fs.valueSources["secret"]).Will(EqualTo(tc.expectedSource)).OrFail()
+		})
+	}
+}
+
+func TestFlagSetApplyEnvFallbacks(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Token string `env:"MYAPP_TOKEN,TOKEN,LEGACY_TOKEN"`
+	}
+	type testCase struct {
+		envVars        map[string]string
+		args           []string
+		expectedToken  string
+		expectedSource string
+	}
+	testCases := map[string]testCase{
+		"no environment variables": {
+			envVars:        map[string]string{"OTHER": "x"},
+			expectedSource: ValueSourceDefault,
+		},
+		"primary environment variable wins": {
+			envVars:        map[string]string{"MYAPP_TOKEN": "t1", "TOKEN": "t2", "LEGACY_TOKEN": "t3"},
+			expectedToken:  "t1",
+			expectedSource: ValueSourceEnv,
+		},
+		"empty primary environment variable falls back": {
+			envVars:        map[string]string{"MYAPP_TOKEN": "", "LEGACY_TOKEN": "t3"},
+			expectedToken:  "t3",
+			expectedSource: ValueSourceEnv,
+		},
+		"all environment variables empty": {
+			envVars:        map[string]string{"MYAPP_TOKEN": "", "TOKEN": ""},
+			expectedSource: ValueSourceDefault,
+		},
+		"CLI overrides environment variables": {
+			envVars:        map[string]string{"TOKEN": "t2"},
+			args:           []string{"--token", "t4"},
+			expectedToken:  "t4",
+			expectedSource: ValueSourceCLI,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(cfg))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Succeed()).OrFail()
+			With(t).Verify(cfg.Token).Will(EqualTo(tc.expectedToken)).OrFail()
+			With(t).Verify(fs.valueSources["token"]).Will(EqualTo(tc.expectedSource)).OrFail()
+		})
+	}
+}
+
+func TestFlagSetApplyBoolValues(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Verbose bool     `short:"v"`
+		Force   bool     `short:"f"`
+		Name    string   `short:"n"`
+		Args    []string `args:"true"`
+	}
+	type testCase struct {
+		args                []string
+		expectedVerbose     bool
+		expectedForce       bool
+		expectedPositionals []string
+		expectedError       string
+	}
+	testCases := map[string]testCase{
+		"flag without value":                  {args: []string{"--verbose", "a"}, expectedVerbose: true, expectedPositionals: []string{"a"}},
+		"explicit value":                      {args: []string{"--verbose=false"}, expectedVerbose: false},
+		"explicit true value":                 {args: []string{"--verbose=true"}, expectedVerbose: true},
+		"separate false value":                {args: []string{"--verbose", "false", "a"}, expectedVerbose: false, expectedPositionals: []string{"a"}},
+		"separate true value":                 {args: []string{"--verbose", "true"}, expectedVerbose: true},
+		"short flag with separate value":      {args: []string{"-v", "false"}, expectedVerbose: false},
+		"cluster with separate value":         {args: []string{"-fv", "false"}, expectedVerbose: false, expectedForce: true},
+		"negated flag with explicit value":    {args: []string{"--verbose", "--no-verbose=false"}, expectedVerbose: true},
+		"other values are positionals":        {args: []string{"--verbose", "no"}, expectedVerbose: true, expectedPositionals: []string{"no"}},
+		"values after separator are ignored":  {args: []string{"--verbose", "--", "false"}, expectedVerbose: true, expectedPositionals: []string{"false"}},
+		"value flags are unaffected":          {args: []string{"--name", "true", "false"}, expectedPositionals: []string{"false"}},
+		"invalid explicit value":              {args: []string{"--verbose=maybe"}, expectedError: `^invalid boolean value "maybe" for -verbose: invalid value 'maybe' for flag 'verbose': invalid syntax$`},
+		"invalid negated explicit value":      {args: []string{"--no-verbose=maybe"}, expectedError: `^invalid boolean value "maybe" for -no-verbose: invalid value 'maybe' for flag 'verbose': invalid syntax$`},
+		"explicit yes value":                  {args: []string{"--verbose=yes"}, expectedVerbose: true},
+		"explicit off value":                  {args: []string{"--force", "--force=off"}, expectedForce: false},
+		"negated flag with explicit on value": {args: []string{"--verbose", "--no-verbose=on"}, expectedVerbose: false},
+		"explicit value for repeated flags":   {args: []string{"-v", "-v", "false"}, expectedVerbose: false},
+		"separate value of last flag in args": {args: []string{"--force", "true", "--verbose", "false"}, expectedVerbose: false, expectedForce: true},
+		"negated flag with separate value":    {args: []string{"--verbose", "--no-verbose", "false", "a"}, expectedVerbose: true, expectedPositionals: []string{"a"}},
+		"separate yes value is positional":    {args: []string{"--verbose", "yes"}, expectedVerbose: true, expectedPositionals: []string{"yes"}},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			cfg := &config{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(cfg))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			err = fs.apply(io.Discard, nil, tc.args)
+			if tc.expectedError != "" {
+				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+				return
+			}
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			With(t).Verify(cfg.Verbose).Will(EqualTo(tc.expectedVerbose)).OrFail()
+			With(t).Verify(cfg.Force).Will(EqualTo(tc.expectedForce)).OrFail()
+			With(t).Verify(cfg.Args).Will(EqualTo(tc.expectedPositionals, cmpopts.EquateEmpty())).OrFail()
+		})
+	}
+}
+
+func TestFlagSetWithIPs(t *testing.T) {
+	t.Parallel()
+
+	config := &struct {
+		Listen net.IP    `flag:"true"`
+		Subnet net.IPNet `flag:"true"`
+	}{
+		Listen: net.ParseIP("10.0.0.1"),
+		Subnet: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+	}
+
+	valueOfConfig := reflect.ValueOf(config)
+	fs, err := newFlagSet(nil, valueOfConfig)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	if len(fs.flags) != 2 {
+		t.Fatalf("Expected 2 flags, got %d", len(fs.flags))
+	}
+
+	With(t).Verify(fs.flags[0].Name).Will(EqualTo("listen")).OrFail()
+	With(t).Verify(fs.flags[0].HasValue).Will(EqualTo(true)).OrFail()
+	With(t).Verify(fs.flags[0].DefaultValue).Will(EqualTo("10.0.0.1")).OrFail()
+	With(t).Verify(fs.flags[1].Name).Will(EqualTo("subnet")).OrFail()
+	With(t).Verify(fs.flags[1].HasValue).Will(EqualTo(true)).OrFail()
+	With(t).Verify(fs.flags[1].DefaultValue).Will(EqualTo("10.0.0.0/8")).OrFail()
+
+	With(t).Verify(fs.apply(io.Discard, nil, []string{"--listen=192.168.0.1", "--subnet=192.168.0.0/16"})).Will(Succeed()).OrFail()
+	With(t).Verify(config.Listen.String()).Will(EqualTo("192.168.0.1")).OrFail()
+	With(t).Verify(config.Subnet.String()).Will(EqualTo("192.168.0.0/16")).OrFail()
+}
+
 func TestFlagSetGetMergedFlagDefs(t *testing.T) {
 	t.Parallel()
 	type testCase struct {
@@ -789,7 +1507,7 @@ func TestFlagSetGetMergedFlagDefs(t *testing.T) {
 					With(t).Verify(err).Will(BeNil()).OrFail()
 					With(t).
 						Verify(mergedFlagDefs).
-						Will(EqualTo(tc.expectedFlags(&tc), cmp.AllowUnexported(flagDef{}, mergedFlagDef{}))).OrFail()
+						Will(EqualTo(tc.expectedFlags(&tc), cmp.AllowUnexported(flagDef{}, mergedFlagDef{}), cmpopts.IgnoreFields(flagDef{}, "index"))).OrFail()
 				} else {
 					With(t).Verify(fs.flags).Will(BeNil()).OrFail()
 				}
@@ -822,8 +1540,8 @@ func TestFlagSetUsagePrinting(t *testing.T) {
 			},
 			expectedSingleLineUsage: `--my-field=VVV`,
 			expectedMultiLineUsage: `
---my-field=VVV      desc (default value: abc, environment variable: 
-                    MY_FIELD)
+--my-field=VVV  desc (default value: abc, environment variable: 
+                MY_FIELD)
 `,
 		},
 		"flags merged across parents": {
@@ -840,10 +1558,116 @@ func TestFlagSetUsagePrinting(t *testing.T) {
 			},
 			expectedSingleLineUsage: `--my-field1=VVV [--my-field2]`,
 			expectedMultiLineUsage: `
---my-field1=VVV     desc1 (default value: v1, environment variable: 
-                    MF1)
-[--my-field2]       desc2 (default value: false, environment 
-                    variable: MF2)
+--my-field1=VVV  desc1 (default value: v1, environment variable: MF1)
+[--my-field2]    desc2 (default value: false, environment variable: 
+                 MF2)
+`,
+		},
+		"hidden flags": {
+			config: &struct {
+				Visible bool   `desc:"Visible flag."`
+				Secret  string `hidden:"true" desc:"Hidden flag."`
+			}{},
+			expectedSingleLineUsage: `[--visible]`,
+			expectedMultiLineUsage: `
+[--visible]  Visible flag. (default value: false, environment 
+             variable: VISIBLE)
+`,
+		},
+		"deprecated flags": {
+			config: &struct {
+				OldName string `desc:"Old name." deprecated:"use --new-name instead"`
+			}{},
+			expectedSingleLineUsage: `[--old-name=VALUE]`,
+			expectedMultiLineUsage: `
+[--old-name=VALUE]  Old name. (environment variable: OLD_NAME) 
+                    (DEPRECATED: use --new-name instead)
+`,
+		},
+		"choices": {
+			config: &struct {
+				Format string `choices:"json,yaml,text" desc:"Output format."`
+			}{Format: "text"},
+			expectedSingleLineUsage: `[--format=VALUE]`,
+			expectedMultiLineUsage: `
+[--format=VALUE]  Output format. (default value: text, environment 
+                  variable: FORMAT) (one of: json, yaml, text)
+`,
+		},
+		"environment variables prefix": {
+			config: &struct {
+				Secret map[string]string `desc:"Secrets." envprefix:"MYAPP_SECRET_"`
+			}{},
+			expectedSingleLineUsage: `[--secret=VALUE]`,
+			expectedMultiLineUsage: `
+[--secret=VALUE]  Secrets. (environment variable: SECRET, environment 
+                  variables: MYAPP_SECRET_*)
+`,
+		},
+		"fallback environment variables": {
+			config: &struct {
+				Token string `desc:"API token." env:"MYAPP_TOKEN,TOKEN"`
+			}{},
+			expectedSingleLineUsage: `[--token=VALUE]`,
+			expectedMultiLineUsage: `
+[--token=VALUE]  API token. (environment variables: MYAPP_TOKEN, 
+                 TOKEN)
+`,
+		},
+		"short aliases": {
+			config: &struct {
+				Verbose bool   `short:"v" desc:"Verbose output."`
+				Name    string `short:"n" required:"true"`
+			}{},
+			expectedSingleLineUsage: `-n, --name=VALUE [-v, --verbose]`,
+			expectedMultiLineUsage: `
+-n, --name=VALUE  environment variable: NAME
+[-v, --verbose]   Verbose output. (default value: false, environment 
+                  variable: VERBOSE)
+`,
+		},
+		"negatable boolean flags": {
+			config: &struct {
+				EnableCache bool `desc:"Cache results."`
+			}{EnableCache: true},
+			expectedSingleLineUsage: `[--enable-cache]`,
+			expectedMultiLineUsage: `
+[--enable-cache]  Cache results. (default value: true, environment 
+                  variable: ENABLE_CACHE) (negate with 
+                  --no-enable-cache)
+`,
+		},
+		"count flags": {
+			config: &struct {
+				Verbosity int `short:"v" count:"true" desc:"Verbosity level."`
+			}{},
+			expectedSingleLineUsage: `[-v, --verbosity]`,
+			expectedMultiLineUsage: `
+[-v, --verbosity]  Verbosity level. (default value: 0, environment 
+                   variable: VERBOSITY) (repeatable)
+`,
+		},
+		"very short flag names": {
+			config: &struct {
+				Q bool `name:"q" desc:"Quiet."`
+			}{},
+			expectedSingleLineUsage: `[--q]`,
+			expectedMultiLineUsage: `
+[--q]  Quiet. (default value: false, environment variable: Q)
+`,
+		},
+		"very long flag names": {
+			config: &struct {
+				Long  string `name:"a-very-long-flag-name-that-exceeds-the-cap" desc:"Long flag."`
+				Short bool   `name:"short" desc:"Short flag."`
+			}{},
+			expectedSingleLineUsage: `[--a-very-long-flag-name-that-exceeds-the-cap=VALUE] [--short]`,
+			expectedMultiLineUsage: `
+[--a-very-long-flag-name-that-exceeds-the-cap=VALUE]
+                                   Long flag. (environment variable: 
+                                   A_VERY_LONG_FLAG_NAME_THAT_EXCEEDS_THE_CAP)
+[--short]                          Short flag. (default value: false, 
+                                   environment variable: SHORT)
 `,
 		},
 		"positionals without flags": {
@@ -852,6 +1676,29 @@ func TestFlagSetUsagePrinting(t *testing.T) {
 			}{},
 			expectedSingleLineUsage: `[ARGS...]`,
 			expectedMultiLineUsage: `
+`,
+		},
+		"flags without environment variables": {
+			config: &struct {
+				Secret string `env:"-" desc:"Secret."`
+				Token  string `env:"-"`
+				Level  int    `env:"-" desc:"Level."`
+			}{},
+			expectedSingleLineUsage: `[--level=VALUE] [--secret=VALUE] [--token=VALUE]`,
+			expectedMultiLineUsage: `
+[--level=VALUE]   Level. (default value: 0)
+[--secret=VALUE]  Secret.
+[--token=VALUE]   
+`,
+		},
+		"typed positionals": {
+			config: &struct {
+				Count int      `arg:"COUNT"`
+				Name  string   `arg:"NAME"`
+				Args  []string `args:"true"`
+			}{},
+			expectedSingleLineUsage: `[COUNT] [NAME] [ARGS...]`,
+			expectedMultiLineUsage: `
 `,
 		},
 		"flags and positionals": {
@@ -864,9 +1711,9 @@ func TestFlagSetUsagePrinting(t *testing.T) {
 			},
 			expectedSingleLineUsage: `[--my-field1=FF] [--my-field2] [ARGS...]`,
 			expectedMultiLineUsage: `
-[--my-field1=FF]    default value: v1, environment variable: MY_FIELD1
-[--my-field2]       desc2 (default value: false, environment 
-                    variable: MF2)
+[--my-field1=FF]  default value: v1, environment variable: MY_FIELD1
+[--my-field2]     desc2 (default value: false, environment variable: 
+                  MF2)
 `,
 		},
 	}
@@ -897,7 +1744,7 @@ func TestFlagSetUsagePrinting(t *testing.T) {
 
 			multiLine, err := NewWrappingWriter(width)
 			With(t).Verify(err).Will(BeNil()).OrFail()
-			With(t).Verify(fs.printFlagsMultiLine(multiLine, "")).Will(Succeed()).OrFail()
+			With(t).Verify(fs.printFlagsMultiLine(multiLine, "", "", false)).Will(Succeed()).OrFail()
 			With(t).Verify(multiLine.String()).Will(EqualTo(tc.expectedMultiLineUsage[1:])).OrFail()
 		})
 	}
@@ -1034,6 +1881,28 @@ func TestFlagSetApply(t *testing.T) {
 				F1 string `name:"my-field1" env:"MF1"`
 			}{F1: "correct value for F1"},
 		},
+		"boolean environment variables accept extra literals": {
+			config: &struct {
+				Enabled bool `flag:"true"`
+				Verbose bool `flag:"true"`
+				Colors  bool `flag:"true"`
+			}{Colors: true},
+			envVars: map[string]string{"ENABLED": "yes", "VERBOSE": "On", "COLORS": "disabled"},
+			args:    []string{},
+			expectedConfig: &struct {
+				Enabled bool `flag:"true"`
+				Verbose bool `flag:"true"`
+				Colors  bool `flag:"true"`
+			}{Enabled: true, Verbose: true, Colors: false},
+		},
+		"invalid boolean environment variable": {
+			config: &struct {
+				Enabled bool `flag:"true"`
+			}{},
+			envVars:       map[string]string{"ENABLED": "maybe"},
+			args:          []string{},
+			expectedError: `^invalid value 'maybe' for flag 'enabled': invalid syntax$`,
+		},
 		"default value preserved": {
 			config: &struct {
 				F1 string `name:"my-field1" env:"MF1"`
@@ -1079,29 +1948,330 @@ func TestFlagSetApply(t *testing.T) {
 				Args: []string{"a", "b", "c"},
 			},
 		},
-		"invalid flag error": {
+		"repeated slice flags accumulate instead of overriding defaults": {
 			config: &struct {
-				F1 string `name:"my-field1"`
-			}{},
-			envVars:       map[string]string{},
-			args:          []string{"--my-field1=VVV1", "--my-field2=VVV2"},
-			expectedError: `^unknown flag: --my-field2$`,
+				Tags []string `name:"tag"`
+			}{Tags: []string{"default"}},
+			envVars: map[string]string{"TAG": "from-env"},
+			args:    []string{"--tag=a", "--tag=b,c", "--tag=d"},
+			expectedConfig: &struct {
+				Tags []string `name:"tag"`
+			}{Tags: []string{"a", "b", "c", "d"}},
 		},
-		"required field is missing error": {
+		"map flags accumulate keys across CLI occurrences": {
 			config: &struct {
-				F1 string `name:"my-field1"`
-				F2 string `name:"my-field2" required:"true"`
-			}{F1: "v1"},
-			envVars:       map[string]string{},
-			args:          []string{"--my-field1=VVV1"},
-			expectedError: `^required flag is missing: --my-field2$`,
+				Labels map[string]string `name:"label"`
+			}{Labels: map[string]string{"env": "dev"}},
+			envVars: map[string]string{"LABEL": "region=eu"},
+			args:    []string{"--label=env=prod", "--label=team=infra"},
+			expectedConfig: &struct {
+				Labels map[string]string `name:"label"`
+			}{Labels: map[string]string{"env": "prod", "team": "infra"}},
 		},
-		"optional string field is not required": {
+		"map flag environment variable replaces default value": {
 			config: &struct {
-				F1 string `desc:"Some desc."`
-				F2 string `required:"true" desc:"Some desc."`
-			}{F2: "v2"},
-			envVars: map[string]string{},
+				Labels map[string]string `name:"label"`
+			}{Labels: map[string]string{"env": "dev"}},
+			envVars: map[string]string{"LABEL": "region=eu,team=infra"},
+			expectedConfig: &struct {
+				Labels map[string]string `name:"label"`
+			}{Labels: map[string]string{"region": "eu", "team": "infra"}},
+		},
+		"value in choices is accepted": {
+			config: &struct {
+				Format string   `choices:"json,yaml,text"`
+				Kinds  []string `choices:"a,b,c"`
+			}{Format: "text"},
+			args: []string{"--format=yaml", "--kinds=a,c"},
+			expectedConfig: &struct {
+				Format string   `choices:"json,yaml,text"`
+				Kinds  []string `choices:"a,b,c"`
+			}{Format: "yaml", Kinds: []string{"a", "c"}},
+		},
+		"value not in choices is rejected": {
+			config: &struct {
+				Format string `choices:"json,yaml,text"`
+			}{Format: "text"},
+			args:          []string{"--format=xml"},
+			expectedError: `^invalid value "xml" for flag -format: invalid value 'xml' for flag 'format': must be one of: json, yaml, text$`,
+		},
+		"slice element not in choices is rejected": {
+			config: &struct {
+				Kinds []string `choices:"a,b,c"`
+			}{},
+			args:          []string{"--kinds=a,d"},
+			expectedError: `^invalid value "a,d" for flag -kinds: invalid value 'd' for flag 'kinds': must be one of: a, b, c$`,
+		},
+		"value matching pattern is accepted": {
+			config: &struct {
+				Name  string   `pattern:"^[a-z][a-z0-9-]*$"`
+				Names []string `pattern:"^[a-z]+$"`
+			}{},
+			args: []string{"--name=my-app1", "--names=a,bc"},
+			expectedConfig: &struct {
+				Name  string   `pattern:"^[a-z][a-z0-9-]*$"`
+				Names []string `pattern:"^[a-z]+$"`
+			}{Name: "my-app1", Names: []string{"a", "bc"}},
+		},
+		"value not matching pattern is rejected": {
+			config: &struct {
+				Name string `pattern:"^[a-z][a-z0-9-]*$"`
+			}{},
+			args:          []string{"--name=1app"},
+			expectedError: `^invalid value "1app" for flag -name: invalid value '1app' for flag 'name': must match pattern: \^\[a-z\]\[a-z0-9-\]\*\$$`,
+		},
+		"slice element not matching pattern is rejected": {
+			config: &struct {
+				Names []string `pattern:"^[a-z]+$"`
+			}{},
+			args:          []string{"--names=a,B"},
+			expectedError: `^invalid value "a,B" for flag -names: invalid value 'B' for flag 'names': must match pattern: \^\[a-z\]\+\$$`,
+		},
+		"values within range are accepted": {
+			config: &struct {
+				Port  int     `min:"1" max:"65535"`
+				Ratio float64 `min:"0" max:"1"`
+				Count uint8   `max:"10"`
+				Sizes []int   `min:"1"`
+			}{},
+			args: []string{"--port=65535", "--ratio=0.5", "--count=10", "--sizes=1,2"},
+			expectedConfig: &struct {
+				Port  int     `min:"1" max:"65535"`
+				Ratio float64 `min:"0" max:"1"`
+				Count uint8   `max:"10"`
+				Sizes []int   `min:"1"`
+			}{Port: 65535, Ratio: 0.5, Count: 10, Sizes: []int{1, 2}},
+		},
+		"default values are not checked against range": {
+			config: &struct {
+				Port int `min:"1" max:"65535"`
+			}{},
+			expectedConfig: &struct {
+				Port int `min:"1" max:"65535"`
+			}{},
+		},
+		"value above maximum is rejected": {
+			config: &struct {
+				Port int `min:"1" max:"65535"`
+			}{},
+			args:          []string{"--port=70000"},
+			expectedError: `^invalid value "70000" for flag -port: invalid value '70000' for flag 'port': out of range \[1, 65535\]$`,
+		},
+		"value below minimum is rejected": {
+			config: &struct {
+				Ratio float64 `min:"0.5"`
+			}{},
+			args:          []string{"--ratio=0.25"},
+			expectedError: `^invalid value "0.25" for flag -ratio: invalid value '0.25' for flag 'ratio': must be at least 0.5$`,
+		},
+		"unsigned value above maximum is rejected": {
+			config: &struct {
+				Count uint `max:"10"`
+			}{},
+			args:          []string{"--count=11"},
+			expectedError: `^invalid value "11" for flag -count: invalid value '11' for flag 'count': must be at most 10$`,
+		},
+		"slice element out of range is rejected": {
+			config: &struct {
+				Sizes []int `min:"1"`
+			}{},
+			args:          []string{"--sizes=1,0"},
+			expectedError: `^invalid value "1,0" for flag -sizes: invalid value '0' for flag 'sizes': must be at least 1$`,
+		},
+		"typed positionals are converted": {
+			config: &struct {
+				Count   int           `arg:"COUNT"`
+				Name    string        `arg:"NAME"`
+				Timeout time.Duration `arg:"TIMEOUT"`
+				Args    []string      `args:"true"`
+			}{Timeout: time.Second},
+			args: []string{"3", "abc"},
+			expectedConfig: &struct {
+				Count   int           `arg:"COUNT"`
+				Name    string        `arg:"NAME"`
+				Timeout time.Duration `arg:"TIMEOUT"`
+				Args    []string      `args:"true"`
+			}{Count: 3, Name: "abc", Timeout: time.Second, Args: []string{"3", "abc"}},
+		},
+		"invalid typed positional is rejected": {
+			config: &struct {
+				Name  string `arg:"NAME"`
+				Count int    `arg:"COUNT"`
+			}{},
+			args:          []string{"abc", "x"},
+			expectedError: `^invalid value 'x' for positional argument #2 \(COUNT\): invalid syntax$`,
+		},
+		"positionals parsed into text unmarshalers": {
+			config: &struct {
+				Pairs []keyValue `args:"true"`
+				Args  []string   `args:"true"`
+			}{},
+			args: []string{"k1=v1", "k2=v2"},
+			expectedConfig: &struct {
+				Pairs []keyValue `args:"true"`
+				Args  []string   `args:"true"`
+			}{Pairs: []keyValue{{Key: "k1", Value: "v1"}, {Key: "k2", Value: "v2"}}, Args: []string{"k1=v1", "k2=v2"}},
+		},
+		"invalid positional for text unmarshaler is rejected": {
+			config: &struct {
+				Pairs []keyValue `args:"true"`
+			}{},
+			args:          []string{"k1=v1", "k2"},
+			expectedError: `^invalid value 'k2' for positional argument #2 \(ARGS\): missing '=' separator$`,
+		},
+		"unset pointer fields stay nil": {
+			config: &struct {
+				Count   *int    `flag:"true"`
+				Name    *string `flag:"true"`
+				Verbose *bool   `flag:"true"`
+			}{},
+			expectedConfig: &struct {
+				Count   *int    `flag:"true"`
+				Name    *string `flag:"true"`
+				Verbose *bool   `flag:"true"`
+			}{},
+		},
+		"pointer fields are allocated when set": {
+			config: &struct {
+				Count   *int           `flag:"true"`
+				Name    *string        `flag:"true"`
+				Verbose *bool          `flag:"true"`
+				Timeout *time.Duration `flag:"true"`
+			}{},
+			args:    []string{"--count=0", "--verbose", "--timeout=1s"},
+			envVars: map[string]string{"NAME": "abc"},
+			expectedConfig: &struct {
+				Count   *int           `flag:"true"`
+				Name    *string        `flag:"true"`
+				Verbose *bool          `flag:"true"`
+				Timeout *time.Duration `flag:"true"`
+			}{Count: ptrOf(0), Name: ptrOf("abc"), Verbose: ptrOf(true), Timeout: ptrOf(time.Second)},
+		},
+		"non-nil pointer fields provide default values": {
+			config: &struct {
+				Count *int `flag:"true"`
+			}{Count: ptrOf(3)},
+			expectedConfig: &struct {
+				Count *int `flag:"true"`
+			}{Count: ptrOf(3)},
+		},
+		"environment variable binding can be disabled": {
+			config: &struct {
+				Secret string `env:"-"`
+			}{},
+			envVars: map[string]string{"SECRET": "s3cr3t", "-": "s3cr3t"},
+			expectedConfig: &struct {
+				Secret string `env:"-"`
+			}{},
+		},
+		"short flag aliases are supported": {
+			config: &struct {
+				Verbose bool   `short:"v"`
+				Name    string `short:"n"`
+			}{},
+			args: []string{"-v", "-n", "abc"},
+			expectedConfig: &struct {
+				Verbose bool   `short:"v"`
+				Name    string `short:"n"`
+			}{Verbose: true, Name: "abc"},
+		},
+		"short flag alias conflicting with another flag is rejected": {
+			config: &struct {
+				V       bool `flag:"true"`
+				Verbose bool `short:"v"`
+			}{},
+			expectedError: `^short name 'v' of flag 'verbose' conflicts with another flag$`,
+		},
+		"negated boolean flags are supported": {
+			config: &struct {
+				EnableCache bool `flag:"true"`
+				Verbose     bool `flag:"true"`
+			}{EnableCache: true},
+			args: []string{"--no-enable-cache", "--verbose"},
+			expectedConfig: &struct {
+				EnableCache bool `flag:"true"`
+				Verbose     bool `flag:"true"`
+			}{EnableCache: false, Verbose: true},
+		},
+		"explicit flags take precedence over negated boolean flags": {
+			config: &struct {
+				Cache   bool `flag:"true"`
+				NoCache bool `flag:"true"`
+			}{Cache: true},
+			args: []string{"--no-cache"},
+			expectedConfig: &struct {
+				Cache   bool `flag:"true"`
+				NoCache bool `flag:"true"`
+			}{Cache: true, NoCache: true},
+		},
+		"unknown negated flag error": {
+			config: &struct {
+				F1 bool `name:"my-field1"`
+			}{},
+			args:          []string{"--no-my-field2"},
+			expectedError: `^unknown flag: --no-my-field2$`,
+		},
+		"count flags increment on each occurrence": {
+			config: &struct {
+				Verbosity int  `short:"v" count:"true"`
+				Level     uint `count:"true"`
+			}{Level: 1},
+			args: []string{"-v", "--verbosity", "-v", "--level"},
+			expectedConfig: &struct {
+				Verbosity int  `short:"v" count:"true"`
+				Level     uint `count:"true"`
+			}{Verbosity: 3, Level: 2},
+		},
+		"count flags increment environment variable value": {
+			config: &struct {
+				Verbosity int `short:"v" count:"true"`
+			}{},
+			envVars: map[string]string{"VERBOSITY": "2"},
+			args:    []string{"-v"},
+			expectedConfig: &struct {
+				Verbosity int `short:"v" count:"true"`
+			}{Verbosity: 3},
+		},
+		"invalid flag error": {
+			config: &struct {
+				F1 string `name:"my-field1"`
+			}{},
+			envVars:       map[string]string{},
+			args:          []string{"--my-field1=VVV1", "--my-field2=VVV2"},
+			expectedError: `^unknown flag: --my-field2 \(did you mean --my-field1\?\)$`,
+		},
+		"hidden flags are applied": {
+			config: &struct {
+				F1 string `name:"my-field1" hidden:"true"`
+			}{},
+			args: []string{"--my-field1=VVV1"},
+			expectedConfig: &struct {
+				F1 string `name:"my-field1" hidden:"true"`
+			}{F1: "VVV1"},
+		},
+		"invalid flag error without suggestion": {
+			config: &struct {
+				F1 string `name:"my-field1"`
+			}{},
+			envVars:       map[string]string{},
+			args:          []string{"--my-field1=VVV1", "--other=VVV2"},
+			expectedError: `^unknown flag: --other$`,
+		},
+		"required field is missing error": {
+			config: &struct {
+				F1 string `name:"my-field1"`
+				F2 string `name:"my-field2" required:"true"`
+			}{F1: "v1"},
+			envVars:       map[string]string{},
+			args:          []string{"--my-field1=VVV1"},
+			expectedError: `^required flag is missing: --my-field2$`,
+		},
+		"optional string field is not required": {
+			config: &struct {
+				F1 string `desc:"Some desc."`
+				F2 string `required:"true" desc:"Some desc."`
+			}{F2: "v2"},
+			envVars: map[string]string{},
 			args:    []string{},
 			expectedConfig: &struct {
 				F1 string `desc:"Some desc."`
@@ -1146,12 +2316,611 @@ func TestFlagSetApply(t *testing.T) {
 			With(t).Verify(err).Will(BeNil()).OrFail()
 
 			if tc.expectedError != "" {
-				With(t).Verify(fs.apply(tc.envVars, tc.args)).Will(Fail(tc.expectedError)).OrFail()
+				With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Fail(tc.expectedError)).OrFail()
 			} else {
-				With(t).Verify(fs.apply(tc.envVars, tc.args)).Will(Succeed()).OrFail()
+				With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Succeed()).OrFail()
 				With(t).Verify(tc.parentConfig).Will(EqualTo(tc.expectedParentConfig)).OrFail()
 				With(t).Verify(tc.config).Will(EqualTo(tc.expectedConfig)).OrFail()
 			}
 		})
 	}
 }
+
+type keyValue struct {
+	Key   string
+	Value string
+}
+
+func (kv *keyValue) UnmarshalText(text []byte) error {
+	var found bool
+	if kv.Key, kv.Value, found = strings.Cut(string(text), "="); !found {
+		return fmt.Errorf("missing '=' separator")
+	}
+	return nil
+}
+
+func TestFlagSetApplyDeprecated(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		args           []string
+		envVars        map[string]string
+		expectedOutput string
+	}
+	testCases := map[string]testCase{
+		"deprecated flag not given": {
+			args: []string{"--new-name=v"},
+		},
+		"deprecated flag given": {
+			args:           []string{"--old-name=v"},
+			expectedOutput: "flag --old-name is deprecated: use --new-name instead\n",
+		},
+		"deprecated flag given via environment variable": {
+			envVars: map[string]string{"OLD_NAME": "v"},
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			fs, err := newFlagSet(nil, reflect.ValueOf(&struct {
+				OldName string `deprecated:"use --new-name instead"`
+				NewName string `flag:"true"`
+			}{}))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+
+			b := &bytes.Buffer{}
+			With(t).Verify(fs.apply(b, tc.envVars, tc.args)).Will(Succeed()).OrFail()
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOutput)).OrFail()
+		})
+	}
+}
+
+func TestFlagSetApplyConfigFile(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Name    string   `flag:"true"`
+		Count   int      `flag:"true"`
+		Enabled bool     `flag:"true"`
+		Tags    []string `flag:"true"`
+	}
+	type testCase struct {
+		fileContents   string
+		envVars        map[string]string
+		args           []string
+		expectedConfig config
+		expectedError  string
+	}
+	testCases := map[string]testCase{
+		"no config file given": {
+			fileContents:   `{"name":"n1"}`,
+			expectedConfig: config{Name: "default"},
+		},
+		"values applied from config file": {
+			fileContents:   `{"name":"n1","count":3,"enabled":true,"tags":["t1","t2"]}`,
+			args:           []string{"--config={{file}}"},
+			expectedConfig: config{Name: "n1", Count: 3, Enabled: true, Tags: []string{"t1", "t2"}},
+		},
+		"config file given as separate argument": {
+			fileContents:   `{"name":"n1"}`,
+			args:           []string{"--config", "{{file}}"},
+			expectedConfig: config{Name: "n1"},
+		},
+		"config file given via environment variable": {
+			fileContents:   `{"name":"n1"}`,
+			envVars:        map[string]string{"CONFIG": "{{file}}"},
+			expectedConfig: config{Name: "n1"},
+		},
+		"environment variables override config file": {
+			fileContents:   `{"name":"n1","count":3}`,
+			envVars:        map[string]string{"NAME": "n2"},
+			args:           []string{"--config={{file}}"},
+			expectedConfig: config{Name: "n2", Count: 3},
+		},
+		"CLI flags override config file": {
+			fileContents:   `{"name":"n1","count":3}`,
+			args:           []string{"--config={{file}}", "--count=4"},
+			expectedConfig: config{Name: "n1", Count: 4},
+		},
+		"config file after positionals separator is ignored": {
+			fileContents:   `{"name":"n1"}`,
+			args:           []string{"--", "--config={{file}}"},
+			expectedConfig: config{Name: "default"},
+		},
+		"missing config file": {
+			args:          []string{"--config=/no/such/file.json"},
+			expectedError: `^failed reading config file: open /no/such/file.json: no such file or directory$`,
+		},
+		"malformed config file": {
+			fileContents:  `{"name":`,
+			args:          []string{"--config={{file}}"},
+			expectedError: `^failed parsing config file '.+': unexpected EOF$`,
+		},
+		"unknown key in config file": {
+			fileContents:  `{"unknown":"v"}`,
+			args:          []string{"--config={{file}}"},
+			expectedError: `^invalid config file '.+': unknown flag: --unknown$`,
+		},
+		"unsupported value in config file": {
+			fileContents:  `{"name":{"k":"v"}}`,
+			args:          []string{"--config={{file}}"},
+			expectedError: `^failed parsing config file '.+': unsupported value for flag 'name': map\[k:v]$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "config.json")
+			if tc.fileContents != "" {
+				With(t).Verify(os.WriteFile(file, []byte(tc.fileContents), 0600)).Will(Succeed()).OrFail()
+			}
+			for i, arg := range tc.args {
+				tc.args[i] = strings.ReplaceAll(arg, "{{file}}", file)
+			}
+			for k, v := range tc.envVars {
+				tc.envVars[k] = strings.ReplaceAll(v, "{{file}}", file)
+			}
+
+			parent, err := newFlagSet(nil, reflect.ValueOf(&ConfigFileConfig{}))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			parent.configFileFlagName = "config"
+
+			c := &config{Name: "default"}
+			fs, err := newFlagSet(parent, reflect.ValueOf(c))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+
+			if tc.expectedError != "" {
+				With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Succeed()).OrFail()
+				With(t).Verify(*c).Will(EqualTo(tc.expectedConfig)).OrFail()
+			}
+		})
+	}
+}
+
+func TestFlagSetApplyPrecedence(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		config            bool
+		env               bool
+		cli               bool
+		expectedValue     string
+		expectedSource    string
+		expectedSetByUser bool
+	}
+	testCases := map[string]testCase{
+		"default":                {expectedValue: "default", expectedSource: ValueSourceDefault},
+		"config beats default":   {config: true, expectedValue: "config", expectedSource: ValueSourceConfig},
+		"env beats default":      {env: true, expectedValue: "env", expectedSource: ValueSourceEnv},
+		"env beats config":       {config: true, env: true, expectedValue: "env", expectedSource: ValueSourceEnv},
+		"CLI beats default":      {cli: true, expectedValue: "cli", expectedSource: ValueSourceCLI, expectedSetByUser: true},
+		"CLI beats config":       {config: true, cli: true, expectedValue: "cli", expectedSource: ValueSourceCLI, expectedSetByUser: true},
+		"CLI beats env":          {env: true, cli: true, expectedValue: "cli", expectedSource: ValueSourceCLI, expectedSetByUser: true},
+		"CLI beats env & config": {config: true, env: true, cli: true, expectedValue: "cli", expectedSource: ValueSourceCLI, expectedSetByUser: true},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			parent, err := newFlagSet(nil, reflect.ValueOf(&ConfigFileConfig{}))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			parent.configFileFlagName = "config"
+
+			// Map flags are replaced (rather than merged into) by each source just like other flags
+			c := &struct {
+				Name   string            `flag:"true"`
+				Labels map[string]string `name:"label"`
+			}{Name: "default", Labels: map[string]string{"default": "1"}}
+			fs, err := newFlagSet(parent, reflect.ValueOf(c))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+
+			var args []string
+			envVars := make(map[string]string)
+			if tc.config {
+				file := filepath.Join(t.TempDir(), "config.json")
+				With(t).Verify(os.WriteFile(file, []byte(`{"name":"config","label":"config=1"}`), 0600)).Will(Succeed()).OrFail()
+				args = append(args, "--config="+file)
+			}
+			if tc.env {
+				envVars["NAME"] = "env"
+				envVars["LABEL"] = "env=1"
+			}
+			if tc.cli {
+				args = append(args, "--name=cli", "--label=cli=1")
+			}
+
+			mergedFlagDefs, err := fs.getMergedFlagDefs()
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			resolver := &valueResolver{}
+			With(t).Verify(fs.applyDefaultValues(mergedFlagDefs)).Will(Succeed()).OrFail()
+			With(t).Verify(fs.applyConfigFile(mergedFlagDefs, envVars, args, resolver)).Will(Succeed()).OrFail()
+			With(t).Verify(fs.applyEnvVars(mergedFlagDefs, envVars, resolver)).Will(Succeed()).OrFail()
+			_, err = fs.applyCLIArgs(mergedFlagDefs, args, resolver)
+			With(t).Verify(err).Will(BeNil()).OrFail()
+
+			i := slices.IndexFunc(mergedFlagDefs, func(mfd *mergedFlagDef) bool { return mfd.Name == "name" })
+			With(t).Verify(c.Name).Will(EqualTo(tc.expectedValue)).OrFail()
+			With(t).Verify(c.Labels).Will(EqualTo(map[string]string{tc.expectedValue: "1"})).OrFail()
+			With(t).Verify(mergedFlagDefs[i].getValueSource()).Will(EqualTo(tc.expectedSource)).OrFail()
+			With(t).Verify(mergedFlagDefs[i].setByUser).Will(EqualTo(tc.expectedSetByUser)).OrFail()
+		})
+	}
+}
+
+func TestFlagSetApplyConfigFileDecoders(t *testing.T) {
+	t.Parallel()
+	kvDecoder := ConfigDecoderFunc(func(r io.Reader) (map[string][]string, error) {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		values := make(map[string][]string)
+		for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			if k, v, found := strings.Cut(line, "="); !found {
+				return nil, fmt.Errorf("malformed line: %s", line)
+			} else {
+				values[k] = append(values[k], v)
+			}
+		}
+		return values, nil
+	})
+	type testCase struct {
+		decoders       map[string]ConfigDecoder
+		fileName       string
+		fileContents   string
+		expectedConfig []string
+		expectedError  string
+	}
+	testCases := map[string]testCase{
+		"JSON decoder is used by default": {
+			fileName:       "config.json",
+			fileContents:   `{"names":["a","b"]}`,
+			expectedConfig: []string{"a", "b"},
+		},
+		"JSON decoder is used for unregistered extensions": {
+			decoders:       map[string]ConfigDecoder{".kv": kvDecoder},
+			fileName:       "config.conf",
+			fileContents:   `{"names":["a"]}`,
+			expectedConfig: []string{"a"},
+		},
+		"decoder is chosen by extension": {
+			decoders:       map[string]ConfigDecoder{".kv": kvDecoder},
+			fileName:       "config.kv",
+			fileContents:   "names=a\nnames=b\n",
+			expectedConfig: []string{"a", "b"},
+		},
+		"extension is matched case-insensitively": {
+			decoders:       map[string]ConfigDecoder{".kv": kvDecoder},
+			fileName:       "config.KV",
+			fileContents:   "names=a\n",
+			expectedConfig: []string{"a"},
+		},
+		"default decoder can be replaced": {
+			decoders:       map[string]ConfigDecoder{"": kvDecoder},
+			fileName:       "config",
+			fileContents:   "names=a\n",
+			expectedConfig: []string{"a"},
+		},
+		"decoder error": {
+			decoders:      map[string]ConfigDecoder{".kv": kvDecoder},
+			fileName:      "config.kv",
+			fileContents:  "names\n",
+			expectedError: `^failed parsing config file '.+': malformed line: names$`,
+		},
+		"unknown flag from decoder": {
+			decoders:      map[string]ConfigDecoder{".kv": kvDecoder},
+			fileName:      "config.kv",
+			fileContents:  "nams=a\n",
+			expectedError: `^invalid config file '.+': unknown flag: --nams \(did you mean --names\?\)$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), tc.fileName)
+			With(t).Verify(os.WriteFile(file, []byte(tc.fileContents), 0600)).Will(Succeed()).OrFail()
+
+			parent, err := newFlagSet(nil, reflect.ValueOf(&ConfigFileConfig{}))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			parent.configFileFlagName = "config"
+			parent.configDecoders = tc.decoders
+
+			c := &struct {
+				Names []string `flag:"true"`
+			}{}
+			fs, err := newFlagSet(parent, reflect.ValueOf(c))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+
+			if tc.expectedError != "" {
+				With(t).Verify(fs.apply(io.Discard, nil, []string{"--config=" + file})).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(fs.apply(io.Discard, nil, []string{"--config=" + file})).Will(Succeed()).OrFail()
+				With(t).Verify(c.Names).Will(EqualTo(tc.expectedConfig)).OrFail()
+			}
+		})
+	}
+}
+
+func TestFlagSetApplyValueFromFile(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		envVars        map[string]string
+		args           []string
+		expectedToken  string
+		expectedTokens []string
+		expectedError  string
+	}
+	testCases := map[string]testCase{
+		"value read from file given in CLI": {
+			args:          []string{"--token=@{{file}}"},
+			expectedToken: "s3cr3t",
+		},
+		"value read from file given in environment variable": {
+			envVars:       map[string]string{"TOKEN": "@{{file}}"},
+			expectedToken: "s3cr3t",
+		},
+		"repeated values read from file": {
+			args:           []string{"--tokens=@{{file}}", "--tokens=t2"},
+			expectedTokens: []string{"s3cr3t", "t2"},
+		},
+		"escaped literal value": {
+			args:          []string{"--token=@@{{file}}"},
+			expectedToken: "@{{file}}",
+		},
+		"missing file": {
+			args:          []string{"--token=@/no/such/file"},
+			expectedError: `^invalid value "@/no/such/file" for flag -token: failed reading value of flag 'token' from file: open /no/such/file: no such file or directory$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			file := filepath.Join(t.TempDir(), "token")
+			With(t).Verify(os.WriteFile(file, []byte("s3cr3t\n"), 0600)).Will(Succeed()).OrFail()
+			for i, arg := range tc.args {
+				tc.args[i] = strings.ReplaceAll(arg, "{{file}}", file)
+			}
+			for k, v := range tc.envVars {
+				tc.envVars[k] = strings.ReplaceAll(v, "{{file}}", file)
+			}
+
+			config := &struct {
+				Token  string   `flag:"true"`
+				Tokens []string `flag:"true"`
+			}{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(config))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+
+			if tc.expectedError != "" {
+				With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(fs.apply(io.Discard, tc.envVars, tc.args)).Will(Succeed()).OrFail()
+				With(t).Verify(config.Token).Will(EqualTo(strings.ReplaceAll(tc.expectedToken, "{{file}}", file))).OrFail()
+				With(t).Verify(config.Tokens).Will(EqualTo(tc.expectedTokens)).OrFail()
+			}
+		})
+	}
+}
+
+func TestFlagSetApplyStdin(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		args          []string
+		expectedData  string
+		expectedName  string
+		expectedError string
+	}
+	testCases := map[string]testCase{
+		"value read from stdin": {
+			args:         []string{"--data=-"},
+			expectedData: "line1\nline2\n",
+		},
+		"regular value": {
+			args:         []string{"--data=v1"},
+			expectedData: "v1",
+		},
+		"dash for non-stdin flag is a regular value": {
+			args:         []string{"--data=-", "--name=-"},
+			expectedData: "line1\nline2\n",
+			expectedName: "-",
+		},
+		"stdin read by two flags": {
+			args:          []string{"--data=-", "--other=-"},
+			expectedError: `^invalid value "-" for flag -other: flag 'other' cannot read from stdin, since it was already read by flag 'data'$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			config := &struct {
+				Data  string `stdin:"true"`
+				Other string `stdin:"true"`
+				Name  string `flag:"true"`
+			}{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(config))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			fs.stdin = strings.NewReader("line1\nline2\n")
+
+			if tc.expectedError != "" {
+				With(t).Verify(fs.apply(io.Discard, nil, tc.args)).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(fs.apply(io.Discard, nil, tc.args)).Will(Succeed()).OrFail()
+				With(t).Verify(config.Data).Will(EqualTo(tc.expectedData)).OrFail()
+				With(t).Verify(config.Name).Will(EqualTo(tc.expectedName)).OrFail()
+			}
+		})
+	}
+}
+
+func TestFlagSetApplyShortFlagClusters(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		All     bool     `short:"a"`
+		Brief   bool     `short:"b"`
+		Verbose int      `short:"v" count:"true"`
+		Output  string   `short:"o"`
+		Abc     bool     `flag:"true"`
+		Args    []string `args:"true"`
+	}
+	type testCase struct {
+		args           []string
+		expectedConfig config
+		expectedError  string
+	}
+	testCases := map[string]testCase{
+		"boolean short flags": {
+			args:           []string{"-ab"},
+			expectedConfig: config{All: true, Brief: true, Args: []string{}},
+		},
+		"count short flags": {
+			args:           []string{"-vvv", "-av"},
+			expectedConfig: config{All: true, Verbose: 4, Args: []string{}},
+		},
+		"value-taking short flag last in cluster takes next argument": {
+			args:           []string{"-ao", "out.txt", "x"},
+			expectedConfig: config{All: true, Output: "out.txt", Args: []string{"x"}},
+		},
+		"value-taking short flag not last in cluster takes rest of cluster": {
+			args:           []string{"-aoab", "x"},
+			expectedConfig: config{All: true, Output: "ab", Args: []string{"x"}},
+		},
+		"value-taking short flag with attached value": {
+			args:           []string{"-oout.txt"},
+			expectedConfig: config{Output: "out.txt", Args: []string{}},
+		},
+		"single-dash long flag is not expanded": {
+			args:           []string{"-abc"},
+			expectedConfig: config{Abc: true, Args: []string{}},
+		},
+		"positionals are not expanded": {
+			args:           []string{"x", "-ab"},
+			expectedConfig: config{Args: []string{"x", "-ab"}},
+		},
+		"unknown short flag in cluster": {
+			args:          []string{"-axb"},
+			expectedError: `^unknown flag: --axb \(did you mean --abc\?\)$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(cfg))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			if tc.expectedError != "" {
+				With(t).Verify(fs.apply(io.Discard, nil, tc.args)).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(fs.apply(io.Discard, nil, tc.args)).Will(Succeed()).OrFail()
+				With(t).Verify(*cfg).Will(EqualTo(tc.expectedConfig)).OrFail()
+			}
+		})
+	}
+}
+
+func TestFlagSetApplyPrompts(t *testing.T) {
+	t.Parallel()
+	type config struct {
+		Token string `required:"true" prompt:"Enter your API token" secret:"true"`
+		Name  string `required:"true"`
+	}
+	type testCase struct {
+		promptMissing  bool
+		interactive    bool
+		args           []string
+		input          string
+		expectedConfig config
+		expectedOutput string
+		expectedError  string
+	}
+	testCases := map[string]testCase{
+		"prompting disabled": {
+			interactive:   true,
+			input:         "t1\nn1\n",
+			expectedError: `^required flag is missing: --name$`,
+		},
+		"non-interactive stdin": {
+			promptMissing: true,
+			input:         "t1\nn1\n",
+			expectedError: `^required flag is missing: --name$`,
+		},
+		"prompts for missing flags": {
+			promptMissing:  true,
+			interactive:    true,
+			input:          "n1\nt1\r\n",
+			expectedConfig: config{Token: "t1", Name: "n1"},
+			expectedOutput: "Enter a value for --name: Enter your API token: ",
+		},
+		"only missing flags are prompted for": {
+			promptMissing:  true,
+			interactive:    true,
+			args:           []string{"--name=n1"},
+			input:          "t1\n",
+			expectedConfig: config{Token: "t1", Name: "n1"},
+			expectedOutput: "Enter your API token: ",
+		},
+		"empty value leaves flag missing": {
+			promptMissing:  true,
+			interactive:    true,
+			input:          "\nt1\n",
+			expectedOutput: "Enter a value for --name: Enter your API token: ",
+			expectedError:  `^required flag is missing: --name$`,
+		},
+		"end of input leaves flag missing": {
+			promptMissing:  true,
+			interactive:    true,
+			input:          "n1\n",
+			expectedOutput: "Enter a value for --name: Enter your API token: ",
+			expectedError:  `^required flag is missing: --token$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := &config{}
+			fs, err := newFlagSet(nil, reflect.ValueOf(cfg))
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			fs.stdin = strings.NewReader(tc.input)
+			fs.promptMissing = tc.promptMissing
+			fs.interactive = &tc.interactive
+
+			b := &bytes.Buffer{}
+			if tc.expectedError != "" {
+				With(t).Verify(fs.apply(b, nil, tc.args)).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(fs.apply(b, nil, tc.args)).Will(Succeed()).OrFail()
+				With(t).Verify(*cfg).Will(EqualTo(tc.expectedConfig)).OrFail()
+				With(t).Verify(fs.valueSources["token"]).Will(EqualTo(ValueSourceCLI)).OrFail()
+			}
+			With(t).Verify(b.String()).Will(EqualTo(tc.expectedOutput)).OrFail()
+		})
+	}
+}
+
+func TestFlagSetUsagePrintingColor(t *testing.T) {
+	t.Parallel()
+	fs, err := newFlagSet(nil, reflect.ValueOf(&struct {
+		Name    string `required:"true"`
+		Verbose bool   `desc:"Verbose output."`
+	}{}))
+	With(t).Verify(err).Will(BeNil()).OrFail()
+
+	ww, err := NewWrappingWriter(100)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	With(t).Verify(fs.printFlagsMultiLine(ww, "", "", true)).Will(Succeed()).OrFail()
+	With(t).Verify(ww.String()).Will(EqualTo("" +
+		"\x1b[1;33m--name=VALUE\x1b[0m  environment variable: NAME\n" +
+		"\x1b[1m[--verbose]\x1b[0m   Verbose output. (default value: false, environment variable: VERBOSE)\n")).OrFail()
+}
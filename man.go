@@ -0,0 +1,133 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// GenManPage writes a man page (in troff format) for this command to the given writer, in the given manual section
+// (e.g. 1 for general commands). The page contains the NAME, SYNOPSIS, DESCRIPTION, OPTIONS & COMMANDS sections.
+func (c *Command) GenManPage(w io.Writer, section int) error {
+	if section < 1 || section > 9 {
+		return fmt.Errorf("invalid man page section %d: must be between 1 and 9", section)
+	}
+
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	fullName := c.getFullName()
+	pageName := strings.ReplaceAll(fullName, " ", "-")
+
+	b := &bytes.Buffer{}
+	_, _ = fmt.Fprintf(b, ".TH \"%s\" \"%d\"\n", manEscape(strings.ToUpper(pageName)), section)
+
+	// Name & short description
+	_, _ = fmt.Fprintln(b, ".SH NAME")
+	_, _ = fmt.Fprintf(b, "%s \\- %s\n", manEscape(pageName), manEscape(c.shortDescription))
+
+	// Synopsis
+	synopsis := &bytes.Buffer{}
+	if err := c.flags.printFlagsSingleLine(synopsis); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b, ".SH SYNOPSIS")
+	_, _ = fmt.Fprintf(b, ".B %s\n", manEscape(fullName))
+	if synopsis.Len() > 0 {
+		_, _ = fmt.Fprintln(b, manEscapeLine(synopsis.String()))
+	}
+
+	// Description (falling back to the short description if there's no long description)
+	description := c.longDescription
+	if description == "" {
+		description = c.shortDescription
+	}
+	_, _ = fmt.Fprintln(b, ".SH DESCRIPTION")
+	for _, line := range strings.Split(strings.TrimSpace(description), "\n") {
+		if strings.TrimSpace(line) == "" {
+			_, _ = fmt.Fprintln(b, ".PP")
+		} else {
+			_, _ = fmt.Fprintln(b, manEscapeLine(line))
+		}
+	}
+
+	// Options
+	if len(mergedFlagDefs) > 0 {
+		_, _ = fmt.Fprintln(b, ".SH OPTIONS")
+		for _, mfd := range mergedFlagDefs {
+			var flagName string
+			if mfd.Short != nil {
+				flagName = fmt.Sprintf("\\fB\\-%s\\fR, ", manEscape(*mfd.Short))
+			}
+			flagName += fmt.Sprintf("\\fB\\-\\-%s\\fR", manEscape(mfd.Name))
+			if valueName := mfd.getValueName(); valueName != "" {
+				flagName += fmt.Sprintf("=\\fI%s\\fR", manEscape(valueName))
+			}
+			_, _ = fmt.Fprintln(b, ".TP")
+			_, _ = fmt.Fprintln(b, flagName)
+
+			var details []string
+			if mfd.Description != nil && *mfd.Description != "" {
+				details = append(details, *mfd.Description)
+			}
+			if mfd.isRequired() {
+				details = append(details, "Required.")
+			}
+			if len(mfd.Choices) > 0 {
+				details = append(details, "One of: "+strings.Join(mfd.Choices, ", "))
+			}
+			if mfd.DefaultValue != "" {
+				details = append(details, "Default value: "+mfd.DefaultValue)
+			}
+			if mfd.EnvVarName != nil {
+				if len(mfd.EnvFallbacks) > 0 {
+					details = append(details, "Environment variables: "+strings.Join(mfd.getEnvVarNames(), ", "))
+				} else {
+					details = append(details, "Environment variable: "+*mfd.EnvVarName)
+				}
+			}
+			if mfd.EnvPrefix != nil {
+				details = append(details, "Environment variables: "+*mfd.EnvPrefix+"*")
+			}
+			for i, detail := range details {
+				if i > 0 {
+					_, _ = fmt.Fprintln(b, ".br")
+				}
+				_, _ = fmt.Fprintln(b, manEscapeLine(strings.ReplaceAll(detail, "\n", " ")))
+			}
+		}
+	}
+
+	// Sub-commands
+	if subCommands := c.getVisibleSubCommands(); len(subCommands) > 0 {
+		_, _ = fmt.Fprintln(b, ".SH COMMANDS")
+		for _, subCmd := range subCommands {
+			_, _ = fmt.Fprintln(b, ".TP")
+			_, _ = fmt.Fprintf(b, "\\fB%s\\fR\n", manEscape(strings.Join(subCmd.getNames(), ", ")))
+			_, _ = fmt.Fprintln(b, manEscapeLine(subCmd.shortDescription))
+		}
+	}
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// manEscape escapes the given text so that troff renders it verbatim.
+func manEscape(s string) string {
+	return strings.NewReplacer(`\`, `\e`, `-`, `\-`).Replace(s)
+}
+
+// manEscapeLine escapes the given text line so that troff renders it verbatim, also ensuring it's not interpreted as a
+// troff request (which would happen if it started with a "." or a "'").
+func manEscapeLine(s string) string {
+	s = manEscape(s)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/arikkfir/justest"
+)
+
+func TestGenManPage(t *testing.T) {
+	t.Parallel()
+
+	root := MustNew(
+		"my-cmd", "Does things.", "This command does things.\n\n.It does them well.",
+		&struct {
+			Action
+			Verbose bool   `short:"v" desc:"Verbose output."`
+			Level   string `env:"LEVEL" choices:"low,high" desc:"Level of things."`
+			Name    string `value-name:"NAME" required:"true" desc:"Name of the thing."`
+		}{Level: "low"},
+		nil,
+		MustNew("sub1", "sub1 desc", "", nil, nil),
+	)
+
+	b := &bytes.Buffer{}
+	With(t).Verify(root.GenManPage(b, 1)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`
+.TH "MY\-CMD" "1"
+.SH NAME
+my\-cmd \- Does things.
+.SH SYNOPSIS
+.B my\-cmd
+[\-h, \-\-help] [\-\-level=VALUE] \-\-name=NAME [\-v, \-\-verbose]
+.SH DESCRIPTION
+This command does things.
+.PP
+\&.It does them well.
+.SH OPTIONS
+.TP
+\fB\-h\fR, \fB\-\-help\fR
+Show this help screen and exit.
+.br
+Default value: false
+.br
+Environment variable: HELP
+.TP
+\fB\-\-level\fR=\fIVALUE\fR
+Level of things.
+.br
+One of: low, high
+.br
+Default value: low
+.br
+Environment variable: LEVEL
+.TP
+\fB\-\-name\fR=\fINAME\fR
+Name of the thing.
+.br
+Required.
+.br
+Environment variable: NAME
+.TP
+\fB\-v\fR, \fB\-\-verbose\fR
+Verbose output.
+.br
+Default value: false
+.br
+Environment variable: VERBOSE
+.SH COMMANDS
+.TP
+\fBsub1\fR
+sub1 desc
+`[1:])).OrFail()
+}
+
+func TestGenManPageInvalidSection(t *testing.T) {
+	t.Parallel()
+	cmd := MustNew("my-cmd", "Does things.", "", nil, nil)
+	With(t).Verify(cmd.GenManPage(&bytes.Buffer{}, 0)).Will(Fail(`invalid man page section 0: must be between 1 and 9`)).OrFail()
+}
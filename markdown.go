@@ -0,0 +1,143 @@
+package command
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var nonAnchorCharsRE = regexp.MustCompile(`[^a-z0-9_-]`)
+
+// GenMarkdown writes Markdown documentation for this command and all of its sub-commands (recursively) to the given
+// writer. Each command gets its own section, containing its descriptions, usage line, flags table and links to its
+// parent & sub-commands sections.
+func (c *Command) GenMarkdown(w io.Writer) error {
+	b := &bytes.Buffer{}
+	if err := c.genMarkdownSection(b, c); err != nil {
+		return err
+	}
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// genMarkdownSection writes the Markdown section of this command, followed by the sections of all of its sub-commands.
+// The given top command is the command documentation generation started from - parent links are only generated for
+// commands below it.
+func (c *Command) genMarkdownSection(b *bytes.Buffer, top *Command) error {
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	fullName := c.getFullName()
+	if c != top {
+		_, _ = fmt.Fprintln(b)
+	}
+	_, _ = fmt.Fprintf(b, "## %s\n", fullName)
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, c.shortDescription)
+	if c.longDescription != "" {
+		_, _ = fmt.Fprintln(b)
+		_, _ = fmt.Fprintln(b, c.longDescription)
+	}
+	if c != top && c.parent != nil {
+		_, _ = fmt.Fprintln(b)
+		_, _ = fmt.Fprintf(b, "Parent command: [%s](#%s)\n", c.parent.getFullName(), markdownAnchor(c.parent.getFullName()))
+	}
+
+	// Usage
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, "**Usage:**")
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, "```")
+	_, _ = fmt.Fprint(b, fullName+" ")
+	if err := c.flags.printFlagsSingleLine(b); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(b)
+	_, _ = fmt.Fprintln(b, "```")
+
+	// Flags
+	if len(mergedFlagDefs) > 0 {
+		_, _ = fmt.Fprintln(b)
+		_, _ = fmt.Fprintln(b, "**Flags:**")
+		_, _ = fmt.Fprintln(b)
+		_, _ = fmt.Fprintln(b, "| Name | Env | Default | Required | Description |")
+		_, _ = fmt.Fprintln(b, "|------|-----|---------|----------|-------------|")
+		for _, mfd := range mergedFlagDefs {
+			name := "--" + mfd.Name
+			if valueName := mfd.getValueName(); valueName != "" {
+				name += "=" + valueName
+			}
+			if mfd.Short != nil {
+				name = "-" + *mfd.Short + ", " + name
+			}
+			var env, defaultValue, required string
+			if mfd.EnvVarName != nil {
+				var names []string
+				for _, name := range mfd.getEnvVarNames() {
+					names = append(names, markdownCode(name))
+				}
+				env = strings.Join(names, ", ")
+			}
+			if mfd.EnvPrefix != nil {
+				if env != "" {
+					env += ", "
+				}
+				env += markdownCode(*mfd.EnvPrefix + "*")
+			}
+			if mfd.DefaultValue != "" {
+				defaultValue = markdownCode(mfd.DefaultValue)
+			}
+			if mfd.isRequired() {
+				required = "Yes"
+			} else {
+				required = "No"
+			}
+			_, _ = fmt.Fprintf(b, "| %s | %s | %s | %s | %s |\n",
+				markdownCode(name), env, defaultValue, required, markdownTableCell(defaultIfNil(mfd.Description, "")))
+		}
+	}
+
+	// Sub-commands
+	subCommands := c.getVisibleSubCommands()
+	if len(subCommands) > 0 {
+		_, _ = fmt.Fprintln(b)
+		_, _ = fmt.Fprintln(b, "**Sub-commands:**")
+		_, _ = fmt.Fprintln(b)
+		for _, subCmd := range subCommands {
+			subCmdFullName := subCmd.getFullName()
+			_, _ = fmt.Fprintf(b, "- [%s](#%s): %s", subCmdFullName, markdownAnchor(subCmdFullName), subCmd.shortDescription)
+			if len(subCmd.aliases) > 0 {
+				_, _ = fmt.Fprintf(b, " (aliases: %s)", strings.Join(subCmd.aliases, ", "))
+			}
+			_, _ = fmt.Fprintln(b)
+		}
+	}
+
+	for _, subCmd := range subCommands {
+		if err := subCmd.genMarkdownSection(b, top); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markdownAnchor returns the anchor generated (by GitHub & most Markdown renderers) for a heading with the given text.
+func markdownAnchor(heading string) string {
+	return nonAnchorCharsRE.ReplaceAllString(strings.ReplaceAll(strings.ToLower(heading), " ", "-"), "")
+}
+
+// markdownCode renders the given text as inline code, safe for use inside a Markdown table cell.
+func markdownCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "|", `\|`) + "`"
+}
+
+// markdownTableCell renders the given text so that it's safe for use inside a Markdown table cell.
+func markdownTableCell(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "|", `\|`), "\n", " ")
+}
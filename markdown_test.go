@@ -0,0 +1,102 @@
+package command
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/arikkfir/justest"
+)
+
+func TestGenMarkdown(t *testing.T) {
+	t.Parallel()
+
+	root := MustNew(
+		"my-cmd", "Does things.", "This command does things.",
+		&struct {
+			Action
+			Verbose bool   `short:"v" desc:"Verbose output."`
+			Name    string `value-name:"NAME" required:"true" desc:"Name of the thing (a|b)."`
+		}{},
+		nil,
+		MustNew(
+			"sub1", "sub1 desc", "", nil, nil,
+			MustNew("sub2", "sub2 desc", "", &struct {
+				Action
+				Force bool `inherited:"true" desc:"Force it."`
+			}{}, nil),
+		),
+	)
+
+	b := &bytes.Buffer{}
+	With(t).Verify(root.GenMarkdown(b)).Will(Succeed()).OrFail()
+
+	// Backticks cannot appear in raw string literals, so the expected output uses single quotes in their place
+	expected := strings.ReplaceAll(`
+## my-cmd
+
+Does things.
+
+This command does things.
+
+**Usage:**
+
+'''
+my-cmd [-h, --help] --name=NAME [-v, --verbose]
+'''
+
+**Flags:**
+
+| Name | Env | Default | Required | Description |
+|------|-----|---------|----------|-------------|
+| '-h, --help' | 'HELP' | 'false' | No | Show this help screen and exit. |
+| '--name=NAME' | 'NAME' |  | Yes | Name of the thing (a\|b). |
+| '-v, --verbose' | 'VERBOSE' | 'false' | No | Verbose output. |
+
+**Sub-commands:**
+
+- [my-cmd sub1](#my-cmd-sub1): sub1 desc
+
+## my-cmd sub1
+
+sub1 desc
+
+Parent command: [my-cmd](#my-cmd)
+
+**Usage:**
+
+'''
+my-cmd sub1 [-h, --help]
+'''
+
+**Flags:**
+
+| Name | Env | Default | Required | Description |
+|------|-----|---------|----------|-------------|
+| '-h, --help' | 'HELP' | 'false' | No | Show this help screen and exit. |
+
+**Sub-commands:**
+
+- [my-cmd sub1 sub2](#my-cmd-sub1-sub2): sub2 desc
+
+## my-cmd sub1 sub2
+
+sub2 desc
+
+Parent command: [my-cmd sub1](#my-cmd-sub1)
+
+**Usage:**
+
+'''
+my-cmd sub1 sub2 [--force] [-h, --help]
+'''
+
+**Flags:**
+
+| Name | Env | Default | Required | Description |
+|------|-----|---------|----------|-------------|
+| '--force' | 'FORCE' | 'false' | No | Force it. |
+| '-h, --help' | 'HELP' | 'false' | No | Show this help screen and exit. |
+`[1:], "'", "`")
+	With(t).Verify(b.String()).Will(EqualTo(expected)).OrFail()
+}
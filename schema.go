@@ -0,0 +1,155 @@
+package command
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// SchemaVersion is the version of the JSON schema written by [Command.DumpSchema]. It is incremented whenever the schema
+// changes in a backwards-incompatible way.
+const SchemaVersion = 1
+
+type schemaDocument struct {
+	SchemaVersion int           `json:"schemaVersion"`
+	Command       commandSchema `json:"command"`
+}
+
+type commandSchema struct {
+	Name             string             `json:"name"`
+	Aliases          []string           `json:"aliases,omitempty"`
+	ShortDescription string             `json:"shortDescription"`
+	LongDescription  string             `json:"longDescription,omitempty"`
+	Hidden           bool               `json:"hidden,omitempty"`
+	Deprecated       string             `json:"deprecated,omitempty"`
+	Flags            []flagSchema       `json:"flags"`
+	Positionals      *positionalsSchema `json:"positionals,omitempty"`
+	SubCommands      []commandSchema    `json:"subCommands"`
+}
+
+type flagSchema struct {
+	Name         string   `json:"name"`
+	Short        string   `json:"short,omitempty"`
+	Env          string   `json:"env,omitempty"`
+	EnvFallbacks []string `json:"envFallbacks,omitempty"`
+	EnvPrefix    string   `json:"envPrefix,omitempty"`
+	ValueName    string   `json:"valueName,omitempty"`
+	Type         string   `json:"type"`
+	Required     bool     `json:"required"`
+	Default      string   `json:"default,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Choices      []string `json:"choices,omitempty"`
+	Hidden       bool     `json:"hidden,omitempty"`
+	Deprecated   string   `json:"deprecated,omitempty"`
+}
+
+type positionalsSchema struct {
+	Names []string `json:"names,omitempty"`
+	Min   int      `json:"min"`
+	Max   int      `json:"max"` // -1 for no maximum
+}
+
+type helpSummary struct {
+	Command     string             `json:"command"`
+	Flags       []flagSchema       `json:"flags"`
+	Positionals *positionalsSchema `json:"positionals,omitempty"`
+}
+
+// PrintHelpJSON writes a JSON summary of the flags (including inherited ones) & positional arguments accepted by this
+// command alone, which is a lighter alternative to [Command.DumpSchema] for tools & wrapper scripts (e.g. to build an
+// interactive prompt for the command resolved by [Command.Resolve]). Hidden flags are omitted.
+func (c *Command) PrintHelpJSON(w io.Writer) error {
+	mergedFlagDefs, err := c.flags.getVisibleMergedFlagDefs()
+	if err != nil {
+		return err
+	}
+
+	summary := helpSummary{Command: c.getFullName(), Flags: []flagSchema{}, Positionals: c.getPositionalsSchema()}
+	for _, mfd := range mergedFlagDefs {
+		summary.Flags = append(summary.Flags, newFlagSchema(mfd))
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+// DumpSchema writes a JSON description of this command and all of its sub-commands (recursively) to the given writer,
+// including their descriptions, flags & positional arguments. Hidden commands & flags are included as well (marked as
+// such), so the schema can be used by external tooling (e.g. GUIs). The document's "schemaVersion" field holds
+// [SchemaVersion].
+func (c *Command) DumpSchema(w io.Writer) error {
+	cs, err := c.getSchema()
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(schemaDocument{SchemaVersion: SchemaVersion, Command: *cs})
+}
+
+// getSchema returns the schema of this command and all of its sub-commands.
+func (c *Command) getSchema() (*commandSchema, error) {
+	mergedFlagDefs, err := c.flags.getMergedFlagDefs()
+	if err != nil {
+		return nil, err
+	}
+
+	cs := &commandSchema{
+		Name:             c.name,
+		Aliases:          c.aliases,
+		ShortDescription: c.shortDescription,
+		LongDescription:  c.longDescription,
+		Hidden:           c.hidden,
+		Deprecated:       c.deprecated,
+		Flags:            []flagSchema{},
+		Positionals:      c.getPositionalsSchema(),
+		SubCommands:      []commandSchema{},
+	}
+	for _, mfd := range mergedFlagDefs {
+		cs.Flags = append(cs.Flags, newFlagSchema(mfd))
+	}
+	for _, subCmd := range c.subCommands {
+		if subSchema, err := subCmd.getSchema(); err != nil {
+			return nil, err
+		} else {
+			cs.SubCommands = append(cs.SubCommands, *subSchema)
+		}
+	}
+	return cs, nil
+}
+
+// newFlagSchema returns the schema of the given flag.
+func newFlagSchema(mfd *mergedFlagDef) flagSchema {
+	return flagSchema{
+		Name:         mfd.Name,
+		Short:        defaultIfNil(mfd.Short, ""),
+		Env:          defaultIfNil(mfd.EnvVarName, ""),
+		EnvFallbacks: mfd.EnvFallbacks,
+		EnvPrefix:    defaultIfNil(mfd.EnvPrefix, ""),
+		ValueName:    mfd.getValueName(),
+		Type:         mfd.flagDefs[0].Targets[0].Type().String(),
+		Required:     mfd.isRequired(),
+		Default:      mfd.DefaultValue,
+		Description:  defaultIfNil(mfd.Description, ""),
+		Choices:      mfd.Choices,
+		Hidden:       mfd.isHidden(),
+		Deprecated:   defaultIfNil(mfd.Deprecated, ""),
+	}
+}
+
+// getPositionalsSchema returns the schema of the positional arguments this command accepts, or nil if it accepts none.
+func (c *Command) getPositionalsSchema() *positionalsSchema {
+	if spec := c.flags.positionalsSpec; spec != nil {
+		return &positionalsSchema{Names: spec.names, Min: spec.min, Max: spec.max}
+	} else if len(c.flags.positionalTargets) == 0 && len(c.flags.positionalsTargets) == 0 && len(c.flags.typedPositionalsTargets) == 0 {
+		return nil
+	}
+
+	ps := &positionalsSchema{Max: len(c.flags.positionalTargets)}
+	for _, target := range c.flags.positionalTargets {
+		ps.Names = append(ps.Names, target.Name)
+	}
+	if len(c.flags.positionalsTargets) > 0 || len(c.flags.typedPositionalsTargets) > 0 {
+		ps.Max = -1
+	}
+	return ps
+}
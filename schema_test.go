@@ -0,0 +1,223 @@
+package command
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/arikkfir/justest"
+)
+
+func TestDumpSchema(t *testing.T) {
+	t.Parallel()
+
+	sub := MustNew("sub", "Sub command.", "", &struct {
+		Action
+		Count int      `arg:"COUNT"`
+		Args  []string `args:"true"`
+	}{}, nil)
+	_ = sub.SetAliases("s")
+	old := MustNew("old", "Old command.", "", nil, nil)
+	old.SetHidden(true)
+	old.SetDeprecated("use 'sub' instead")
+	root := MustNew(
+		"my-cmd", "Does things.", "This command does things.",
+		&struct {
+			Action
+			Verbose bool   `short:"v" desc:"Verbose output." inherited:"true"`
+			Format  string `choices:"json,yaml" required:"true"`
+			Secret  string `env:"-" hidden:"true"`
+		}{Format: "json"},
+		nil,
+		sub,
+		old,
+	)
+	With(t).Verify(root.SetPositionalSpec([]string{"SRC", "DST"}, 1, 2)).Will(Succeed()).OrFail()
+
+	b := &bytes.Buffer{}
+	With(t).Verify(root.DumpSchema(b)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`
+{
+  "schemaVersion": 1,
+  "command": {
+    "name": "my-cmd",
+    "shortDescription": "Does things.",
+    "longDescription": "This command does things.",
+    "flags": [
+      {
+        "name": "format",
+        "env": "FORMAT",
+        "valueName": "VALUE",
+        "type": "string",
+        "required": true,
+        "default": "json",
+        "choices": [
+          "json",
+          "yaml"
+        ]
+      },
+      {
+        "name": "help",
+        "short": "h",
+        "env": "HELP",
+        "type": "bool",
+        "required": false,
+        "default": "false",
+        "description": "Show this help screen and exit."
+      },
+      {
+        "name": "secret",
+        "valueName": "VALUE",
+        "type": "string",
+        "required": false,
+        "hidden": true
+      },
+      {
+        "name": "verbose",
+        "short": "v",
+        "env": "VERBOSE",
+        "type": "bool",
+        "required": false,
+        "default": "false",
+        "description": "Verbose output."
+      }
+    ],
+    "positionals": {
+      "names": [
+        "SRC",
+        "DST"
+      ],
+      "min": 1,
+      "max": 2
+    },
+    "subCommands": [
+      {
+        "name": "sub",
+        "aliases": [
+          "s"
+        ],
+        "shortDescription": "Sub command.",
+        "flags": [
+          {
+            "name": "help",
+            "short": "h",
+            "env": "HELP",
+            "type": "bool",
+            "required": false,
+            "default": "false",
+            "description": "Show this help screen and exit."
+          },
+          {
+            "name": "verbose",
+            "short": "v",
+            "env": "VERBOSE",
+            "type": "bool",
+            "required": false,
+            "default": "false",
+            "description": "Verbose output."
+          }
+        ],
+        "positionals": {
+          "names": [
+            "COUNT"
+          ],
+          "min": 0,
+          "max": -1
+        },
+        "subCommands": []
+      },
+      {
+        "name": "old",
+        "shortDescription": "Old command.",
+        "hidden": true,
+        "deprecated": "use 'sub' instead",
+        "flags": [
+          {
+            "name": "help",
+            "short": "h",
+            "env": "HELP",
+            "type": "bool",
+            "required": false,
+            "default": "false",
+            "description": "Show this help screen and exit."
+          },
+          {
+            "name": "verbose",
+            "short": "v",
+            "env": "VERBOSE",
+            "type": "bool",
+            "required": false,
+            "default": "false",
+            "description": "Verbose output."
+          }
+        ],
+        "subCommands": []
+      }
+    ]
+  }
+}
+`[1:])).OrFail()
+}
+
+func TestPrintHelpJSON(t *testing.T) {
+	t.Parallel()
+
+	sub := MustNew("sub", "Sub command.", "", &struct {
+		Action
+		Output string   `short:"o" value-name:"FILE" required:"true" desc:"Output file."`
+		Secret string   `hidden:"true"`
+		Src    string   `arg:"SRC"`
+		Args   []string `args:"true"`
+	}{}, nil)
+	root := MustNew("my-cmd", "Does things.", "", &struct {
+		Action
+		Verbose bool `short:"v" desc:"Verbose output." inherited:"true"`
+		Level   int  `desc:"Not inherited."`
+	}{}, nil, sub)
+
+	cmd, err := root.Resolve([]string{"sub", "-o", "out.txt"}, nil)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+
+	b := &bytes.Buffer{}
+	With(t).Verify(cmd.PrintHelpJSON(b)).Will(Succeed()).OrFail()
+	With(t).Verify(b.String()).Will(EqualTo(`
+{
+  "command": "my-cmd sub",
+  "flags": [
+    {
+      "name": "help",
+      "short": "h",
+      "env": "HELP",
+      "type": "bool",
+      "required": false,
+      "default": "false",
+      "description": "Show this help screen and exit."
+    },
+    {
+      "name": "output",
+      "short": "o",
+      "env": "OUTPUT",
+      "valueName": "FILE",
+      "type": "string",
+      "required": true,
+      "description": "Output file."
+    },
+    {
+      "name": "verbose",
+      "short": "v",
+      "env": "VERBOSE",
+      "type": "bool",
+      "required": false,
+      "default": "false",
+      "description": "Verbose output."
+    }
+  ],
+  "positionals": {
+    "names": [
+      "SRC"
+    ],
+    "min": 0,
+    "max": -1
+  }
+}
+`[1:])).OrFail()
+}
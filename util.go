@@ -2,13 +2,43 @@ package command
 
 import (
 	"fmt"
+	"io"
 	"os"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
+)
 
-	"golang.org/x/sys/unix"
+// ANSI escape sequences used for colorizing help screens.
+const (
+	ansiBold       = "\x1b[1m"
+	ansiBoldYellow = "\x1b[1;33m"
+	ansiReset      = "\x1b[0m"
 )
 
+// colorize wraps the given text with the given ANSI escape sequence (and a reset sequence), if enabled.
+func colorize(s, style string, enabled bool) string {
+	if !enabled {
+		return s
+	}
+	return style + s + ansiReset
+}
+
+// shouldColorize returns whether output written to the given writer should be colorized, which is the case if it's a
+// terminal, and the "NO_COLOR" environment variable is not set (see https://no-color.org).
+func shouldColorize(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return isTerminal(w)
+}
+
+// defaultTerminalWidth is the width used when the terminal's width cannot be detected (e.g. when output is piped), and
+// the "COLUMNS" environment variable is not set to a valid width either.
+const defaultTerminalWidth = 80
+
 func ptrOf[T any](v T) *T {
 	return &v
 }
@@ -27,6 +57,75 @@ func intForBool(b bool) int {
 	return 0
 }
 
+func isIntegerKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+// isStringOrStringSlice returns true if the given type is a string or a slice of strings.
+func isStringOrStringSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.String
+}
+
+// upperCaseKeys returns a copy of the given map with upper-cased keys. If several keys only differ by case, the value of
+// the lexicographically smallest key (i.e. the most upper-cased one) is used.
+func upperCaseKeys(m map[string]string) map[string]string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	result := make(map[string]string, len(m))
+	for _, k := range keys {
+		uk := strings.ToUpper(k)
+		if _, ok := result[uk]; !ok {
+			result[uk] = m[k]
+		}
+	}
+	return result
+}
+
+// levenshteinDistance returns the edit distance between the two given strings, i.e. the minimal number of single
+// character insertions, deletions or substitutions required to change one into the other.
+func levenshteinDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
+
+// findClosest returns the candidate closest to the given name (by edit distance), as long as its distance is within
+// the given maximum distance; otherwise, an empty string is returned. Ties are resolved in favor of earlier candidates.
+func findClosest(name string, candidates []string, maxDistance int) string {
+	closest, closestDistance := "", maxDistance+1
+	for _, candidate := range candidates {
+		if d := levenshteinDistance(name, candidate); d < closestDistance {
+			closest, closestDistance = candidate, d
+		}
+	}
+	return closest
+}
+
 func fieldNameToFlagName(fieldName string) string {
 	var result []rune
 	for i, r := range fieldName {
@@ -86,11 +185,131 @@ func EnvVarsArrayToMap(envVars []string) map[string]string {
 	return envVarsMap
 }
 
-func getTerminalWidth() int {
-	fd := int(os.Stdout.Fd())
-	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+// LoadDotEnv reads the given dotenv file, and returns the environment variables it defines. The returned map can be
+// merged into the environment variables map given to [Execute] or [ExecuteWithContext].
+//
+// Each line in the file should be of the form "KEY=VALUE", optionally prefixed by "export ". Blank lines and lines
+// starting with "#" are ignored. Values can be double-quoted (supporting escape sequences such as "\n" and "\""),
+// single-quoted (taken literally), or unquoted (in which case a trailing " # comment" is stripped).
+//
+//goland:noinspection GoUnusedExportedFunction
+func LoadDotEnv(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return 80
+		return nil, fmt.Errorf("failed reading dotenv file: %w", err)
+	}
+
+	envVars := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, err := parseDotEnvLine(line); err != nil {
+			return nil, fmt.Errorf("failed parsing dotenv file '%s' at line %d: %w", path, i+1, err)
+		} else {
+			envVars[key] = value
+		}
+	}
+	return envVars, nil
+}
+
+// parseDotEnvLine parses a single (non-blank, non-comment) dotenv line into its key & value.
+func parseDotEnvLine(line string) (string, string, error) {
+	if rest, found := strings.CutPrefix(line, "export"); found && rest != "" && unicode.IsSpace(rune(rest[0])) {
+		line = strings.TrimSpace(rest)
+	}
+
+	key, value, found := strings.Cut(line, "=")
+	if !found {
+		return "", "", fmt.Errorf("expected KEY=VALUE")
+	}
+	key = strings.TrimSpace(key)
+	if key == "" || strings.IndexFunc(key, func(r rune) bool { return unicode.IsSpace(r) || r == '"' || r == '\'' }) >= 0 {
+		return "", "", fmt.Errorf("invalid key '%s'", key)
+	}
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return key, "", nil
+	}
+
+	var rest string
+	switch value[0] {
+	case '"':
+		var b strings.Builder
+		closed := false
+		for i := 1; i < len(value) && !closed; i++ {
+			switch c := value[i]; {
+			case c == '"':
+				closed = true
+				rest = value[i+1:]
+			case c == '\\' && i+1 < len(value):
+				i++
+				switch e := value[i]; e {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				default:
+					b.WriteByte(e)
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+		if !closed {
+			return "", "", fmt.Errorf("unterminated double-quoted value")
+		}
+		value = b.String()
+	case '\'':
+		end := strings.IndexByte(value[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated single-quoted value")
+		}
+		rest = value[end+2:]
+		value = value[1 : end+1]
+	default:
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		return key, value, nil
+	}
+
+	// Only whitespace and a comment may follow a quoted value
+	if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", "", fmt.Errorf("unexpected characters after quoted value: %s", rest)
+	}
+	return key, value, nil
+}
+
+// getFallbackTerminalWidth returns the width to use when the terminal's width cannot be detected: the value of the
+// "COLUMNS" environment variable if it's a positive integer, or [defaultTerminalWidth] otherwise.
+func getFallbackTerminalWidth() int {
+	if width, err := strconv.Atoi(strings.TrimSpace(os.Getenv("COLUMNS"))); err == nil && width > 0 {
+		return width
+	}
+	return defaultTerminalWidth
+}
+
+// readLine reads a single line from the given reader (without the line terminator), reading one byte at a time so that
+// nothing beyond the line is consumed. An [io.EOF] error is returned if the reader is exhausted before anything is read.
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+	b := make([]byte, 1)
+	for {
+		if n, err := r.Read(b); n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		} else if err == io.EOF && len(line) > 0 {
+			break
+		} else if err != nil {
+			return "", err
+		}
 	}
-	return int(ws.Col)
+	return strings.TrimSuffix(string(line), "\r"), nil
 }
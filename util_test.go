@@ -1,6 +1,9 @@
 package command
 
 import (
+	"bytes"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/arikkfir/justest"
@@ -47,3 +50,170 @@ func TestFieldNameToEnvVarName(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadDotEnv(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		contents        string
+		expectedEnvVars map[string]string
+		expectedError   string
+	}
+	testCases := map[string]testCase{
+		"empty file": {
+			contents:        "",
+			expectedEnvVars: map[string]string{},
+		},
+		"simple values, comments & blank lines": {
+			contents:        "# comment\n\nK1=v1\n  K2 = v2  \nK3=\n",
+			expectedEnvVars: map[string]string{"K1": "v1", "K2": "v2", "K3": ""},
+		},
+		"export prefix": {
+			contents:        "export K1=v1\nexport\tK2=v2\nexporter=v3",
+			expectedEnvVars: map[string]string{"K1": "v1", "K2": "v2", "exporter": "v3"},
+		},
+		"inline comments": {
+			contents:        "K1=v1 # comment\nK2=v#2\nK3=\"v 3\" # comment\nK4='v 4' # comment",
+			expectedEnvVars: map[string]string{"K1": "v1", "K2": "v#2", "K3": "v 3", "K4": "v 4"},
+		},
+		"double-quoted values with escapes": {
+			contents:        `K1="a\nb\tc \"d\" \\e # f"`,
+			expectedEnvVars: map[string]string{"K1": "a\nb\tc \"d\" \\e # f"},
+		},
+		"single-quoted values are literal": {
+			contents:        `K1='a\nb "c"'`,
+			expectedEnvVars: map[string]string{"K1": `a\nb "c"`},
+		},
+		"CRLF line endings": {
+			contents:        "K1=v1\r\nK2=v2\r\n",
+			expectedEnvVars: map[string]string{"K1": "v1", "K2": "v2"},
+		},
+		"missing equals sign": {
+			contents:      "K1=v1\nK2\n",
+			expectedError: `^failed parsing dotenv file '.+' at line 2: expected KEY=VALUE$`,
+		},
+		"invalid key": {
+			contents:      "MY KEY=v1",
+			expectedError: `^failed parsing dotenv file '.+' at line 1: invalid key 'MY KEY'$`,
+		},
+		"unterminated double-quoted value": {
+			contents:      "\nK1=\"v1",
+			expectedError: `^failed parsing dotenv file '.+' at line 2: unterminated double-quoted value$`,
+		},
+		"unterminated single-quoted value": {
+			contents:      "K1='v1",
+			expectedError: `^failed parsing dotenv file '.+' at line 1: unterminated single-quoted value$`,
+		},
+		"characters after quoted value": {
+			contents:      `K1="v1"v2`,
+			expectedError: `^failed parsing dotenv file '.+' at line 1: unexpected characters after quoted value: v2$`,
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			path := filepath.Join(t.TempDir(), ".env")
+			With(t).Verify(os.WriteFile(path, []byte(tc.contents), 0600)).Will(Succeed()).OrFail()
+			envVars, err := LoadDotEnv(path)
+			if tc.expectedError != "" {
+				With(t).Verify(err).Will(Fail(tc.expectedError)).OrFail()
+			} else {
+				With(t).Verify(err).Will(BeNil()).OrFail()
+				With(t).Verify(envVars).Will(EqualTo(tc.expectedEnvVars)).OrFail()
+			}
+		})
+	}
+}
+
+func TestLoadDotEnvMissingFile(t *testing.T) {
+	t.Parallel()
+	_, err := LoadDotEnv(filepath.Join(t.TempDir(), ".env"))
+	With(t).Verify(err).Will(Fail(`^failed reading dotenv file: open .+: no such file or directory$`)).OrFail()
+}
+
+func TestLevenshteinDistance(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		a, b             string
+		expectedDistance int
+	}
+	testCases := map[string]testCase{
+		"equal":         {a: "color", b: "color", expectedDistance: 0},
+		"empty":         {a: "", b: "abc", expectedDistance: 3},
+		"deletion":      {a: "colr", b: "color", expectedDistance: 1},
+		"substitution":  {a: "colur", b: "color", expectedDistance: 1},
+		"transposition": {a: "stauts", b: "status", expectedDistance: 2},
+		"different":     {a: "kitten", b: "sitting", expectedDistance: 3},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			With(t).Verify(levenshteinDistance(tc.a, tc.b)).Will(EqualTo(tc.expectedDistance)).OrFail()
+			With(t).Verify(levenshteinDistance(tc.b, tc.a)).Will(EqualTo(tc.expectedDistance)).OrFail()
+		})
+	}
+}
+
+func TestFindClosest(t *testing.T) {
+	t.Parallel()
+	candidates := []string{"color", "verbose", "version"}
+	With(t).Verify(findClosest("colr", candidates, 2)).Will(EqualTo("color")).OrFail()
+	With(t).Verify(findClosest("versoin", candidates, 2)).Will(EqualTo("version")).OrFail()
+	With(t).Verify(findClosest("quiet", candidates, 2)).Will(BeEmpty()).OrFail()
+}
+
+func TestGetFallbackTerminalWidth(t *testing.T) {
+	type testCase struct {
+		columns       *string
+		expectedWidth int
+	}
+	testCases := map[string]testCase{
+		"unset":       {columns: nil, expectedWidth: 80},
+		"valid":       {columns: ptrOf("120"), expectedWidth: 120},
+		"padded":      {columns: ptrOf(" 100 "), expectedWidth: 100},
+		"empty":       {columns: ptrOf(""), expectedWidth: 80},
+		"unparseable": {columns: ptrOf("wide"), expectedWidth: 80},
+		"zero":        {columns: ptrOf("0"), expectedWidth: 80},
+		"negative":    {columns: ptrOf("-20"), expectedWidth: 80},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			if tc.columns != nil {
+				t.Setenv("COLUMNS", *tc.columns)
+			} else {
+				t.Setenv("COLUMNS", "")
+				With(t).Verify(os.Unsetenv("COLUMNS")).Will(Succeed()).OrFail()
+			}
+			With(t).Verify(getFallbackTerminalWidth()).Will(EqualTo(tc.expectedWidth)).OrFail()
+		})
+	}
+}
+
+func TestUpperCaseKeys(t *testing.T) {
+	t.Parallel()
+	With(t).Verify(upperCaseKeys(nil)).Will(EqualTo(map[string]string{})).OrFail()
+	With(t).
+		Verify(upperCaseKeys(map[string]string{"path": "1", "Home": "2", "USER": "3"})).
+		Will(EqualTo(map[string]string{"PATH": "1", "HOME": "2", "USER": "3"})).
+		OrFail()
+	With(t).
+		Verify(upperCaseKeys(map[string]string{"path": "1", "PATH": "2", "Path": "3"})).
+		Will(EqualTo(map[string]string{"PATH": "2"})).
+		OrFail()
+}
+
+func TestColorize(t *testing.T) {
+	t.Parallel()
+	With(t).Verify(colorize("text", ansiBold, false)).Will(EqualTo("text")).OrFail()
+	With(t).Verify(colorize("text", ansiBold, true)).Will(EqualTo("\x1b[1mtext\x1b[0m")).OrFail()
+}
+
+func TestShouldColorize(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	With(t).Verify(shouldColorize(&bytes.Buffer{})).Will(EqualTo(false)).OrFail()
+
+	t.Setenv("NO_COLOR", "1")
+	With(t).Verify(shouldColorize(os.Stdout)).Will(EqualTo(false)).OrFail()
+}
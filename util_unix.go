@@ -0,0 +1,47 @@
+//go:build !windows
+
+package command
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+func getTerminalWidth() int {
+	fd := int(os.Stdout.Fd())
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return getFallbackTerminalWidth()
+	}
+	return int(ws.Col)
+}
+
+// isTerminal returns whether the given writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	_, err := unix.IoctlGetWinsize(int(f.Fd()), unix.TIOCGWINSZ)
+	return err == nil
+}
+
+// readSecretLine reads a single line from the given terminal, without echoing it back.
+func readSecretLine(f *os.File) (string, error) {
+	fd := int(f.Fd())
+	state, err := unix.IoctlGetTermios(fd, ioctlReadTermios)
+	if err != nil {
+		return "", err
+	}
+	noEcho := *state
+	noEcho.Lflag &^= unix.ECHO
+	noEcho.Lflag |= unix.ICANON | unix.ISIG
+	noEcho.Iflag |= unix.ICRNL
+	if err := unix.IoctlSetTermios(fd, ioctlWriteTermios, &noEcho); err != nil {
+		return "", err
+	}
+	defer func() { _ = unix.IoctlSetTermios(fd, ioctlWriteTermios, state) }()
+	return readLine(f)
+}
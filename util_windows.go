@@ -0,0 +1,46 @@
+//go:build windows
+
+package command
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func getTerminalWidth() int {
+	var info windows.ConsoleScreenBufferInfo
+	if err := windows.GetConsoleScreenBufferInfo(windows.Handle(os.Stdout.Fd()), &info); err != nil {
+		return getFallbackTerminalWidth()
+	}
+	if width := int(info.Window.Right-info.Window.Left) + 1; width > 0 {
+		return width
+	}
+	return getFallbackTerminalWidth()
+}
+
+// isTerminal returns whether the given writer is a console.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	var mode uint32
+	return windows.GetConsoleMode(windows.Handle(f.Fd()), &mode) == nil
+}
+
+// readSecretLine reads a single line from the given console, without echoing it back.
+func readSecretLine(f *os.File) (string, error) {
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		return "", err
+	}
+	noEcho := mode&^windows.ENABLE_ECHO_INPUT | windows.ENABLE_PROCESSED_INPUT | windows.ENABLE_LINE_INPUT
+	if err := windows.SetConsoleMode(handle, noEcho); err != nil {
+		return "", err
+	}
+	defer func() { _ = windows.SetConsoleMode(handle, mode) }()
+	return readLine(f)
+}
@@ -6,11 +6,75 @@ import (
 	"unicode"
 )
 
+// escapeState tracks the parsing state of ANSI escape sequences (e.g. "\x1b[1m" for bold text) written to a
+// [WrappingWriter], which are emitted verbatim, but are not counted towards the line width.
+type escapeState int
+
+const (
+	escapeNone  escapeState = iota // not in an escape sequence
+	escapeStart                    // "\x1b" was written
+	escapeCSI                      // "\x1b[" was written, and the sequence is not terminated yet
+)
+
+// next returns the escape state after the given rune is written in this state.
+func (s escapeState) next(r rune) escapeState {
+	switch {
+	case s == escapeNone && r == '\x1b':
+		return escapeStart
+	case s == escapeStart && r == '[':
+		return escapeCSI
+	case s == escapeCSI && (r < 0x40 || r > 0x7E):
+		return escapeCSI
+	default:
+		return escapeNone
+	}
+}
+
+// visibleLen returns the number of the given runes that are visible, i.e. excluding ANSI escape sequences.
+func visibleLen(runes []rune) int {
+	n := 0
+	state := escapeNone
+	for _, r := range runes {
+		if state == escapeNone && r != '\x1b' {
+			n++
+		} else {
+			state = state.next(r)
+		}
+	}
+	return n
+}
+
+// Truncate returns the given string cut to fit in the given width (counted in runes), e.g. for fixed-width columns. If
+// the string is wider than that, it's cut at the last word boundary that fits (or mid-word, if there's none), and an
+// ellipsis ("…") is appended to it.
+func Truncate(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	} else if width <= 0 {
+		return ""
+	}
+
+	// Leave room for the ellipsis, preferring to cut at whitespace (which is dropped along with the rest of the string)
+	cut := width - 1
+	for j := cut; j > 0; j-- {
+		if unicode.IsSpace(runes[j]) {
+			cut = j
+			break
+		}
+	}
+	return strings.TrimRightFunc(string(runes[:cut]), unicode.IsSpace) + "…"
+}
+
 type WrappingWriter struct {
 	data                   []rune
 	width                  int
 	remainingToNextNewLine int
 	linePrefix             string
+	continuationPrefix     string
+	firstLinePending       bool
+	breakChars             string
+	escape                 escapeState
 }
 
 func NewWrappingWriter(width int) (*WrappingWriter, error) {
@@ -21,61 +85,116 @@ func NewWrappingWriter(width int) (*WrappingWriter, error) {
 }
 
 func (w *WrappingWriter) SetLinePrefix(prefix string) error {
-	if len(prefix) >= w.width {
-		return fmt.Errorf("invalid prefix '%s': too larger for width %d", prefix, w.width)
-	} else if strings.Contains(prefix, "\n") {
-		return fmt.Errorf("invalid prefix '%s': cannot contain new lines", prefix)
+	return w.SetLinePrefixes(prefix, prefix)
+}
+
+// SetLinePrefixes sets the prefix of the first line written from now on (or of the current line, if it's already been
+// started), and a different prefix for all lines following it, whether wrapped or explicitly started. This is useful
+// for hanging indents, e.g. for descriptions that start after a name column, and whose following lines are indented
+// to align with it.
+func (w *WrappingWriter) SetLinePrefixes(first, continuation string) error {
+	for _, prefix := range []string{first, continuation} {
+		if len(prefix) >= w.width {
+			return fmt.Errorf("invalid prefix '%s': too larger for width %d", prefix, w.width)
+		} else if strings.Contains(prefix, "\n") {
+			return fmt.Errorf("invalid prefix '%s': cannot contain new lines", prefix)
+		}
 	}
-	w.linePrefix = prefix
+	w.linePrefix = first
+	w.continuationPrefix = continuation
+	w.firstLinePending = len(w.data) == 0 || w.data[len(w.data)-1] == '\n'
 	return nil
 }
 
+// nextLinePrefix returns the prefix for the line being started, which is the first-line prefix for the first line
+// started after the prefixes were set, and the continuation prefix for all lines after it.
+func (w *WrappingWriter) nextLinePrefix() string {
+	if w.firstLinePending {
+		w.firstLinePending = false
+		return w.linePrefix
+	}
+	return w.continuationPrefix
+}
+
+// SetBreakChars sets additional characters (e.g. "-/") after which lines may be broken, when a line exceeds the width but
+// contains no whitespace to break it at. By default, lines are only broken at whitespace.
+func (w *WrappingWriter) SetBreakChars(chars string) error {
+	if strings.ContainsFunc(chars, unicode.IsSpace) {
+		return fmt.Errorf("invalid break characters '%s': cannot contain whitespace", chars)
+	}
+	w.breakChars = chars
+	return nil
+}
+
+// findBreakIndex returns the index of the character in the current line after which it should be broken, or -1 if it
+// cannot be broken. Whitespace is preferred over the configured break characters, which are only used when the line
+// contains no whitespace to break at.
+func (w *WrappingWriter) findBreakIndex() int {
+	isBreakChar := func(r rune) bool { return strings.ContainsRune(w.breakChars, r) }
+	for _, canBreakAt := range []func(rune) bool{unicode.IsSpace, isBreakChar} {
+		for j := len(w.data) - 1; j >= 0; j-- {
+			rr := w.data[j]
+			if rr == '\n' {
+				// Current line has no break character
+				break
+			} else if visibleLen(w.data[j:])+len(w.continuationPrefix) >= w.width {
+				// Text after this character is already at width-length (including prefix), so breaking won't help
+				break
+			} else if canBreakAt(rr) {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
 func (w *WrappingWriter) Write(p []byte) (n int, err error) {
 	srcRunes := []rune(string(p))
 	for i := 0; i < len(srcRunes); i++ {
 		r := srcRunes[i]
-		if r == '\n' {
+		if w.escape != escapeNone || r == '\x1b' {
+			// Escape sequences are written verbatim, without counting towards the line width
+			if len(w.data) == 0 || w.data[len(w.data)-1] == '\n' {
+				prefix := w.nextLinePrefix()
+				w.data = append(w.data, []rune(prefix)...)
+				w.remainingToNextNewLine -= len(prefix)
+			}
+			w.data = append(w.data, r)
+			w.escape = w.escape.next(r)
+		} else if r == '\n' {
 			if len(w.data) == 0 || (i > 0 && w.data[len(w.data)-1] == '\n') {
-				w.data = append(w.data, []rune(w.linePrefix)...)
+				w.data = append(w.data, []rune(w.nextLinePrefix())...)
 			}
 			w.data = append(w.data, r)
 			w.remainingToNextNewLine = w.width
 		} else if w.remainingToNextNewLine == 0 {
-			for j := len(w.data) - 1; j >= 0; j-- {
-				rr := w.data[j]
-				if rr == '\n' {
-					// Current line has no space; just keep writing this line without splitting it
-					w.data = append(w.data, r)
-					break
-				} else if len(w.data)-j+len(w.linePrefix) >= w.width {
-					// current line is already at width-length (including prefix) - just keep writing
-					w.data = append(w.data, r)
-					break
-				} else if unicode.IsSpace(rr) {
-					var runesBeforeSpace, runesAfterSpace []rune
-					runesBeforeSpace = w.data[0 : j+1]
-					if j < len(w.data)-1 {
-						runesAfterSpace = w.data[j+1:]
-					}
-					w.data = make([]rune, 0, len(runesBeforeSpace)+len(runesAfterSpace)+1)
-					w.data = append(w.data, runesBeforeSpace...)
-					w.data = append(w.data, '\n')
-					w.data = append(w.data, []rune(w.linePrefix)...)
-					w.data = append(w.data, runesAfterSpace...)
-					w.data = append(w.data, r)
-
-					// Remaining characters now equal width minus text after last space, minus the char we just wrote
-					w.remainingToNextNewLine = w.width - len(w.linePrefix) - len(runesAfterSpace) - 1
-					if w.remainingToNextNewLine < 0 {
-						w.remainingToNextNewLine = 0
-					}
-					break
+			if j := w.findBreakIndex(); j < 0 {
+				// Current line cannot be broken; just keep writing this line without splitting it
+				w.data = append(w.data, r)
+			} else {
+				var runesBeforeBreak, runesAfterBreak []rune
+				runesBeforeBreak = w.data[0 : j+1]
+				if j < len(w.data)-1 {
+					runesAfterBreak = w.data[j+1:]
+				}
+				w.data = make([]rune, 0, len(runesBeforeBreak)+len(runesAfterBreak)+1)
+				w.data = append(w.data, runesBeforeBreak...)
+				w.data = append(w.data, '\n')
+				w.data = append(w.data, []rune(w.continuationPrefix)...)
+				w.data = append(w.data, runesAfterBreak...)
+				w.data = append(w.data, r)
+
+				// Remaining characters now equal width minus text after the break, minus the char we just wrote
+				w.remainingToNextNewLine = w.width - len(w.continuationPrefix) - visibleLen(runesAfterBreak) - 1
+				if w.remainingToNextNewLine < 0 {
+					w.remainingToNextNewLine = 0
 				}
 			}
 		} else {
 			if len(w.data) == 0 || w.data[len(w.data)-1] == '\n' {
-				w.data = append(w.data, []rune(w.linePrefix)...)
-				w.remainingToNextNewLine -= len(w.linePrefix)
+				prefix := w.nextLinePrefix()
+				w.data = append(w.data, []rune(prefix)...)
+				w.remainingToNextNewLine -= len(prefix)
 			}
 			w.data = append(w.data, r)
 			w.remainingToNextNewLine--
@@ -84,6 +203,20 @@ func (w *WrappingWriter) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// WriteDivider writes a line consisting of the given character repeated to fill the width (after the line prefix), e.g.
+// for separating sections. If the current line is not empty, the divider starts on a new line. The divider is followed
+// by a new line, and is never wrapped.
+func (w *WrappingWriter) WriteDivider(ch rune) {
+	if len(w.data) > 0 && w.data[len(w.data)-1] != '\n' {
+		w.data = append(w.data, '\n')
+	}
+	prefix := w.nextLinePrefix()
+	w.data = append(w.data, []rune(prefix)...)
+	w.data = append(w.data, []rune(strings.Repeat(string(ch), w.width-len(prefix)))...)
+	w.data = append(w.data, '\n')
+	w.remainingToNextNewLine = w.width
+}
+
 func (w *WrappingWriter) String() string {
 	return string(w.data)
 }
@@ -12,6 +12,7 @@ func TestWrappingWriter(t *testing.T) {
 		inputs         [][]byte
 		width          int
 		prefix         string
+		breakChars     string
 		expectedString string
 	}
 	testCases := map[string]testCase{
@@ -259,6 +260,75 @@ one two
     two
 `,
 		},
+		"long token without break characters": {
+			inputs: [][]byte{
+				[]byte("use --very-long-key=value"),
+			},
+			width: 12,
+			expectedString: `
+use 
+--very-long-key=value
+`,
+		},
+		"long token with break characters": {
+			inputs: [][]byte{
+				[]byte("use --very-long-key=value"),
+			},
+			width:      12,
+			breakChars: "-/",
+			expectedString: `
+use 
+--very-long-
+key=value
+`,
+		},
+		"url with break characters": {
+			inputs: [][]byte{
+				[]byte("https://example.com/some/long/path"),
+			},
+			width:      16,
+			breakChars: "-/",
+			expectedString: `
+https://
+example.com/
+some/long/path
+`,
+		},
+		"whitespace preferred over break characters": {
+			inputs: [][]byte{
+				[]byte("a-b c-d e-f"),
+			},
+			width:      8,
+			breakChars: "-",
+			expectedString: `
+a-b c-d 
+e-f
+`,
+		},
+		"colored text, single line under width": {
+			inputs: [][]byte{
+				[]byte("\x1b[1mhello\x1b[0m world"),
+			},
+			width:          11,
+			expectedString: "\n\x1b[1mhello\x1b[0m world\n",
+		},
+		"colored text, multi-line over width": {
+			inputs: [][]byte{
+				[]byte("\x1b[1mhello\x1b[0m \x1b[31mworld\x1b[0m test"),
+			},
+			width:          11,
+			expectedString: "\n\x1b[1mhello\x1b[0m \n\x1b[31mworld\x1b[0m test\n",
+		},
+		"colored text split across inputs, with prefix": {
+			inputs: [][]byte{
+				[]byte("\x1b["),
+				[]byte("1mhello"),
+				[]byte("\x1b[0m world test"),
+			},
+			width:          10,
+			prefix:         "  ",
+			expectedString: "\n  \x1b[1mhello\x1b[0m \n  world \n  test\n",
+		},
 	}
 	for name, tc := range testCases {
 		tc := tc
@@ -270,6 +340,9 @@ one two
 			if tc.prefix != "" {
 				With(t).Verify(w.SetLinePrefix(tc.prefix)).Will(Succeed()).OrFail()
 			}
+			if tc.breakChars != "" {
+				With(t).Verify(w.SetBreakChars(tc.breakChars)).Will(Succeed()).OrFail()
+			}
 
 			for _, input := range tc.inputs {
 				With(t).Verify(w.Write(input)).Will(Succeed()).OrFail()
@@ -279,3 +352,149 @@ one two
 		})
 	}
 }
+
+func TestWrappingWriterSetLinePrefixes(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		before         string
+		first          string
+		continuation   string
+		inputs         []string
+		expectedString string
+	}
+	testCases := map[string]testCase{
+		"first line unindented, wrapped lines indented": {
+			first:          "",
+			continuation:   "    ",
+			inputs:         []string{"one two three four"},
+			expectedString: "one two \n    three \n    four",
+		},
+		"explicit new lines use continuation prefix": {
+			first:          "> ",
+			continuation:   "  ",
+			inputs:         []string{"one\ntwo\nthree"},
+			expectedString: "> one\n  two\n  three",
+		},
+		"set mid-line applies continuation to following lines": {
+			before:         "name  ",
+			first:          "",
+			continuation:   "      ",
+			inputs:         []string{"one two three"},
+			expectedString: "name  one \n      two \n      three",
+		},
+		"same prefixes behave like a single prefix": {
+			first:          "  ",
+			continuation:   "  ",
+			inputs:         []string{"hello world test"},
+			expectedString: "  hello \n  world \n  test",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			w, err := NewWrappingWriter(10)
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			With(t).Verify(w.Write([]byte(tc.before))).Will(Succeed()).OrFail()
+			With(t).Verify(w.SetLinePrefixes(tc.first, tc.continuation)).Will(Succeed()).OrFail()
+			for _, input := range tc.inputs {
+				With(t).Verify(w.Write([]byte(input))).Will(Succeed()).OrFail()
+			}
+			With(t).Verify(w.String()).Will(EqualTo(tc.expectedString)).OrFail()
+		})
+	}
+}
+
+func TestWrappingWriterSetLinePrefixesInvalid(t *testing.T) {
+	t.Parallel()
+	w, err := NewWrappingWriter(10)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	With(t).Verify(w.SetLinePrefixes("", "          ")).Will(Fail(`^invalid prefix '          ': too larger for width 10$`)).OrFail()
+	With(t).Verify(w.SetLinePrefixes("\n", "")).Will(Fail(`^invalid prefix '\n': cannot contain new lines$`)).OrFail()
+}
+
+func TestWrappingWriterWriteDivider(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		before         string
+		after          string
+		prefix         string
+		expectedString string
+	}
+	testCases := map[string]testCase{
+		"divider only": {
+			expectedString: "----------\n",
+		},
+		"divider after complete line": {
+			before:         "hello\n",
+			after:          "world",
+			expectedString: "hello\n----------\nworld",
+		},
+		"divider after partial line": {
+			before:         "hello",
+			after:          "world",
+			expectedString: "hello\n----------\nworld",
+		},
+		"text after divider is wrapped at full width": {
+			before:         "hello",
+			after:          "one two three",
+			expectedString: "hello\n----------\none two \nthree",
+		},
+		"divider with prefix": {
+			before:         "hello",
+			after:          "one two three",
+			prefix:         "  ",
+			expectedString: "  hello\n  --------\n  one two \n  three",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			w, err := NewWrappingWriter(10)
+			With(t).Verify(err).Will(BeNil()).OrFail()
+			if tc.prefix != "" {
+				With(t).Verify(w.SetLinePrefix(tc.prefix)).Will(Succeed()).OrFail()
+			}
+			With(t).Verify(w.Write([]byte(tc.before))).Will(Succeed()).OrFail()
+			w.WriteDivider('-')
+			With(t).Verify(w.Write([]byte(tc.after))).Will(Succeed()).OrFail()
+			With(t).Verify(w.String()).Will(EqualTo(tc.expectedString)).OrFail()
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	t.Parallel()
+	type testCase struct {
+		s        string
+		width    int
+		expected string
+	}
+	testCases := map[string]testCase{
+		"shorter than width":          {s: "hello", width: 10, expected: "hello"},
+		"exactly width":               {s: "hello world", width: 11, expected: "hello world"},
+		"cut at word boundary":        {s: "hello world again", width: 14, expected: "hello world…"},
+		"cut right before whitespace": {s: "hello world again", width: 12, expected: "hello world…"},
+		"cut mid-word without spaces": {s: "helloworld", width: 6, expected: "hello…"},
+		"multiple spaces are dropped": {s: "hello   world", width: 9, expected: "hello…"},
+		"runes are counted":           {s: "héllo wörld ağain", width: 14, expected: "héllo wörld…"},
+		"width of one":                {s: "hello", width: 1, expected: "…"},
+		"zero width":                  {s: "hello", width: 0, expected: ""},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			With(t).Verify(Truncate(tc.s, tc.width)).Will(EqualTo(tc.expected)).OrFail()
+		})
+	}
+}
+
+func TestWrappingWriterSetBreakChars(t *testing.T) {
+	t.Parallel()
+	w, err := NewWrappingWriter(10)
+	With(t).Verify(err).Will(BeNil()).OrFail()
+	With(t).Verify(w.SetBreakChars("-/")).Will(Succeed()).OrFail()
+	With(t).Verify(w.SetBreakChars("- ")).Will(Fail(`^invalid break characters '- ': cannot contain whitespace$`)).OrFail()
+}